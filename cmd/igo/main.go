@@ -6,11 +6,13 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strings"
 )
 
 import (
 	"igo/config"
 	"igo/log"
+	"igo/metrics"
 	"igo/server"
 )
 
@@ -19,29 +21,85 @@ var (
         Welcome IGO!
 `
 
-	configFile = flag.String("config", "./igo_config.toml", "Input the config file path")
+	configs     configURLs
+	checkConfig = flag.Bool("check-config", false, "Check the config, print every problem and exit")
 )
 
+func init() {
+	flag.Var(&configs, "config", "The config source, repeat it to override the keys by the later, default ./igo_config.toml.\n"+
+		"The file path of the zk params or the whole config, or the url of file://, zk://, etcd://, consul:// and env://")
+}
+
+//configURLs the config sources of the -config flags.
+type configURLs []string
+
+func (c *configURLs) String() string {
+	return strings.Join(*c, " ")
+}
+
+func (c *configURLs) Set(s string) error {
+	*c = append(*c, s)
+	return nil
+}
+
 func main() {
+	flag.Parse()
+	if len(configs) == 0 {
+		configs = configURLs{"./igo_config.toml"}
+	}
+	if *checkConfig {
+		os.Exit(check(configs))
+	}
+
 	//print banner
 	fmt.Println(banner)
 
+	//load config
+	src, err := config.OpenLayers(configs...)
+	if err != nil {
+		log.Error(err)
+		os.Exit(-1)
+	}
+	cfg, err := src.Parse()
+	if err != nil {
+		log.Error(err)
+		os.Exit(-1)
+	}
+
+	//new and run server, reload it when the config changed or on SIGHUP
+	s := server.NewServer(cfg)
+	s.SetConfiger(src)
+
+	//pprof, metrics and the admin api
+	http.Handle("/metrics", metrics.Handler())
+	http.Handle("/api/", s.APIHandler())
 	go func() {
-		log.Error(http.ListenAndServe(":6060", nil))
+		log.Error(server.ListenHTTP(&cfg.Server, nil))
 	}()
 
-	//load config
-	flag.Parse()
-	var cfg *config.Config
-	if configFile != nil {
-		c, err := config.ParseConfig(*configFile)
-		if err != nil {
-			log.Error(err)
-			os.Exit(-1)
-		}
-		cfg = c
+	if err := src.Watch(s.Reload); err != nil {
+		log.Error(err)
 	}
+	log.Error(s.Run())
+}
 
-	//new and run server
-	log.Error(server.NewServer(cfg).Run())
+//check print the problems of the config, return the exit code.
+func check(urls []string) int {
+	fname := strings.Join(urls, " ")
+	src, err := config.OpenLayers(urls...)
+	if err == nil {
+		_, err = src.Parse()
+	}
+	if err == nil {
+		fmt.Printf("%v: config OK\n", fname)
+		return 0
+	}
+	if errs, ok := err.(config.ValidateError); ok {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", fname, e)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", fname, err)
+	}
+	return 1
 }
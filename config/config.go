@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
 
 	"time"
 
@@ -13,27 +17,70 @@ type Configer interface {
 	Parse() (*Config, error)
 }
 
+//Watcher the config source notify the changes, fn is called with each valid new config.
+//The config fn returned error is not taken as the last good one.
+type Watcher interface {
+	Watch(fn func(*Config) error) error
+}
+
 //Config all the config
 type Config struct {
-	Server ServerConfig `toml:"Server"`
+	Server ServerConfig   `toml:"Server"`
+	Nodes  []NodeConfig   `toml:"Node"`
+	Shards []ShardConfig  `toml:"Shard"`
+	Users  []UserConfig   `toml:"User"`
+	Rules  []FirewallRule `toml:"Firewall"`
+	Limits []RateLimit    `toml:"RateLimit"`
 	// Redis  ServerConfig `toml:"Server.redis"`
 }
 
 //ServerConfig the server config
 type ServerConfig struct {
-	Listen    string `toml:"listen"`
-	Addr      string `toml:"dbaddr"`
-	DBName    string `toml:"dbname"`
-	User      string `toml:"user"`
-	Passwd    string `toml:"passwd"`
-	Collation string `toml:"collation"`
-
-	MaxClient    int64 `toml:"maxClient"`
-	WriteTimeout int   `toml:"writeTimeout"`
-	ReadTimeout  int   `toml:"readTimeout"`
-	MaxLifeTime  int   `toml:"maxLifeTmie"`
-	MaxIdleConn  int   `toml:"maxIdleConn"`
-	MaxConnNum   int   `toml:"maxConnNum"`
+	Listen    string   `toml:"listen"`
+	Addr      string   `toml:"dbaddr"`
+	DBName    string   `toml:"dbname"`
+	User      string   `toml:"user"`
+	Passwd    Password `toml:"passwd"` //file:path, env:NAME or cmd:command read the secret
+	Collation string   `toml:"collation"`
+
+	Slaves    []string `toml:"slaves"`    //slave addrs of the default node, read statements go to them
+	KeepHints bool     `toml:"keepHints"` //send the /*igo:...*/ hints to mysql, strip them by default
+
+	Consistency string `toml:"consistency"` //read your writes: "session", "token" or empty to disable
+	GTIDWait    int    `toml:"gtidWait"`    //milliseconds to wait the slave execute the gtid, 0 only check gtid_executed
+
+	SlowLog  string `toml:"slowLog"`  //the slow log file, empty to disable
+	SlowTime int    `toml:"slowTime"` //milliseconds, the commands took longer are logged, 0 log all
+
+	MaxExecTime int `toml:"maxExecTime"` //milliseconds, the statements run longer are killed on the backend, 0 no limit
+
+	MaxResultRows  int    `toml:"maxResultRows"`  //the max rows of a result set, 0 no limit
+	MaxResultBytes int    `toml:"maxResultBytes"` //the max bytes of a result set, 0 no limit
+	ResultLimit    string `toml:"resultLimit"`    //the result over the limit: "kill"(default) kill the query, "drain" read and discard the rest
+	InjectLimit    bool   `toml:"injectLimit"`    //add LIMIT maxResultRows to the SELECT without LIMIT, the result is truncated instead of failing
+
+	FirewallAllowlist string `toml:"firewallAllowlist"` //the file of the learned digests, one fingerprint per line
+
+	AuditLog  string `toml:"auditLog"`  //the audit log file of every statement, empty to disable
+	AuditMode string `toml:"auditMode"` //"sql"(default) record the sql text, "digest" only the digest
+
+	AdminListen string   `toml:"adminListen"` //the admin port speaks the mysql protocol, empty to disable
+	AdminUser   string   `toml:"adminUser"`
+	AdminPasswd Password `toml:"adminPasswd"` //cleartext, mysql_native_password hash "*HEX" or the secret reference
+
+	HTTPListen   string   `toml:"httpListen"`   //pprof, /metrics and the /api of the admin, default ":6060"
+	HTTPToken    Password `toml:"httpToken"`    //the bearer token of the /api, or the secret reference
+	HTTPCert     string   `toml:"httpCert"`     //serve https with the cert and key files
+	HTTPKey      string   `toml:"httpKey"`      //the key file of httpCert
+	HTTPClientCA string   `toml:"httpClientCA"` //require the client certificates signed by the CA file, they are authorized to the /api
+
+	MaxClient     int64 `toml:"maxClient"`
+	MaxClientWait int   `toml:"maxClientWait"` //milliseconds the new client waits at maxClient, then gets "Too many connections", 0 no wait
+	WriteTimeout  int   `toml:"writeTimeout"`
+	ReadTimeout   int   `toml:"readTimeout"`
+	MaxLifeTime   int   `toml:"maxLifeTmie"`
+	MaxIdleConn   int   `toml:"maxIdleConn"`
+	MaxConnNum    int   `toml:"maxConnNum"`
 
 	Loc              *time.Location //location time
 	ColumnsWithAlias bool
@@ -41,16 +88,129 @@ type ServerConfig struct {
 	Strict bool
 }
 
+//NodeConfig a backend mysql node, user, passwd and pool size are shared with ServerConfig.
+type NodeConfig struct {
+	Name   string   `toml:"name"`
+	Addr   string   `toml:"dbaddr"`
+	Slaves []string `toml:"slaves"`
+}
+
+//ShardConfig a sharded table and the rule to locate the node by the sharding key.
+type ShardConfig struct {
+	Table  string            `toml:"table"`
+	Key    string            `toml:"key"`
+	Rule   string            `toml:"rule"`   //hash, range, date or lookup
+	Nodes  []string          `toml:"nodes"`  //node names, "default" is the ServerConfig dbaddr
+	Bounds []string          `toml:"bounds"` //range and date rule: the upper bounds of nodes[0:len(nodes)-1]
+	Lookup map[string]string `toml:"lookup"` //lookup rule: key value -> node name
+	Policy string            `toml:"policy"` //statements without key: reject(default) or broadcast
+}
+
+//the date layouts of the date rule bounds and key values
+var dateLayouts = []string{"2006-01-02 15:04:05", "2006-01-02", "20060102"}
+
+//ParseShardValue parse the range and date rule value to int64, the date is the unix time in local.
+func ParseShardValue(rule, s string) (int64, error) {
+	if !strings.EqualFold(rule, "date") {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	for _, layout := range dateLayouts {
+		if len(layout) != len(s) {
+			continue
+		}
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown date format %q", s)
+}
+
+//ParseBounds parse the bounds of the range and date rule, they must be ascending.
+func (sc *ShardConfig) ParseBounds() ([]int64, error) {
+	bounds := make([]int64, 0, len(sc.Bounds))
+	for _, b := range sc.Bounds {
+		v, err := ParseShardValue(sc.Rule, b)
+		if err != nil {
+			return nil, fmt.Errorf("bad bound %q: %v", b, err)
+		}
+		if n := len(bounds); n > 0 && v <= bounds[n-1] {
+			return nil, errors.New("bounds must be ascending")
+		}
+		bounds = append(bounds, v)
+	}
+	return bounds, nil
+}
+
+//UserConfig a proxy user, no user configured means any user can connect.
+type UserConfig struct {
+	Name   string   `toml:"name"`
+	Passwd Password `toml:"passwd"` //cleartext, mysql_native_password hash "*HEX" or the secret reference
+	Hosts  []string `toml:"hosts"`  //the client ips or CIDRs allowed to connect as the user, empty allows any
+
+	MaxExecTime    int `toml:"maxExecTime"`    //milliseconds, override the Server maxExecTime if > 0
+	MaxResultRows  int `toml:"maxResultRows"`  //override the Server maxResultRows if > 0
+	MaxResultBytes int `toml:"maxResultBytes"` //override the Server maxResultBytes if > 0
+}
+
+//FirewallRule a firewall rule checked before the statement is routed, the conditions set are all matched.
+//The rules are checked in order, the first allow, deny or learn rule matched decides, no rule matched means allow.
+type FirewallRule struct {
+	Name     string   `toml:"name"`
+	Action   string   `toml:"action"`   //allow, deny, log(log and check the next rules) or learn(add the digest to the allowlist and allow)
+	Users    []string `toml:"users"`    //the proxy users
+	Sources  []string `toml:"sources"`  //the client ips or CIDRs
+	Types    []string `toml:"types"`    //the statement types, such as select, update, drop, truncate
+	Tables   []string `toml:"tables"`   //table in any db or db.table, the tables of the statement without db are in the current db
+	Digests  []string `toml:"digests"`  //the digest ids or the fingerprints
+	Regex    string   `toml:"regex"`    //match the sql text
+	NoWhere  bool     `toml:"noWhere"`  //UPDATE or DELETE without WHERE
+	Unlisted bool     `toml:"unlisted"` //the digest is not in the allowlist
+}
+
+//RateLimit a rate limit of the statements matched, the conditions set are all matched.
+//Every rule matched applies, the statement runs when all of them admit it.
+type RateLimit struct {
+	Name          string   `toml:"name"`
+	Users         []string `toml:"users"`         //the proxy users
+	Sources       []string `toml:"sources"`       //the client ips or CIDRs
+	Digests       []string `toml:"digests"`       //the digest ids or the fingerprints
+	Per           string   `toml:"per"`           //the limit for each "user", "source" ip or "digest", empty shared by all matched
+	QPS           float64  `toml:"qps"`           //the statements per second, 0 no limit
+	Burst         int      `toml:"burst"`         //the statements run at once over the qps, default qps
+	MaxConcurrent int      `toml:"maxConcurrent"` //the statements running at the same time, 0 no limit
+	Wait          int      `toml:"wait"`          //milliseconds to queue the statement over the limit, 0 reject it at once
+}
+
 //ParseConfig parse Config from toml file path.
 func ParseConfig(fname string) (*Config, error) {
-	content, err := ioutil.ReadFile(fname)
+	cfg, err := NewConfiger(fname)
 	if err != nil {
 		return nil, err
 	}
-	cfg := ZKConfig{}
-	if err := toml.Unmarshal(content, &cfg); err != nil {
+	return cfg.Parse()
+}
+
+//NewConfiger read the config source from toml file path,
+//the config is in zk if zk_addrs is set, or else the file is the whole config.
+func NewConfiger(fname string) (Configer, error) {
+	s, err := newSource(fname)
+	if err != nil {
 		return nil, err
 	}
+	return Layers{s}, nil
+}
 
-	return cfg.Parse()
+func newSource(fname string) (Source, error) {
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ZKConfig{}
+	if err := toml.Unmarshal(content, cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Addrs) == 0 {
+		return &FileConfig{Path: fname}, nil
+	}
+	return cfg, nil
 }
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+import (
+	"igo/log"
+)
+
+//consulWait the max wait time of the blocking query.
+const consulWait = 5 * time.Minute
+
+//ConsulConfig the toml config in the consul kv.
+type ConsulConfig struct {
+	Endpoint string //http://host:8500
+	Key      string
+	Token    string
+}
+
+var _ Source = &ConsulConfig{}
+
+//consul://host:8500/key?token=xxx, consul://host:8500/key?tls=true for https.
+func newConsulSource(u *url.URL) (Source, error) {
+	scheme := "http"
+	if u.Query().Get("tls") == "true" {
+		scheme = "https"
+	}
+	return &ConsulConfig{
+		Endpoint: scheme + "://" + u.Host,
+		Key:      strings.TrimPrefix(u.Path, "/"),
+		Token:    u.Query().Get("token"),
+	}, nil
+}
+
+//Load load the toml content of the key.
+func (c *ConsulConfig) Load() (map[string]interface{}, error) {
+	content, _, err := c.get(&http.Client{Timeout: 10 * time.Second}, "")
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Load Consul Config: %v", c.Key)
+	return decodeTree(string(content))
+}
+
+//Notify watch the key by the blocking query.
+func (c *ConsulConfig) Notify(fn func()) error {
+	_, index, err := c.get(&http.Client{Timeout: 10 * time.Second}, "")
+	if err != nil {
+		return err
+	}
+	go func() {
+		cli := &http.Client{Timeout: consulWait + time.Minute}
+		for {
+			_, next, err := c.get(cli, index)
+			if err != nil {
+				log.Errorf("consul watch %v: %v", c.Key, err)
+				time.Sleep(watchRetry)
+				continue
+			}
+			if next != index {
+				index = next
+				fn()
+			}
+		}
+	}()
+	return nil
+}
+
+//get get the raw value and the modify index of the key, it block until the index changed if index is set.
+func (c *ConsulConfig) get(cli *http.Client, index string) ([]byte, string, error) {
+	q := url.Values{"raw": {""}}
+	if index != "" {
+		q.Set("index", index)
+		q.Set("wait", consulWait.String())
+	}
+	req, err := http.NewRequest("GET", c.Endpoint+"/v1/kv/"+c.Key+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("consul: key %q: %v", c.Key, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("X-Consul-Index"), nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+//EnvConfig override the config keys by the environment variables with the prefix,
+//such as IGO_SERVER_PASSWD for [Server] passwd and IGO_NODE_0_DBADDR for the first [[Node]] dbaddr.
+//The value of the string key is used as is, the others are toml values like 100, true or ["a", "b"].
+type EnvConfig struct {
+	Prefix string
+}
+
+var _ Source = &EnvConfig{}
+
+//env://IGO
+func newEnvSource(u *url.URL) (Source, error) {
+	return &EnvConfig{Prefix: strings.ToUpper(u.Host + u.Path)}, nil
+}
+
+//Load load the variables with the prefix.
+func (e *EnvConfig) Load() (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	prefix := e.Prefix + "_"
+	for _, kv := range os.Environ() {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) {
+			continue
+		}
+		path := strings.Split(strings.ToLower(kv[len(prefix):i]), "_")
+		v, err := envValue(path, kv[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		t := tree
+		for _, k := range path[:len(path)-1] {
+			sub, ok := t[k].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				t[k] = sub
+			}
+			t = sub
+		}
+		t[path[len(path)-1]] = v
+	}
+	return tree, nil
+}
+
+//Notify the environment not change.
+func (e *EnvConfig) Notify(fn func()) error {
+	return nil
+}
+
+//envValue parse the value by the type of the config field of the path.
+func envValue(path []string, s string) (interface{}, error) {
+	if typ := fieldType(reflect.TypeOf(Config{}), path); typ == nil || typ.Kind() == reflect.String {
+		return s, nil
+	}
+	var v struct{ V interface{} }
+	if _, err := toml.Decode("V = "+s, &v); err != nil {
+		return nil, err
+	}
+	return v.V, nil
+}
+
+//fieldType find the type of the field by the toml keys, the index of the slice is skipped.
+func fieldType(typ reflect.Type, path []string) reflect.Type {
+	for _, k := range path {
+		if typ.Kind() == reflect.Slice {
+			if _, err := strconv.Atoi(k); err == nil {
+				typ = typ.Elem()
+				continue
+			}
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil
+		}
+		var found bool
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := f.Tag.Get("toml")
+			if name == "" {
+				name = f.Name
+			}
+			if strings.EqualFold(name, k) {
+				typ, found = f.Type, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return typ
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+import (
+	"igo/log"
+)
+
+//EtcdConfig the toml config in the etcd v3 key, by the grpc gateway json api.
+type EtcdConfig struct {
+	Endpoint string //http://host:2379
+	Key      string
+}
+
+var _ Source = &EtcdConfig{}
+
+//etcd://host:2379/key, etcd://host:2379/key?tls=true for https.
+func newEtcdSource(u *url.URL) (Source, error) {
+	scheme := "http"
+	if u.Query().Get("tls") == "true" {
+		scheme = "https"
+	}
+	return &EtcdConfig{Endpoint: scheme + "://" + u.Host, Key: u.Path}, nil
+}
+
+type etcdKV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+//Load load the toml content of the key.
+func (e *EtcdConfig) Load() (map[string]interface{}, error) {
+	var res struct {
+		Kvs []etcdKV `json:"kvs"`
+	}
+	body := map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(e.Key))}
+	resp, err := e.post(&http.Client{Timeout: 10 * time.Second}, "/v3/kv/range", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	if len(res.Kvs) == 0 {
+		return nil, fmt.Errorf("etcd: key %q not found", e.Key)
+	}
+	content, err := base64.StdEncoding.DecodeString(res.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Load Etcd Config: %v", e.Key)
+	return decodeTree(string(content))
+}
+
+//Notify watch the key, the watch is created again after the stream broken.
+func (e *EtcdConfig) Notify(fn func()) error {
+	go func() {
+		body := map[string]interface{}{
+			"create_request": map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(e.Key))},
+		}
+		for {
+			if err := e.watch(body, fn); err != nil {
+				log.Errorf("etcd watch %v: %v", e.Key, err)
+			}
+			time.Sleep(watchRetry)
+			fn() //the changes may be missed
+		}
+	}()
+	return nil
+}
+
+func (e *EtcdConfig) watch(body interface{}, fn func()) error {
+	resp, err := e.post(http.DefaultClient, "/v3/watch", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Events []struct {
+					Kv etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return err
+		}
+		if len(msg.Result.Events) > 0 {
+			fn()
+		}
+	}
+}
+
+func (e *EtcdConfig) post(cli *http.Client, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.Post(e.Endpoint+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd %v: %v", path, resp.Status)
+	}
+	return resp, nil
+}
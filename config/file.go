@@ -0,0 +1,67 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+)
+
+import (
+	"igo/log"
+)
+
+//FileConfig the whole config in a local toml file, no zk needed.
+type FileConfig struct {
+	Path string
+}
+
+var _ Configer = &FileConfig{}
+var _ Source = &FileConfig{}
+
+//file://path, the relative path is file://igo_config.toml
+func newFileSource(u *url.URL) (Source, error) {
+	return &FileConfig{Path: u.Host + u.Path}, nil
+}
+
+//Parse parse config from the file.
+func (f *FileConfig) Parse() (*Config, error) {
+	return Layers{f}.Parse()
+}
+
+//Watch call fn with the new config after the file changed.
+func (f *FileConfig) Watch(fn func(*Config) error) error {
+	return Layers{f}.Watch(fn)
+}
+
+//Load load the raw config of the file.
+func (f *FileConfig) Load() (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTree(string(content))
+}
+
+//Notify poll the modify time of the file.
+func (f *FileConfig) Notify(fn func()) error {
+	fi, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		mtime := fi.ModTime()
+		for range time.Tick(pollInterval) {
+			fi, err := os.Stat(f.Path)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			if !fi.ModTime().Equal(mtime) {
+				mtime = fi.ModTime()
+				fn()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func Test_FileConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "igo_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+[Server]
+listen = "127.0.0.1:6603"
+dbaddr = "127.0.0.1:3306"
+maxClient = 100
+maxConnNum = 10
+
+[[User]]
+name = "app"
+passwd = "app"
+`)
+	f.Close()
+
+	src, err := NewConfiger(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := src.(Layers); !ok || len(l) != 1 {
+		t.Fatalf("expect one layer, got %T", src)
+	} else if _, ok := l[0].(*FileConfig); !ok {
+		t.Fatalf("expect file config, got %T", l[0])
+	}
+	c, err := src.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Addr != "127.0.0.1:3306" || c.Server.MaxClient != 100 || len(c.Users) != 1 {
+		t.Fatalf("config:%+v", c)
+	}
+
+	ioutil.WriteFile(f.Name(), []byte("[Server]\nlisten = \"127.0.0.1:6603\"\n"), 0644)
+	if _, err := src.Parse(); err == nil {
+		t.Fatal("expect invalid config error")
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//the prefixes of the password reference the secret.
+const (
+	secretFile = "file:" //file:/run/secrets/db, the content of the file
+	secretEnv  = "env:"  //env:DB_PASSWD, the environment variable
+	secretCmd  = "cmd:"  //cmd:vault read -field=passwd secret/db, the output of the command
+)
+
+//secretCmdTimeout the max time to run the command of the password.
+var secretCmdTimeout = 10 * time.Second
+
+const redacted = "******"
+
+//Password the password in the config, it is redacted when printed or marshaled.
+type Password string
+
+func (p Password) String() string {
+	if p == "" {
+		return ""
+	}
+	return redacted
+}
+
+//GoString redact %#v.
+func (p Password) GoString() string {
+	return fmt.Sprintf("%q", p.String())
+}
+
+//MarshalText redact the json and toml output.
+func (p Password) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+//Resolve read the secret the password referenced, the trailing newline is trimmed.
+//The password without reference prefix is returned as is.
+func (p Password) Resolve() (Password, error) {
+	s := string(p)
+	switch {
+	case strings.HasPrefix(s, secretFile):
+		data, err := ioutil.ReadFile(s[len(secretFile):])
+		if err != nil {
+			return "", fmt.Errorf("password file: %v", err)
+		}
+		return Password(strings.TrimRight(string(data), "\r\n")), nil
+
+	case strings.HasPrefix(s, secretEnv):
+		v, ok := os.LookupEnv(s[len(secretEnv):])
+		if !ok {
+			return "", fmt.Errorf("password env %v is not set", s[len(secretEnv):])
+		}
+		return Password(v), nil
+
+	case strings.HasPrefix(s, secretCmd):
+		ctx, cancel := context.WithTimeout(context.Background(), secretCmdTimeout)
+		defer cancel()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", s[len(secretCmd):])
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			//the command is not logged, it may contain the secret.
+			return "", fmt.Errorf("password cmd: %v %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return Password(strings.TrimRight(string(out), "\r\n")), nil
+	}
+	return p, nil
+}
+
+//resolveSecrets resolve the passwords of the config.
+func (c *Config) resolveSecrets() error {
+	var err error
+	if c.Server.Passwd, err = c.Server.Passwd.Resolve(); err != nil {
+		return fmt.Errorf("Server: %v", err)
+	}
+	if c.Server.AdminPasswd, err = c.Server.AdminPasswd.Resolve(); err != nil {
+		return fmt.Errorf("Server adminPasswd: %v", err)
+	}
+	if c.Server.HTTPToken, err = c.Server.HTTPToken.Resolve(); err != nil {
+		return fmt.Errorf("Server httpToken: %v", err)
+	}
+	for i := range c.Users {
+		if c.Users[i].Passwd, err = c.Users[i].Passwd.Resolve(); err != nil {
+			return fmt.Errorf("User %q: %v", c.Users[i].Name, err)
+		}
+	}
+	return nil
+}
+
+var passwdLine = regexp.MustCompile(`(?im)^(\s*(?:(?:admin)?passwd|httptoken)\s*=\s*).*$`)
+
+//Redact replace the passwd and token values of the toml content for logging.
+func Redact(content string) string {
+	return passwdLine.ReplaceAllString(content, `${1}"`+redacted+`"`)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_PasswordResolve(t *testing.T) {
+	f, err := ioutil.TempFile("", "igo_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("from-file\n")
+	f.Close()
+	os.Setenv("IGO_TEST_SECRET", "from-env")
+	defer os.Unsetenv("IGO_TEST_SECRET")
+
+	cases := map[Password]Password{
+		"plain":                      "plain",
+		Password("file:" + f.Name()): "from-file",
+		"env:IGO_TEST_SECRET":        "from-env",
+		"cmd:echo from-cmd":          "from-cmd",
+	}
+	for p, want := range cases {
+		got, err := p.Resolve()
+		if err != nil || got != want {
+			t.Fatalf("%s: got %s, %v", string(p), string(got), err)
+		}
+	}
+	for _, p := range []Password{"file:/not/exist", "env:IGO_TEST_NOT_SET", "cmd:exit 1"} {
+		if _, err := p.Resolve(); err == nil {
+			t.Fatalf("%s: expect error", string(p))
+		}
+	}
+}
+
+func Test_PasswordRedact(t *testing.T) {
+	c := Config{Server: ServerConfig{User: "root", Passwd: "topsecret"}, Users: []UserConfig{{Name: "app", Passwd: "appsecret"}}}
+	data, _ := json.Marshal(c)
+	for _, out := range []string{fmt.Sprintf("%v", c), fmt.Sprintf("%+v", c), fmt.Sprintf("%#v", c), string(data)} {
+		if strings.Contains(out, "secret") {
+			t.Fatalf("password not redacted: %s", out)
+		}
+	}
+
+	content := Redact("[Server]\nuser = \"root\"\npasswd = \"topsecret\"\n  passwd=\"x\"\nhttpToken = \"tokensecret\"")
+	if strings.Contains(content, "secret") || strings.Count(content, redacted) != 3 || !strings.Contains(content, "root") {
+		t.Fatalf("got %s", content)
+	}
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"igo/log"
+
+	"github.com/BurntSushi/toml"
+)
+
+//pollInterval the interval of the sources without push notify check the changes.
+var pollInterval = 2 * time.Second
+
+//Source a config source give the raw toml tree, the sources are merged as layers.
+type Source interface {
+	Load() (map[string]interface{}, error)
+	//Notify call fn when the config may be changed.
+	Notify(fn func()) error
+}
+
+//SourceFunc open the source of the url.
+type SourceFunc func(u *url.URL) (Source, error)
+
+var (
+	_sourceMu sync.Mutex
+	_sources  = map[string]SourceFunc{
+		"file":   newFileSource,
+		"zk":     newZKSource,
+		"etcd":   newEtcdSource,
+		"consul": newConsulSource,
+		"env":    newEnvSource,
+	}
+)
+
+//RegisterSource register the source of the url scheme.
+func RegisterSource(scheme string, fn SourceFunc) {
+	_sourceMu.Lock()
+	_sources[scheme] = fn
+	_sourceMu.Unlock()
+}
+
+//OpenSource open the source of the url, such as file:///etc/igo.toml, zk://host1:2181,host2:2181/igo,
+//etcd://host:2379/igo, consul://host:8500/igo and env://IGO.
+//The path without scheme is the toml file of the zk params or the whole config.
+func OpenSource(rawurl string) (Source, error) {
+	if !strings.Contains(rawurl, "://") {
+		return newSource(rawurl)
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	_sourceMu.Lock()
+	fn := _sources[u.Scheme]
+	_sourceMu.Unlock()
+	if fn == nil {
+		return nil, fmt.Errorf("unknown config source %q", u.Scheme)
+	}
+	return fn(u)
+}
+
+//OpenLayers open the sources of the urls, the keys of the later override the former.
+func OpenLayers(urls ...string) (Layers, error) {
+	var l Layers
+	for _, u := range urls {
+		s, err := OpenSource(u)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, s)
+	}
+	return l, nil
+}
+
+//Layers the sources merged in order, the later override the keys of the former.
+type Layers []Source
+
+var _ Configer = Layers{}
+var _ Watcher = Layers{}
+
+//Parse load and merge the sources, then parse and validate the config.
+func (l Layers) Parse() (*Config, error) {
+	c, _, err := l.parse()
+	return c, err
+}
+
+func (l Layers) parse() (*Config, string, error) {
+	tree := make(map[string]interface{})
+	for _, s := range l {
+		t, err := s.Load()
+		if err != nil {
+			return nil, "", err
+		}
+		if err := merge(tree, t); err != nil {
+			return nil, "", err
+		}
+	}
+	return decode(tree)
+}
+
+//Watch call fn with the new config when any source changed,
+//the config can not be parsed, invalid or fn returned error is logged and skipped.
+func (l Layers) Watch(fn func(*Config) error) error {
+	changed := make(chan struct{}, 1)
+	for _, s := range l {
+		err := s.Notify(func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, last, _ := l.parse()
+
+	go func() {
+		for range changed {
+			c, content, err := l.parse()
+			if err != nil {
+				log.Errorf("Reject config: %v", err)
+				continue
+			}
+			if content == last {
+				continue
+			}
+			if err := fn(c); err != nil {
+				log.Errorf("Reject config: %v", err)
+				continue
+			}
+			last = content
+		}
+	}()
+	return nil
+}
+
+//decode encode the merged tree to toml and parse the config of it.
+func decode(tree map[string]interface{}) (*Config, string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(tree); err != nil {
+		return nil, "", err
+	}
+	c := new(Config)
+	if _, err := toml.Decode(buf.String(), c); err != nil {
+		return nil, "", err
+	}
+	if err := c.resolveSecrets(); err != nil {
+		return nil, "", err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, "", err
+	}
+	return c, buf.String(), nil
+}
+
+//decodeTree parse the toml content to the raw tree.
+func decodeTree(content string) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	if _, err := toml.Decode(content, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+//merge override the keys of dst with src, the keys are case insensitive as the config fields.
+//The tables and array of tables are merged by key and index, the others are replaced.
+func merge(dst, src map[string]interface{}) error {
+	for k, v := range src {
+		key := k
+		for dk := range dst {
+			if strings.EqualFold(dk, k) {
+				key = dk
+				break
+			}
+		}
+		merged, err := mergeValue(dst[key], v)
+		if err != nil {
+			return fmt.Errorf("%v: %v", k, err)
+		}
+		dst[key] = merged
+	}
+	return nil
+}
+
+func mergeValue(dst, src interface{}) (interface{}, error) {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		switch d := dst.(type) {
+		case map[string]interface{}:
+			return d, merge(d, s)
+		case []map[string]interface{}:
+			return mergeTables(d, s)
+		case nil:
+			if indexed(s) {
+				return mergeTables(nil, s)
+			}
+			t := make(map[string]interface{})
+			return t, merge(t, s)
+		}
+	}
+	return src, nil
+}
+
+//indexed all keys of the table are the index of array.
+func indexed(t map[string]interface{}) bool {
+	for k := range t {
+		if _, err := strconv.Atoi(k); err != nil {
+			return false
+		}
+	}
+	return len(t) > 0
+}
+
+//mergeTables merge the table with index keys to the array of tables.
+func mergeTables(dst []map[string]interface{}, src map[string]interface{}) ([]map[string]interface{}, error) {
+	idx := make([]int, 0, len(src))
+	for k := range src {
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("bad index %q", k)
+		}
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	for _, i := range idx {
+		if i < 0 || i > len(dst) {
+			return nil, fmt.Errorf("bad index %d of %d tables", i, len(dst))
+		}
+		t, ok := src[strconv.Itoa(i)].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("index %d is not a table", i)
+		}
+		if i == len(dst) {
+			dst = append(dst, make(map[string]interface{}))
+		}
+		if err := merge(dst[i], t); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+[Server]
+listen = "127.0.0.1:6603"
+dbaddr = "127.0.0.1:3306"
+passwd = "root"
+maxClient = 100
+maxConnNum = 10
+
+[[Node]]
+name = "n1"
+dbaddr = "127.0.0.1:3307"
+`
+
+func Test_Layers(t *testing.T) {
+	f, err := ioutil.TempFile("", "igo_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testConfig)
+	f.Close()
+
+	env := map[string]string{
+		"IGOTEST_SERVER_PASSWD":    "123456",
+		"IGOTEST_SERVER_MAXCLIENT": "200",
+		"IGOTEST_SERVER_SLAVES":    `["127.0.0.1:3316"]`,
+		"IGOTEST_NODE_0_DBADDR":    "127.0.0.1:3317",
+		"IGOTEST_NODE_1_NAME":      "n2",
+		"IGOTEST_NODE_1_DBADDR":    "127.0.0.1:3308",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	l, err := OpenLayers("file://"+f.Name(), "env://igotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := l.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := c.Server
+	if s.Passwd != "123456" || s.MaxClient != 200 || len(s.Slaves) != 1 || s.Addr != "127.0.0.1:3306" {
+		t.Fatalf("server:%+v", s)
+	}
+	if len(c.Nodes) != 2 || c.Nodes[0].Name != "n1" || c.Nodes[0].Addr != "127.0.0.1:3317" || c.Nodes[1].Name != "n2" {
+		t.Fatalf("nodes:%+v", c.Nodes)
+	}
+
+	os.Setenv("IGOTEST_SERVER_MAXCONNNUM", "0")
+	defer os.Unsetenv("IGOTEST_SERVER_MAXCONNNUM")
+	if _, err := l.Parse(); err == nil {
+		t.Fatal("expect invalid config")
+	}
+
+	if _, err := OpenSource("redis://127.0.0.1/igo"); err == nil {
+		t.Fatal("expect unknown source")
+	}
+	s2, err := OpenSource("zk://h1:2181,h2:2181/igo/config?timeout=3")
+	if z, ok := s2.(*ZKConfig); err != nil || !ok || len(z.Addrs) != 2 || z.Path != "/igo/config" || z.Timeout != 3 {
+		t.Fatalf("zk source: %+v %v", s2, err)
+	}
+}
+
+func Test_ConsulSource(t *testing.T) {
+	index := 1
+	changed := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/igo/config" || r.Header.Get("X-Consul-Token") != "tk" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("index") != "" {
+			<-changed
+			index++
+		}
+		w.Header().Set("X-Consul-Index", fmt.Sprint(index))
+		w.Write([]byte(testConfig))
+	}))
+	defer srv.Close()
+
+	s, err := OpenSource("consul://" + strings.TrimPrefix(srv.URL, "http://") + "/igo/config?token=tk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Layers{s}.Parse()
+	if err != nil || c.Server.MaxClient != 100 {
+		t.Fatal(c, err)
+	}
+
+	notified := make(chan struct{}, 1)
+	if err := s.Notify(func() { notified <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	close(changed)
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not notified")
+	}
+}
+
+func Test_EtcdSource(t *testing.T) {
+	value := base64.StdEncoding.EncodeToString([]byte(testConfig))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			fmt.Fprintf(w, `{"header":{"revision":"2"},"kvs":[{"key":"L2lnbw==","value":%q}],"count":"1"}`, value)
+		case "/v3/watch":
+			fmt.Fprint(w, `{"result":{"created":true}}`)
+			w.(http.Flusher).Flush()
+			fmt.Fprintf(w, `{"result":{"events":[{"kv":{"key":"L2lnbw==","value":%q}}]}}`, value)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := OpenSource("etcd://" + strings.TrimPrefix(srv.URL, "http://") + "/igo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Layers{s}.Parse()
+	if err != nil || len(c.Nodes) != 1 {
+		t.Fatal(c, err)
+	}
+
+	notified := make(chan struct{}, 1)
+	s.Notify(func() {
+		select {
+		case notified <- struct{}{}:
+		default:
+		}
+	})
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not notified")
+	}
+}
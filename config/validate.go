@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+import (
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+//defaultNode the node name of ServerConfig dbaddr.
+const defaultNode = "default"
+
+//ValidateError all the problems of the config.
+type ValidateError []string
+
+func (e ValidateError) Error() string {
+	return "invalid config: " + strings.Join(e, "; ")
+}
+
+//Validate check the config can be applied, the error is ValidateError with every problem found.
+func (c *Config) Validate() error {
+	var errs ValidateError
+	addf := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	checkAddr := func(section, name, addr string) {
+		if addr == "" {
+			addf("%v: %v is not set", section, name)
+		} else if err := validAddr(addr); err != nil {
+			addf("%v: %v %q: %v", section, name, addr, err)
+		}
+	}
+
+	s := &c.Server
+	checkAddr("Server", "listen", s.Listen)
+	checkAddr("Server", "dbaddr", s.Addr)
+	for _, addr := range s.Slaves {
+		checkAddr("Server", "slaves", addr)
+	}
+	if s.AdminListen != "" {
+		checkAddr("Server", "adminListen", s.AdminListen)
+		if s.AdminUser == "" {
+			addf("Server: adminUser is not set for adminListen")
+		}
+	}
+	if s.HTTPListen != "" {
+		checkAddr("Server", "httpListen", s.HTTPListen)
+	}
+	if (s.HTTPCert == "") != (s.HTTPKey == "") {
+		addf("Server: httpCert and httpKey must be set together")
+	}
+	if s.HTTPClientCA != "" && s.HTTPCert == "" {
+		addf("Server: httpClientCA needs httpCert and httpKey")
+	}
+	if s.Collation != "" {
+		if _, ok := mysql.Collations[s.Collation]; !ok {
+			addf("Server: unknown collation %q", s.Collation)
+		}
+	}
+	switch s.Consistency {
+	case "", "session", "token":
+	default:
+		addf("Server: unknown consistency %q", s.Consistency)
+	}
+	switch s.AuditMode {
+	case "", "sql", "digest":
+	default:
+		addf("Server: unknown auditMode %q", s.AuditMode)
+	}
+	switch s.ResultLimit {
+	case "", "kill", "drain":
+	default:
+		addf("Server: unknown resultLimit %q", s.ResultLimit)
+	}
+	if s.MaxConnNum <= 0 {
+		addf("Server: maxConnNum %d must be > 0", s.MaxConnNum)
+	}
+	if s.MaxIdleConn < 0 || s.MaxIdleConn > s.MaxConnNum {
+		addf("Server: maxIdleConn %d must be in [0, maxConnNum %d]", s.MaxIdleConn, s.MaxConnNum)
+	}
+	for _, v := range []struct {
+		name string
+		v    int64
+	}{
+		{"maxClient", s.MaxClient},
+		{"maxClientWait", int64(s.MaxClientWait)},
+		{"readTimeout", int64(s.ReadTimeout)},
+		{"writeTimeout", int64(s.WriteTimeout)},
+		{"maxLifeTmie", int64(s.MaxLifeTime)},
+		{"gtidWait", int64(s.GTIDWait)},
+		{"slowTime", int64(s.SlowTime)},
+		{"maxExecTime", int64(s.MaxExecTime)},
+		{"maxResultRows", int64(s.MaxResultRows)},
+		{"maxResultBytes", int64(s.MaxResultBytes)},
+	} {
+		if v.v < 0 {
+			addf("Server: %v %d must be >= 0", v.name, v.v)
+		}
+	}
+
+	nodes := map[string]bool{defaultNode: true}
+	for i, n := range c.Nodes {
+		section := fmt.Sprintf("Node[%d] %q", i, n.Name)
+		switch {
+		case n.Name == "":
+			addf("%v: name is not set", section)
+		case nodes[n.Name]:
+			addf("%v: duplicate name", section)
+		}
+		nodes[n.Name] = true
+		checkAddr(section, "dbaddr", n.Addr)
+		for _, addr := range n.Slaves {
+			checkAddr(section, "slaves", addr)
+		}
+	}
+
+	tables := make(map[string]bool)
+	for i, sc := range c.Shards {
+		section := fmt.Sprintf("Shard[%d] %q", i, sc.Table)
+		table := strings.ToLower(sc.Table)
+		if sc.Table == "" || sc.Key == "" {
+			addf("%v: table and key are required", section)
+		} else if tables[table] {
+			addf("%v: duplicate table", section)
+		}
+		tables[table] = true
+
+		if len(sc.Nodes) == 0 {
+			addf("%v: nodes is empty", section)
+		}
+		for _, name := range sc.Nodes {
+			if !nodes[name] {
+				addf("%v: node %q not found", section, name)
+			}
+		}
+		for _, name := range sc.Lookup {
+			if !nodes[name] {
+				addf("%v: lookup node %q not found", section, name)
+			}
+		}
+		switch strings.ToLower(sc.Rule) {
+		case "hash":
+		case "range", "date":
+			if len(sc.Bounds) != len(sc.Nodes)-1 {
+				addf("%v: %v rule need %d bounds, got %d", section, sc.Rule, len(sc.Nodes)-1, len(sc.Bounds))
+			}
+			if _, err := sc.ParseBounds(); err != nil {
+				addf("%v: %v", section, err)
+			}
+		case "lookup":
+			if len(sc.Lookup) == 0 {
+				addf("%v: lookup rule need lookup table", section)
+			}
+		default:
+			addf("%v: unknown rule %q", section, sc.Rule)
+		}
+		switch strings.ToLower(sc.Policy) {
+		case "", "reject", "broadcast":
+		default:
+			addf("%v: unknown policy %q", section, sc.Policy)
+		}
+	}
+
+	users := make(map[string]bool)
+	for i, u := range c.Users {
+		switch {
+		case u.Name == "":
+			addf("User[%d]: name is not set", i)
+		case users[u.Name]:
+			addf("User[%d] %q: duplicate name", i, u.Name)
+		}
+		users[u.Name] = true
+		if u.MaxExecTime < 0 {
+			addf("User[%d] %q: maxExecTime %d must be >= 0", i, u.Name, u.MaxExecTime)
+		}
+		if u.MaxResultRows < 0 || u.MaxResultBytes < 0 {
+			addf("User[%d] %q: maxResultRows and maxResultBytes must be >= 0", i, u.Name)
+		}
+		for _, host := range u.Hosts {
+			if _, _, err := net.ParseCIDR(host); err != nil && net.ParseIP(host) == nil {
+				addf("User[%d] %q: invalid host %q", i, u.Name, host)
+			}
+		}
+	}
+
+	for i, r := range c.Rules {
+		section := fmt.Sprintf("Firewall[%d] %q", i, r.Name)
+		switch r.Action {
+		case "allow", "deny", "log", "learn":
+		default:
+			addf("%v: unknown action %q", section, r.Action)
+		}
+		if r.Action == "learn" && s.FirewallAllowlist == "" {
+			addf("%v: learn need Server firewallAllowlist", section)
+		}
+		for _, src := range r.Sources {
+			if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+				addf("%v: invalid source %q", section, src)
+			}
+		}
+		for _, t := range r.Types {
+			if parser.ParseStmtType(t) == parser.StmtUnknown {
+				addf("%v: unknown statement type %q", section, t)
+			}
+		}
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			addf("%v: regex: %v", section, err)
+		}
+	}
+
+	for i, l := range c.Limits {
+		section := fmt.Sprintf("RateLimit[%d] %q", i, l.Name)
+		switch l.Per {
+		case "", "user", "source", "digest":
+		default:
+			addf("%v: unknown per %q", section, l.Per)
+		}
+		if l.QPS < 0 || l.Burst < 0 || l.MaxConcurrent < 0 || l.Wait < 0 {
+			addf("%v: qps, burst, maxConcurrent and wait must be >= 0", section)
+		}
+		if l.QPS == 0 && l.MaxConcurrent == 0 {
+			addf("%v: qps or maxConcurrent is required", section)
+		}
+		for _, src := range l.Sources {
+			if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+				addf("%v: invalid source %q", section, src)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//validAddr check the addr is host:port.
+func validAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("bad port %q", port)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import "testing"
+
+func Test_Validate(t *testing.T) {
+	c := &Config{
+		Server: ServerConfig{Listen: "127.0.0.1:6603", Addr: "127.0.0.1:3306", Collation: "utf8mb4_general_ci", MaxIdleConn: 10, MaxConnNum: 100},
+		Nodes:  []NodeConfig{{Name: "n1", Addr: "127.0.0.1:3307"}},
+		Shards: []ShardConfig{{Table: "user", Key: "uid", Rule: "hash", Nodes: []string{"default", "n1"}}},
+		Users:  []UserConfig{{Name: "app"}},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ranged := *c
+	ranged.Shards = []ShardConfig{{Table: "user", Key: "uid", Rule: "date", Nodes: []string{"default", "n1", "default"},
+		Bounds: []string{"2020-01-01", "20210101"}}}
+	if err := ranged.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []func(c *Config){
+		func(c *Config) { c.Server.Addr = "" },
+		func(c *Config) { c.Server.Listen = "6603" },
+		func(c *Config) { c.Server.Slaves = []string{"127.0.0.1:99999"} },
+		func(c *Config) { c.Server.Collation = "utf9" },
+		func(c *Config) { c.Server.MaxIdleConn = 200 },
+		func(c *Config) { c.Server.MaxConnNum = 0 },
+		func(c *Config) { c.Server.Consistency = "strong" },
+		func(c *Config) { c.Server.SlowTime = -1 },
+		func(c *Config) { c.Server.MaxClientWait = -1 },
+		func(c *Config) { c.Server.MaxExecTime = -1 },
+		func(c *Config) { c.Users[0].MaxExecTime = -1 },
+		func(c *Config) { c.Server.MaxResultRows = -1 },
+		func(c *Config) { c.Server.ResultLimit = "stop" },
+		func(c *Config) { c.Users[0].MaxResultBytes = -1 },
+		func(c *Config) { c.Users[0].Hosts = []string{"localhost"} },
+		func(c *Config) { c.Server.AuditMode = "full" },
+		func(c *Config) { c.Server.AdminListen = "127.0.0.1:6604" },
+		func(c *Config) { c.Server.HTTPCert = "igo.pem" },
+		func(c *Config) { c.Server.HTTPClientCA = "ca.pem" },
+		func(c *Config) { c.Nodes = append(c.Nodes, NodeConfig{Name: "n1", Addr: "127.0.0.1:3308"}) },
+		func(c *Config) { c.Nodes[0].Name = "default" },
+		func(c *Config) { c.Shards[0].Nodes = []string{"n2"} },
+		func(c *Config) { c.Shards[0].Rule = "range" },
+		func(c *Config) { c.Shards[0].Rule, c.Shards[0].Bounds = "range", []string{"1k"} },
+		func(c *Config) {
+			c.Shards[0].Rule, c.Shards[0].Nodes, c.Shards[0].Bounds = "range", []string{"default", "n1", "default"}, []string{"100", "100"}
+		},
+		func(c *Config) { c.Shards[0].Rule, c.Shards[0].Bounds = "date", []string{"2020/01/01"} },
+		func(c *Config) {
+			c.Shards[0].Rule, c.Shards[0].Nodes, c.Shards[0].Bounds = "date", []string{"default", "n1", "default"}, []string{"2021-01-01", "20200101"}
+		},
+		func(c *Config) { c.Users = append(c.Users, UserConfig{Name: "app"}) },
+		func(c *Config) { c.Rules = []FirewallRule{{Name: "r", Action: "reject"}} },
+		func(c *Config) { c.Rules = []FirewallRule{{Name: "r", Action: "learn"}} },
+		func(c *Config) { c.Rules = []FirewallRule{{Name: "r", Action: "deny", Types: []string{"dropx"}}} },
+		func(c *Config) { c.Rules = []FirewallRule{{Name: "r", Action: "deny", Sources: []string{"10.0.0/8"}}} },
+		func(c *Config) { c.Rules = []FirewallRule{{Name: "r", Action: "deny", Regex: "("}} },
+		func(c *Config) { c.Limits = []RateLimit{{Name: "l"}} },
+		func(c *Config) { c.Limits = []RateLimit{{Name: "l", QPS: 10, Per: "table"}} },
+		func(c *Config) { c.Limits = []RateLimit{{Name: "l", MaxConcurrent: 10, Wait: -1}} },
+		func(c *Config) { c.Limits = []RateLimit{{Name: "l", QPS: 10, Sources: []string{"10.0.0.256"}}} },
+	} {
+		cc := *c
+		cc.Nodes = append([]NodeConfig(nil), c.Nodes...)
+		cc.Shards = []ShardConfig{c.Shards[0]}
+		cc.Users = append([]UserConfig(nil), c.Users...)
+		bad(&cc)
+		if err := cc.Validate(); err == nil {
+			t.Fatalf("expect error: %+v", cc)
+		}
+	}
+
+	//every problem is reported
+	c = &Config{
+		Server: ServerConfig{Listen: "127.0.0.1:6603", MaxIdleConn: 20, MaxConnNum: 10},
+		Users:  []UserConfig{{Name: "app"}, {Name: "app"}},
+	}
+	err := c.Validate()
+	if errs, ok := err.(ValidateError); !ok || len(errs) != 3 {
+		t.Fatalf("got %v", err)
+	}
+}
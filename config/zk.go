@@ -1,15 +1,21 @@
 package config
 
 import (
+	"fmt"
 	"igo/log"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"encoding/json"
 
-	"github.com/BurntSushi/toml"
 	"github.com/samuel/go-zookeeper/zk"
 )
 
+//watchRetry the interval to set the watch again after zk error.
+var watchRetry = time.Second
+
 //ZKConfig zk config
 type ZKConfig struct {
 	Addrs   []string `toml:"zk_addrs"`
@@ -25,13 +31,40 @@ type ZKConfig struct {
 			State       int    `json:"state"`
 		} `json:"metadata"`
 	}
+
+	cli *zk.Conn
 }
 
 var _ Configer = &ZKConfig{}
+var _ Source = &ZKConfig{}
+
+//zk://host1:2181,host2:2181/path?timeout=10
+func newZKSource(u *url.URL) (Source, error) {
+	z := &ZKConfig{Addrs: strings.Split(u.Host, ","), Path: u.Path, Timeout: 10}
+	if t := u.Query().Get("timeout"); t != "" {
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("zk timeout %q: %v", t, err)
+		}
+		z.Timeout = n
+	}
+	return z, nil
+}
 
 //Parse parse config from zk.
 func (z *ZKConfig) Parse() (*Config, error) {
-	cli, _, err := zk.Connect(z.Addrs, time.Duration(z.Timeout)*time.Second)
+	return Layers{z}.Parse()
+}
+
+//Watch watch the config node, fn is called with the new config after each change.
+//The config can not be parsed or invalid is logged and skipped.
+func (z *ZKConfig) Watch(fn func(*Config) error) error {
+	return Layers{z}.Watch(fn)
+}
+
+//Load load the raw config in the node.
+func (z *ZKConfig) Load() (map[string]interface{}, error) {
+	cli, err := z.connect()
 	if err != nil {
 		log.Error(err)
 		return nil, err
@@ -42,17 +75,76 @@ func (z *ZKConfig) Parse() (*Config, error) {
 		return nil, err
 	}
 	log.Infof("Load ZK Config: %+v", z.Path)
+	return z.decode(data)
+}
 
-	if err := json.Unmarshal(data, &z.Data); err != nil {
+//Notify watch the config node.
+func (z *ZKConfig) Notify(fn func()) error {
+	cli, err := z.connect()
+	if err != nil {
+		return err
+	}
+	_, _, ev, err := cli.GetW(z.Path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			e := <-ev
+			log.Infof("ZK Config event: %v, path: %v", e.Type, z.Path)
+			if ev = z.rewatch(cli); e.Type != zk.EventNodeDeleted {
+				fn()
+			}
+		}
+	}()
+	return nil
+}
+
+//rewatch set the watch again, it wait for the node created if deleted.
+func (z *ZKConfig) rewatch(cli *zk.Conn) <-chan zk.Event {
+	for {
+		_, _, ev, err := cli.GetW(z.Path)
+		if err == nil {
+			return ev
+		}
+		if err == zk.ErrNoNode {
+			var ok bool
+			if ok, _, ev, err = cli.ExistsW(z.Path); err == nil && !ok {
+				log.Warnf("ZK Config node deleted: %v", z.Path)
+				return ev
+			}
+		}
 		log.Error(err)
+		time.Sleep(watchRetry)
+	}
+}
+
+//Close close the zk connection.
+func (z *ZKConfig) Close() {
+	if z.cli != nil {
+		z.cli.Close()
+		z.cli = nil
+	}
+}
+
+func (z *ZKConfig) connect() (*zk.Conn, error) {
+	if z.cli != nil {
+		return z.cli, nil
+	}
+	cli, _, err := zk.Connect(z.Addrs, time.Duration(z.Timeout)*time.Second)
+	if err != nil {
 		return nil, err
 	}
-	log.Debugf("\n%v", string(z.Data.Content))
+	z.cli = cli
+	return cli, nil
+}
 
-	c := new(Config)
-	if err := toml.Unmarshal([]byte(z.Data.Content), c); err != nil {
-		log.Error(err)
+//decode parse the json payload of the node and the toml content in it.
+func (z *ZKConfig) decode(data []byte) (map[string]interface{}, error) {
+	if err := json.Unmarshal(data, &z.Data); err != nil {
 		return nil, err
 	}
-	return c, nil
+	log.Debugf("\n%v", Redact(z.Data.Content))
+	return decodeTree(z.Data.Content)
 }
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+//AuditLogWriter append the audit records to the file as JSON Lines, it rotates like the FileLogWriter.
+//The records are written synchronously and the write error is returned to the caller,
+//nothing is buffered in a channel to be dropped when it is full.
+type AuditLogWriter struct {
+	*FileLogWriter
+}
+
+//NewAuditWriter create an AuditLogWriter returning as LoggerInterface.
+func NewAuditWriter() LoggerInterface {
+	w := NewFileWriter().(*FileLogWriter)
+	w.Logger.SetFlags(0)
+	return &AuditLogWriter{w}
+}
+
+//WriteMsg write the record line into file, the level is ignored.
+func (w *AuditLogWriter) WriteMsg(msg string, level int) error {
+	w.docheck(len(msg) + 1)
+	return w.Logger.Output(0, msg)
+}
+
+//AuditRecord a statement passed through igo.
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	ConnID       uint32    `json:"conn_id"`
+	User         string    `json:"user"`
+	ClientIP     string    `json:"client_ip"`
+	Schema       string    `json:"schema"`
+	SQL          string    `json:"sql,omitempty"`
+	Digest       string    `json:"digest,omitempty"`
+	AffectedRows uint64    `json:"affected_rows"`
+	ErrorCode    uint16    `json:"error_code"`
+}
+
+//the audit logger, replaced wholesale by SetAuditLogger.
+var (
+	_auditMu     sync.RWMutex
+	_auditLogger *SimpleLogger
+)
+
+var errNoAuditLogger = errors.New("audit logger is not set")
+
+//SetAuditLogger set the adapter of the audit log, such as
+//SetAuditLogger("audit", `{"filename":"logs/audit.log"}`), the adapter set before is closed.
+func SetAuditLogger(adaptername string, config string) error {
+	l := NewLogger(0)
+	if err := l.SetLogger(adaptername, config); err != nil {
+		return err
+	}
+	_auditMu.Lock()
+	old := _auditLogger
+	_auditLogger = l
+	_auditMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+//DelAuditLogger stop the audit log.
+func DelAuditLogger() {
+	_auditMu.Lock()
+	old := _auditLogger
+	_auditLogger = nil
+	_auditMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+//Audit write the record, the error is returned if it is not written.
+func Audit(r *AuditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_auditMu.RLock()
+	defer _auditMu.RUnlock()
+	if _auditLogger == nil {
+		return errNoAuditLogger
+	}
+	return _auditLogger.writerMsg(LevelInformational, string(line))
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	if err := Audit(&AuditRecord{}); err == nil {
+		t.Fatal("expect error without audit logger")
+	}
+	if err := SetAuditLogger("audit", `{"filename":"audit.log","maxlines":2}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("audit.log")
+	defer DelAuditLogger()
+
+	for i := 0; i < 3; i++ {
+		if err := Audit(&AuditRecord{Time: time.Now(), ConnID: uint32(i), SQL: "select\n1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile("audit.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//rotated after 2 lines
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var r AuditRecord
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &r) != nil || r.ConnID != 2 || r.SQL != "select\n1" {
+		t.Fatalf("%q", data)
+	}
+	rotated, _ := ioutil.ReadDir(".")
+	for _, f := range rotated {
+		if strings.HasPrefix(f.Name(), "audit.log.") {
+			os.Remove(f.Name())
+		}
+	}
+}
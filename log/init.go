@@ -1,6 +1,9 @@
 package log
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 var _logger *SimpleLogger
 
@@ -10,6 +13,8 @@ func init() {
 	Register("console", NewConsole)
 	Register("file", NewFileWriter)
 	Register("nsq", NewNsqWriter)
+	Register("slowfile", NewSlowWriter)
+	Register("audit", NewAuditWriter)
 
 	//console logger
 	_logger = NewLogger(10000)
@@ -29,6 +34,37 @@ func SetLevel(l int) {
 	_logger.SetLevel(l)
 }
 
+// GetLevel returns the global log level.
+func GetLevel() int {
+	return _logger.level
+}
+
+var levelNames = []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+
+// LevelName returns the name of the level, such as "info".
+func LevelName(l int) string {
+	if l < 0 || l >= len(levelNames) {
+		return fmt.Sprint(l)
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the level name, "warn" and "trace" are accepted as the aliases.
+func ParseLevel(name string) (int, error) {
+	switch name = strings.ToLower(name); name {
+	case "warn":
+		return LevelWarn, nil
+	case "trace":
+		return LevelTrace, nil
+	}
+	for l, n := range levelNames {
+		if n == name {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
 func SetLogFuncCall(b bool) {
 	_logger.EnableFuncCallDepth(b)
 	_logger.SetLogFuncCallDepth(3)
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SlowLogWriter write the slow query entries to the file as is, without the date and level prefix.
+//It rotates like the FileLogWriter, an entry is never split between two files.
+type SlowLogWriter struct {
+	*FileLogWriter
+}
+
+//NewSlowWriter create a SlowLogWriter returning as LoggerInterface.
+func NewSlowWriter() LoggerInterface {
+	w := NewFileWriter().(*FileLogWriter)
+	w.Logger.SetFlags(0)
+	return &SlowLogWriter{w}
+}
+
+//WriteMsg write the entry into file, the level is ignored.
+func (w *SlowLogWriter) WriteMsg(msg string, level int) error {
+	w.docheck(len(msg) + 1)
+	w.Logger.Println(msg)
+	return nil
+}
+
+//SlowEntry a slow command of the client.
+type SlowEntry struct {
+	Time         time.Time //the time the command started
+	Duration     time.Duration
+	ID           uint32 //the connection id
+	User         string
+	Host         string //the client ip
+	Schema       string
+	Backend      string //the mysql addrs executed the command
+	RowsSent     uint64
+	RowsAffected uint64
+	BytesSent    uint64
+	Query        string //the statement, or "# administrator command: Xxx" for the other commands
+}
+
+//String format the entry as the mysql slow log, which pt-query-digest can parse.
+func (e *SlowEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Time: %s\n", e.Time.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	fmt.Fprintf(&b, "# User@Host: %s[%s] @ %s [%s]  Id: %d\n", e.User, e.User, e.Host, e.Host, e.ID)
+	var attrs []string
+	if e.Schema != "" {
+		attrs = append(attrs, "Schema: "+e.Schema)
+	}
+	if e.Backend != "" {
+		attrs = append(attrs, "Backend: "+e.Backend)
+	}
+	if len(attrs) > 0 {
+		fmt.Fprintf(&b, "# %s\n", strings.Join(attrs, "  "))
+	}
+	fmt.Fprintf(&b, "# Query_time: %.6f  Lock_time: 0.000000  Rows_sent: %d  Rows_examined: 0  Rows_affected: %d  Bytes_sent: %d\n",
+		e.Duration.Seconds(), e.RowsSent, e.RowsAffected, e.BytesSent)
+	fmt.Fprintf(&b, "SET timestamp=%d;\n", e.Time.Unix())
+	b.WriteString(strings.TrimRight(strings.TrimSpace(e.Query), ";"))
+	b.WriteString(";")
+	return b.String()
+}
+
+//the slow query logger, replaced wholesale by SetSlowLogger.
+var (
+	_slowMu     sync.RWMutex
+	_slowLogger *SimpleLogger
+)
+
+//SetSlowLogger set the adapter of the slow query log, such as
+//SetSlowLogger("slowfile", `{"filename":"logs/slow.log"}`), the adapter set before is closed.
+func SetSlowLogger(adaptername string, config string) error {
+	l := NewLogger(0)
+	if err := l.SetLogger(adaptername, config); err != nil {
+		return err
+	}
+	_slowMu.Lock()
+	old := _slowLogger
+	_slowLogger = l
+	_slowMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+//DelSlowLogger stop the slow query log.
+func DelSlowLogger() {
+	_slowMu.Lock()
+	old := _slowLogger
+	_slowLogger = nil
+	_slowMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+//Slow write the slow query entry, it is dropped if no slow logger set.
+func Slow(e *SlowEntry) {
+	_slowMu.RLock()
+	defer _slowMu.RUnlock()
+	if _slowLogger != nil {
+		_slowLogger.writerMsg(LevelInformational, e.String())
+	}
+}
@@ -0,0 +1,342 @@
+//Package metrics the counters, gauges and histograms exposed in the prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//DefBuckets the default latency buckets in seconds.
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+//the metric types
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+//metric write the samples of the metric.
+type metric interface {
+	describe() *desc
+	write(w io.Writer)
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) describe() *desc { return d }
+
+var (
+	_mu      sync.Mutex
+	_metrics = make(map[string]metric)
+)
+
+//register register the metric, the one with the same name is replaced.
+func register(m metric) {
+	_mu.Lock()
+	_metrics[m.describe().name] = m
+	_mu.Unlock()
+}
+
+//Unregister remove the metric of the name.
+func Unregister(name string) {
+	_mu.Lock()
+	delete(_metrics, name)
+	_mu.Unlock()
+}
+
+//WriteTo write all the metrics sorted by name.
+func WriteTo(w io.Writer) {
+	_mu.Lock()
+	ms := make([]metric, 0, len(_metrics))
+	for _, m := range _metrics {
+		ms = append(ms, m)
+	}
+	_mu.Unlock()
+	sort.Slice(ms, func(i, j int) bool { return ms[i].describe().name < ms[j].describe().name })
+
+	for _, m := range ms {
+		d := m.describe()
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+		m.write(w)
+	}
+}
+
+//Handler the http handler of /metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		WriteTo(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+//Value a float64 updated atomically.
+type Value struct {
+	bits uint64
+}
+
+//Add add delta to the value.
+func (v *Value) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		n := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, n) {
+			return
+		}
+	}
+}
+
+//Inc add 1 to the value.
+func (v *Value) Inc() { v.Add(1) }
+
+//Set set the value.
+func (v *Value) Set(f float64) { atomic.StoreUint64(&v.bits, math.Float64bits(f)) }
+
+//Get get the value.
+func (v *Value) Get() float64 { return math.Float64frombits(atomic.LoadUint64(&v.bits)) }
+
+//vec the children of the label values.
+type vec struct {
+	*desc
+	mu       sync.Mutex
+	children map[string]interface{}
+	values   map[string][]string
+}
+
+func newVec(name, help, typ string, labels []string) *vec {
+	return &vec{
+		desc:     &desc{name: name, help: help, typ: typ, labels: labels},
+		children: make(map[string]interface{}),
+		values:   make(map[string][]string),
+	}
+}
+
+func (v *vec) with(values []string, create func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics %v: %d label values for %d labels", v.name, len(values), len(v.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.children[key]
+	if !ok {
+		c = create()
+		v.children[key] = c
+		v.values[key] = append([]string(nil), values...)
+	}
+	return c
+}
+
+//each call fn with the children sorted by the label values.
+func (v *vec) each(fn func(values []string, child interface{})) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := make([]interface{}, len(keys))
+	values := make([][]string, len(keys))
+	for i, k := range keys {
+		children[i], values[i] = v.children[k], v.values[k]
+	}
+	v.mu.Unlock()
+	for i := range keys {
+		fn(values[i], children[i])
+	}
+}
+
+//Reset remove all the children.
+func (v *vec) Reset() {
+	v.mu.Lock()
+	v.children = make(map[string]interface{})
+	v.values = make(map[string][]string)
+	v.mu.Unlock()
+}
+
+//CounterVec the counters of the label values.
+type CounterVec struct {
+	*vec
+}
+
+//NewCounterVec new and register the counter.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, typeCounter, labels)}
+	register(c)
+	return c
+}
+
+//With get the counter of the label values, keep it to avoid the lookup in the hot path.
+func (c *CounterVec) With(values ...string) *Value {
+	return c.with(values, func() interface{} { return new(Value) }).(*Value)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.each(func(values []string, child interface{}) {
+		writeSample(w, c.name, c.labels, values, "", "", child.(*Value).Get())
+	})
+}
+
+//GaugeVec the gauges of the label values.
+type GaugeVec struct {
+	*vec
+}
+
+//NewGaugeVec new and register the gauge.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, typeGauge, labels)}
+	register(g)
+	return g
+}
+
+//With get the gauge of the label values.
+func (g *GaugeVec) With(values ...string) *Value {
+	return g.with(values, func() interface{} { return new(Value) }).(*Value)
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.each(func(values []string, child interface{}) {
+		writeSample(w, g.name, g.labels, values, "", "", child.(*Value).Get())
+	})
+}
+
+//Histogram the observations counted in the buckets.
+type Histogram struct {
+	upper  []float64
+	counts []uint64 //atomic
+	count  uint64   //atomic
+	sum    Value
+}
+
+//Observe add the observation.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upper, v)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	h.sum.Add(v)
+}
+
+//HistogramVec the histograms of the label values.
+type HistogramVec struct {
+	*vec
+	buckets []float64
+}
+
+//NewHistogramVec new and register the histogram, the buckets are the ascending upper bounds.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{newVec(name, help, typeHistogram, labels), buckets}
+	register(h)
+	return h
+}
+
+//With get the histogram of the label values.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values, func() interface{} {
+		return &Histogram{upper: h.buckets, counts: make([]uint64, len(h.buckets))}
+	}).(*Histogram)
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.each(func(values []string, child interface{}) {
+		hist := child.(*Histogram)
+		var cum uint64
+		for i, upper := range hist.upper {
+			cum += atomic.LoadUint64(&hist.counts[i])
+			writeSample(w, h.name+"_bucket", h.labels, values, "le", formatFloat(upper), float64(cum))
+		}
+		count := atomic.LoadUint64(&hist.count)
+		writeSample(w, h.name+"_bucket", h.labels, values, "le", "+Inf", float64(count))
+		writeSample(w, h.name+"_sum", h.labels, values, "", "", hist.sum.Get())
+		writeSample(w, h.name+"_count", h.labels, values, "", "", float64(count))
+	})
+}
+
+//CollectFunc emit the samples when scraped.
+type CollectFunc func(emit func(value float64, values ...string))
+
+type funcMetric struct {
+	*desc
+	fn CollectFunc
+}
+
+//NewGaugeFunc register the gauges collected by fn when scraped.
+func NewGaugeFunc(name, help string, labels []string, fn CollectFunc) {
+	register(&funcMetric{&desc{name: name, help: help, typ: typeGauge, labels: labels}, fn})
+}
+
+//NewCounterFunc register the counters collected by fn when scraped.
+func NewCounterFunc(name, help string, labels []string, fn CollectFunc) {
+	register(&funcMetric{&desc{name: name, help: help, typ: typeCounter, labels: labels}, fn})
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	f.fn(func(value float64, values ...string) {
+		writeSample(w, f.name, f.labels, values, "", "", value)
+	})
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	var buf bytes.Buffer
+	buf.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		buf.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			var value string
+			if i < len(values) {
+				value = values[i]
+			}
+			fmt.Fprintf(&buf, `%s="%s"`, l, escapeLabel(value))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, `%s="%s"`, extraLabel, extraValue)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(v))
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_WriteTo(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "The requests.", "code", "path")
+	c.With("200", `/a"b`).Inc()
+	c.With("200", `/a"b`).Add(2)
+	c.With("500", "/").Inc()
+	g := NewGaugeVec("test_temperature", "The\ntemperature.")
+	g.With().Set(-1.5)
+	h := NewHistogramVec("test_latency_seconds", "The latency.", []float64{0.1, 1}, "cmd")
+	h.With("query").Observe(0.05)
+	h.With("query").Observe(0.1)
+	h.With("query").Observe(3)
+	NewGaugeFunc("test_pool", "The pool.", []string{"state"}, func(emit func(float64, ...string)) {
+		emit(3, "open")
+	})
+	defer func() {
+		for _, name := range []string{"test_requests_total", "test_temperature", "test_latency_seconds", "test_pool"} {
+			Unregister(name)
+		}
+	}()
+
+	var buf bytes.Buffer
+	WriteTo(&buf)
+	want := `# HELP test_latency_seconds The latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{cmd="query",le="0.1"} 2
+test_latency_seconds_bucket{cmd="query",le="1"} 2
+test_latency_seconds_bucket{cmd="query",le="+Inf"} 3
+test_latency_seconds_sum{cmd="query"} 3.15
+test_latency_seconds_count{cmd="query"} 3
+# HELP test_pool The pool.
+# TYPE test_pool gauge
+test_pool{state="open"} 3
+# HELP test_requests_total The requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200",path="/a\"b"} 3
+test_requests_total{code="500",path="/"} 1
+# HELP test_temperature The\ntemperature.
+# TYPE test_temperature gauge
+test_temperature -1.5
+`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Fatalf("got:\n%s", got)
+	}
+}
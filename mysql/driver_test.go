@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"testing"
+)
+
+//dsn the data source of the tests with a server, the driver tests are kept from go-sql-driver.
+var dsn = "root@tcp(127.0.0.1:3306)/test?strict=true"
+
+//DBTest the test with a database connection.
+type DBTest struct {
+	*testing.T
+}
+
+func (dbt *DBTest) mustExec(query string, args ...interface{}) {
+	dbt.Fatalf("exec %q: no database/sql driver", query)
+}
+
+//runTests skip the tests, the package is not the database/sql driver to connect the server.
+func runTests(t *testing.T, dsn string, tests ...func(dbt *DBTest)) {
+	t.Skipf("no database/sql driver to connect %v", dsn)
+}
@@ -0,0 +1,511 @@
+package parser
+
+//Statement the parsed statement.
+type Statement interface {
+	Type() StmtType
+}
+
+//Expr the expression node.
+type Expr interface {
+	expr()
+}
+
+//TableRef the table reference in FROM, UPDATE and DELETE.
+type TableRef interface {
+	tableRef()
+}
+
+//TableName the [schema.]table name.
+type TableName struct {
+	Schema string
+	Name   string
+}
+
+//AliasedTable the table with optional alias.
+type AliasedTable struct {
+	Table *TableName
+	Alias string
+}
+
+//SubqueryTable the derived table `(SELECT ...) AS alias`.
+type SubqueryTable struct {
+	Select Statement
+	Alias  string
+}
+
+//Join the joined tables.
+type Join struct {
+	Kind  string //JOIN, LEFT JOIN, RIGHT JOIN, CROSS JOIN, NATURAL JOIN, STRAIGHT_JOIN
+	Left  TableRef
+	Right TableRef
+	On    Expr
+	Using []string
+}
+
+//ParenTable the parenthesized table references.
+type ParenTable struct {
+	Tables []TableRef
+}
+
+func (*AliasedTable) tableRef()  {}
+func (*SubqueryTable) tableRef() {}
+func (*Join) tableRef()          {}
+func (*ParenTable) tableRef()    {}
+
+//SelectField the item of the select list.
+type SelectField struct {
+	Star  bool   //* or table.*
+	Table string //the qualifier of table.*
+	Expr  Expr
+	Alias string
+}
+
+//OrderItem the item of ORDER BY.
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+//Limit the LIMIT clause, Pos and End is the offset of it in the sql.
+type Limit struct {
+	Offset Expr
+	Count  Expr
+	Pos    int
+	End    int
+}
+
+//Select the SELECT statement.
+type Select struct {
+	Distinct bool
+	Fields   []*SelectField
+	From     []TableRef
+	Where    Expr
+	GroupBy  []Expr
+	Rollup   bool
+	Having   Expr
+	OrderBy  []*OrderItem
+	Limit    *Limit
+	Lock     string //FOR UPDATE, FOR SHARE, LOCK IN SHARE MODE
+	//LimitAt the offset in the sql where a LIMIT clause can be inserted.
+	LimitAt int
+}
+
+//Union the UNION of selects, the ORDER BY and LIMIT apply to the result.
+type Union struct {
+	Selects []*Select
+	All     []bool //All[i] is the UNION ALL before Selects[i+1]
+	OrderBy []*OrderItem
+	Limit   *Limit
+}
+
+//Assignment the `col = expr` in SET and ON DUPLICATE KEY UPDATE.
+type Assignment struct {
+	Column *ColumnRef
+	Value  Expr
+}
+
+//Insert the INSERT and REPLACE statement.
+type Insert struct {
+	Replace  bool
+	Ignore   bool
+	Table    *TableName
+	Columns  []string
+	Rows     [][]Expr
+	Set      []*Assignment //INSERT ... SET
+	Select   Statement     //INSERT ... SELECT
+	OnDup    []*Assignment
+	Priority string
+}
+
+//Update the UPDATE statement.
+type Update struct {
+	Ignore  bool
+	Tables  []TableRef
+	Set     []*Assignment
+	Where   Expr
+	OrderBy []*OrderItem
+	Limit   *Limit
+}
+
+//Delete the DELETE statement, Targets is the deleted tables of multiple table syntax.
+type Delete struct {
+	Ignore  bool
+	Targets []*TableName
+	From    []TableRef
+	Where   Expr
+	OrderBy []*OrderItem
+	Limit   *Limit
+}
+
+//DDL the CREATE, ALTER, DROP, TRUNCATE and RENAME statement,
+//only the object and names are parsed, the definitions are skipped.
+type DDL struct {
+	Action      StmtType
+	Object      string //TABLE, DATABASE, INDEX, VIEW...
+	Tables      []*TableName
+	Name        string //the database, index or other object name
+	IfExists    bool
+	IfNotExists bool
+}
+
+//SetVar the variable assignment of SET.
+type SetVar struct {
+	Scope string //GLOBAL, SESSION, USER for @var, empty for the default
+	Name  string //lower case, names and charset for SET NAMES and SET CHARACTER SET
+	Value Expr
+}
+
+//Set the SET statement, SET [GLOBAL|SESSION] TRANSACTION is the variable transaction
+//with the upper case characteristics, such as ISOLATION LEVEL READ COMMITTED.
+type Set struct {
+	Vars []*SetVar
+}
+
+//Use the USE statement.
+type Use struct {
+	DB string
+}
+
+//Show the SHOW statement.
+type Show struct {
+	Full  bool
+	Scope string //GLOBAL or SESSION
+	Kind  string //upper case words, such as PROCESSLIST, VARIABLES, CREATE TABLE
+	Table *TableName
+	From  string
+	Like  string
+	Where Expr
+}
+
+//Transaction the BEGIN, START TRANSACTION, COMMIT and ROLLBACK statement.
+type Transaction struct {
+	Action StmtType
+}
+
+//Kill the KILL [CONNECTION | QUERY] statement.
+type Kill struct {
+	Query bool
+	ID    uint64
+}
+
+//Other the statement not parsed by the parser.
+type Other struct {
+	StmtType StmtType
+}
+
+//Type implement Statement
+func (*Select) Type() StmtType { return StmtSelect }
+
+//Type implement Statement
+func (*Union) Type() StmtType { return StmtSelect }
+
+//Type implement Statement
+func (s *Insert) Type() StmtType {
+	if s.Replace {
+		return StmtReplace
+	}
+	return StmtInsert
+}
+
+//Type implement Statement
+func (*Update) Type() StmtType { return StmtUpdate }
+
+//Type implement Statement
+func (*Delete) Type() StmtType { return StmtDelete }
+
+//Type implement Statement
+func (s *DDL) Type() StmtType { return s.Action }
+
+//Type implement Statement
+func (*Set) Type() StmtType { return StmtSet }
+
+//Type implement Statement
+func (*Use) Type() StmtType { return StmtUse }
+
+//Type implement Statement
+func (*Show) Type() StmtType { return StmtShow }
+
+//Type implement Statement
+func (s *Transaction) Type() StmtType { return s.Action }
+
+//Type implement Statement
+func (*Kill) Type() StmtType { return StmtKill }
+
+//Type implement Statement
+func (s *Other) Type() StmtType { return s.StmtType }
+
+//LiteralKind the kind of literal.
+type LiteralKind int
+
+//literal kinds
+const (
+	LitNumber LiteralKind = iota
+	LitString
+	LitNull
+	LitBool
+	LitHex
+)
+
+//Literal the constant value, Val is the unescaped string or the raw number.
+type Literal struct {
+	Kind LiteralKind
+	Val  string
+}
+
+//Param the ? placeholder.
+type Param struct {
+	Index int
+}
+
+//Variable the @user_var or @@system_var, Name include the @.
+type Variable struct {
+	Name string
+}
+
+//ColumnRef the [[schema.]table.]column.
+type ColumnRef struct {
+	Schema string
+	Table  string
+	Name   string
+}
+
+//FuncCall the function call, COUNT(*) has Star.
+type FuncCall struct {
+	Name     string //upper case
+	Distinct bool
+	Star     bool
+	Args     []Expr
+}
+
+//BinaryExpr the binary operation, Op is upper case, such as AND, =, LIKE, NOT LIKE.
+type BinaryExpr struct {
+	Op string
+	L  Expr
+	R  Expr
+}
+
+//UnaryExpr the unary operation, such as NOT, -, ~, BINARY.
+type UnaryExpr struct {
+	Op string
+	X  Expr
+}
+
+//InExpr the `x [NOT] IN (list)` or `x [NOT] IN (subquery)`.
+type InExpr struct {
+	X      Expr
+	Not    bool
+	List   []Expr
+	Select Statement
+}
+
+//BetweenExpr the `x [NOT] BETWEEN low AND high`.
+type BetweenExpr struct {
+	X    Expr
+	Not  bool
+	Low  Expr
+	High Expr
+}
+
+//IsExpr the `x IS [NOT] NULL|TRUE|FALSE|UNKNOWN`.
+type IsExpr struct {
+	X    Expr
+	Not  bool
+	What string
+}
+
+//Subquery the (SELECT ...) used as a value.
+type Subquery struct {
+	Select Statement
+}
+
+//ExistsExpr the EXISTS (SELECT ...).
+type ExistsExpr struct {
+	Select Statement
+}
+
+//When the WHEN ... THEN ... of CASE.
+type When struct {
+	Cond   Expr
+	Result Expr
+}
+
+//CaseExpr the CASE [value] WHEN ... END.
+type CaseExpr struct {
+	Value Expr
+	Whens []*When
+	Else  Expr
+}
+
+//RowExpr the (a, b, ...) row constructor.
+type RowExpr struct {
+	Items []Expr
+}
+
+//IntervalExpr the INTERVAL expr unit.
+type IntervalExpr struct {
+	X    Expr
+	Unit string
+}
+
+//CastExpr the CAST(x AS type), CONVERT(x, type) and CONVERT(x USING charset).
+type CastExpr struct {
+	X    Expr
+	Type string //the upper case type words, or USING charset
+}
+
+//DefaultExpr the DEFAULT in VALUES and SET.
+type DefaultExpr struct{}
+
+func (*Literal) expr()      {}
+func (*Param) expr()        {}
+func (*Variable) expr()     {}
+func (*ColumnRef) expr()    {}
+func (*FuncCall) expr()     {}
+func (*BinaryExpr) expr()   {}
+func (*UnaryExpr) expr()    {}
+func (*InExpr) expr()       {}
+func (*BetweenExpr) expr()  {}
+func (*IsExpr) expr()       {}
+func (*Subquery) expr()     {}
+func (*ExistsExpr) expr()   {}
+func (*CaseExpr) expr()     {}
+func (*RowExpr) expr()      {}
+func (*IntervalExpr) expr() {}
+func (*CastExpr) expr()     {}
+func (*DefaultExpr) expr()  {}
+
+//Tables return all the table names the statement access, include the sub queries.
+func Tables(stmt Statement) []*TableName {
+	var v tableVisitor
+	v.stmt(stmt)
+	return v.tables
+}
+
+type tableVisitor struct {
+	tables []*TableName
+}
+
+func (v *tableVisitor) add(t *TableName) {
+	if t != nil {
+		v.tables = append(v.tables, t)
+	}
+}
+
+func (v *tableVisitor) stmt(stmt Statement) {
+	switch s := stmt.(type) {
+	case *Select:
+		for _, f := range s.Fields {
+			v.expr(f.Expr)
+		}
+		v.refs(s.From)
+		v.expr(s.Where)
+		v.exprs(s.GroupBy)
+		v.expr(s.Having)
+		v.orders(s.OrderBy)
+	case *Union:
+		for _, sel := range s.Selects {
+			v.stmt(sel)
+		}
+	case *Insert:
+		v.add(s.Table)
+		for _, row := range s.Rows {
+			v.exprs(row)
+		}
+		v.assignments(s.Set)
+		if s.Select != nil {
+			v.stmt(s.Select)
+		}
+		v.assignments(s.OnDup)
+	case *Update:
+		v.refs(s.Tables)
+		v.assignments(s.Set)
+		v.expr(s.Where)
+		v.orders(s.OrderBy)
+	case *Delete:
+		for _, t := range s.Targets {
+			v.add(t)
+		}
+		v.refs(s.From)
+		v.expr(s.Where)
+		v.orders(s.OrderBy)
+	case *DDL:
+		for _, t := range s.Tables {
+			v.add(t)
+		}
+	case *Show:
+		v.add(s.Table)
+	}
+}
+
+func (v *tableVisitor) refs(refs []TableRef) {
+	for _, ref := range refs {
+		switch r := ref.(type) {
+		case *AliasedTable:
+			v.add(r.Table)
+		case *SubqueryTable:
+			v.stmt(r.Select)
+		case *Join:
+			v.refs([]TableRef{r.Left, r.Right})
+			v.expr(r.On)
+		case *ParenTable:
+			v.refs(r.Tables)
+		}
+	}
+}
+
+func (v *tableVisitor) assignments(list []*Assignment) {
+	for _, a := range list {
+		v.expr(a.Value)
+	}
+}
+
+func (v *tableVisitor) orders(list []*OrderItem) {
+	for _, o := range list {
+		v.expr(o.Expr)
+	}
+}
+
+func (v *tableVisitor) exprs(list []Expr) {
+	for _, e := range list {
+		v.expr(e)
+	}
+}
+
+func (v *tableVisitor) expr(e Expr) {
+	switch x := e.(type) {
+	case *FuncCall:
+		v.exprs(x.Args)
+	case *BinaryExpr:
+		v.expr(x.L)
+		v.expr(x.R)
+	case *UnaryExpr:
+		v.expr(x.X)
+	case *InExpr:
+		v.expr(x.X)
+		v.exprs(x.List)
+		if x.Select != nil {
+			v.stmt(x.Select)
+		}
+	case *BetweenExpr:
+		v.exprs([]Expr{x.X, x.Low, x.High})
+	case *IsExpr:
+		v.expr(x.X)
+	case *Subquery:
+		v.stmt(x.Select)
+	case *ExistsExpr:
+		v.stmt(x.Select)
+	case *CaseExpr:
+		v.expr(x.Value)
+		for _, w := range x.Whens {
+			v.expr(w.Cond)
+			v.expr(w.Result)
+		}
+		v.expr(x.Else)
+	case *RowExpr:
+		v.exprs(x.Items)
+	case *IntervalExpr:
+		v.expr(x.X)
+	case *CastExpr:
+		v.expr(x.X)
+	}
+}
@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"strings"
+)
+
+//StmtType the type of the statement.
+type StmtType int
+
+//statement types
+const (
+	StmtUnknown StmtType = iota
+	StmtSelect
+	StmtInsert
+	StmtReplace
+	StmtUpdate
+	StmtDelete
+	StmtLoad
+	StmtCall
+	StmtCreate
+	StmtAlter
+	StmtDrop
+	StmtTruncate
+	StmtRename
+	StmtSet
+	StmtUse
+	StmtShow
+	StmtExplain
+	StmtBegin
+	StmtCommit
+	StmtRollback
+	StmtSavepoint
+	StmtLock
+	StmtUnlock
+	StmtKill
+	StmtGrant
+	StmtRevoke
+	StmtPrepare
+	StmtExecute
+	StmtDeallocate
+	StmtFlush
+	StmtAnalyze
+	StmtOptimize
+	StmtDo
+	StmtHandler
+)
+
+var stmtNames = []string{
+	StmtUnknown:    "unknown",
+	StmtSelect:     "select",
+	StmtInsert:     "insert",
+	StmtReplace:    "replace",
+	StmtUpdate:     "update",
+	StmtDelete:     "delete",
+	StmtLoad:       "load",
+	StmtCall:       "call",
+	StmtCreate:     "create",
+	StmtAlter:      "alter",
+	StmtDrop:       "drop",
+	StmtTruncate:   "truncate",
+	StmtRename:     "rename",
+	StmtSet:        "set",
+	StmtUse:        "use",
+	StmtShow:       "show",
+	StmtExplain:    "explain",
+	StmtBegin:      "begin",
+	StmtCommit:     "commit",
+	StmtRollback:   "rollback",
+	StmtSavepoint:  "savepoint",
+	StmtLock:       "lock",
+	StmtUnlock:     "unlock",
+	StmtKill:       "kill",
+	StmtGrant:      "grant",
+	StmtRevoke:     "revoke",
+	StmtPrepare:    "prepare",
+	StmtExecute:    "execute",
+	StmtDeallocate: "deallocate",
+	StmtFlush:      "flush",
+	StmtAnalyze:    "analyze",
+	StmtOptimize:   "optimize",
+	StmtDo:         "do",
+	StmtHandler:    "handler",
+}
+
+func (t StmtType) String() string {
+	if t >= 0 && int(t) < len(stmtNames) {
+		return stmtNames[t]
+	}
+	return stmtNames[StmtUnknown]
+}
+
+//ParseStmtType the statement type of the name, such as "select", StmtUnknown if it is not a type name.
+func ParseStmtType(name string) StmtType {
+	for t, n := range stmtNames {
+		if strings.EqualFold(n, name) {
+			return StmtType(t)
+		}
+	}
+	return StmtUnknown
+}
+
+//IsDML the statement read or write the table data.
+func (t StmtType) IsDML() bool {
+	switch t {
+	case StmtSelect, StmtInsert, StmtReplace, StmtUpdate, StmtDelete, StmtLoad:
+		return true
+	}
+	return false
+}
+
+//IsDDL the statement change the schema.
+func (t StmtType) IsDDL() bool {
+	switch t {
+	case StmtCreate, StmtAlter, StmtDrop, StmtTruncate, StmtRename:
+		return true
+	}
+	return false
+}
+
+//IsWrite the statement may change the data or the schema.
+func (t StmtType) IsWrite() bool {
+	switch t {
+	case StmtInsert, StmtReplace, StmtUpdate, StmtDelete, StmtLoad, StmtCall:
+		return true
+	}
+	return t.IsDDL()
+}
+
+var firstWords = map[string]StmtType{
+	"SELECT":     StmtSelect,
+	"VALUES":     StmtSelect,
+	"TABLE":      StmtSelect,
+	"INSERT":     StmtInsert,
+	"REPLACE":    StmtReplace,
+	"UPDATE":     StmtUpdate,
+	"DELETE":     StmtDelete,
+	"LOAD":       StmtLoad,
+	"CALL":       StmtCall,
+	"CREATE":     StmtCreate,
+	"ALTER":      StmtAlter,
+	"DROP":       StmtDrop,
+	"TRUNCATE":   StmtTruncate,
+	"RENAME":     StmtRename,
+	"SET":        StmtSet,
+	"USE":        StmtUse,
+	"SHOW":       StmtShow,
+	"EXPLAIN":    StmtExplain,
+	"DESCRIBE":   StmtExplain,
+	"DESC":       StmtExplain,
+	"BEGIN":      StmtBegin,
+	"START":      StmtBegin,
+	"COMMIT":     StmtCommit,
+	"ROLLBACK":   StmtRollback,
+	"SAVEPOINT":  StmtSavepoint,
+	"RELEASE":    StmtSavepoint,
+	"LOCK":       StmtLock,
+	"UNLOCK":     StmtUnlock,
+	"KILL":       StmtKill,
+	"GRANT":      StmtGrant,
+	"REVOKE":     StmtRevoke,
+	"PREPARE":    StmtPrepare,
+	"EXECUTE":    StmtExecute,
+	"DEALLOCATE": StmtDeallocate,
+	"FLUSH":      StmtFlush,
+	"ANALYZE":    StmtAnalyze,
+	"OPTIMIZE":   StmtOptimize,
+	"DO":         StmtDo,
+	"HANDLER":    StmtHandler,
+}
+
+//Classify return the statement type by the first word of the sql,
+//it skip the leading comments and parentheses and never tokenize the whole sql,
+//the statement after the CTE list of WITH is classified.
+func Classify(sql []byte) StmtType {
+	return classify(NewTokenizer(sql))
+}
+
+func classify(t *Tokenizer) StmtType {
+	for {
+		tok, err := t.Next()
+		if err != nil {
+			return StmtUnknown
+		}
+		if tok.Type == TokOperator && tok.Val == "(" {
+			continue
+		}
+		if tok.Type != TokIdent {
+			return StmtUnknown
+		}
+		word := strings.ToUpper(tok.Val)
+		if word == "WITH" {
+			return classifyWith(t)
+		}
+		return firstWords[word]
+	}
+}
+
+//classifyWith skip the CTE list "[RECURSIVE] name [(cols)] AS (query), ..." and classify the statement after it.
+func classifyWith(t *Tokenizer) StmtType {
+	depth, closed := 0, false
+	for {
+		tok, err := t.Next()
+		if err != nil {
+			return StmtUnknown
+		}
+		isOp := tok.Type == TokOperator
+		switch {
+		case isOp && tok.Val == "(":
+			if closed {
+				//the parenthesized statement after the list
+				return classify(t)
+			}
+			depth++
+		case isOp && tok.Val == ")":
+			depth--
+			closed = depth == 0
+		case closed:
+			//AS after the column list, or "," before the next CTE
+			closed = false
+			if tok.Type == TokIdent && !strings.EqualFold(tok.Val, "AS") {
+				return firstWords[strings.ToUpper(tok.Val)]
+			}
+		}
+	}
+}
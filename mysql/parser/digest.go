@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+)
+
+//the words a sign before a number is unary after.
+var signAfter = map[string]bool{
+	"select": true, "where": true, "and": true, "or": true, "not": true, "on": true, "set": true,
+	"when": true, "then": true, "else": true, "by": true, "limit": true, "offset": true,
+	"between": true, "like": true, "in": true, "is": true, "values": true, "value": true,
+}
+
+//Fingerprint normalize the sql to the digest of the statements with the same shape:
+//the literals are replaced by ?, IN lists are collapsed to in(?+), multi rows VALUES to values(?+),
+//the comments are removed, the words are lower cased and the spaces are collapsed.
+//
+//	SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'a' => select * from t where id in(?+) and name = ?
+func Fingerprint(sql []byte) string {
+	toks, _ := Tokenize(sql)
+	out := make([]string, 0, len(toks))
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		switch tok.Type {
+		case TokNumber, TokString, TokParam:
+			if n := len(out); n > 0 && (out[n-1] == "-" || out[n-1] == "+") && (n == 1 || unaryAfter(out[n-2])) {
+				out = out[:n-1]
+			}
+			out = append(out, "?")
+
+		case TokIdent, TokQuotedIdent:
+			word := strings.ToLower(tok.Val)
+			switch {
+			case word == "in" && tok.Type == TokIdent:
+				if end := literalList(toks, i+1); end > 0 {
+					out = append(out, "in", "(?+)")
+					i = end
+					continue
+				}
+			case (word == "values" || word == "value") && tok.Type == TokIdent && len(out) > 0 &&
+				(out[len(out)-1] == ")" || !isOp(out[len(out)-1])):
+				//insert into t values ..., insert into t(a, b) values ..., but not "a = values(a)"
+				if end := skipRows(toks, i+1); end > 0 {
+					out = append(out, "values", "(?+)")
+					i = end
+					continue
+				}
+			}
+			out = append(out, word)
+
+		case TokOperator:
+			if tok.Val != ";" {
+				out = append(out, tok.Val)
+			}
+
+		default:
+			out = append(out, tok.Val)
+		}
+	}
+
+	var b strings.Builder
+	for i, s := range out {
+		if i > 0 && !noSpace(out[i-1], s) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+//DigestID the short id of the fingerprint, the upper 16 hex digits of the md5 like pt-query-digest.
+func DigestID(fingerprint string) string {
+	sum := md5.Sum([]byte(fingerprint))
+	return strings.ToUpper(hex.EncodeToString(sum[8:]))
+}
+
+func isOp(s string) bool {
+	return len(s) > 0 && !isIdentChar(s[0]) && s[0] != '?' && s[0] != '@' && s[0] != '`'
+}
+
+//unaryAfter the sign after s is unary.
+func unaryAfter(s string) bool {
+	return (isOp(s) && s != ")") || signAfter[s]
+}
+
+func noSpace(prev, s string) bool {
+	switch {
+	case s == "," || s == ")" || s == "." || prev == "(" || prev == ".":
+		return true
+	case s == "(?+)" || s == "(":
+		return !isOp(prev)
+	}
+	return false
+}
+
+//literalList return the index of ")" if toks[i:] is "(" literals separated by "," ")", or 0.
+func literalList(toks []Token, i int) int {
+	if i >= len(toks) || toks[i].Val != "(" || toks[i].Type != TokOperator {
+		return 0
+	}
+	for j := i + 1; j < len(toks); j += 2 {
+		if !toks[j].IsLiteral() || j+1 >= len(toks) || toks[j+1].Type != TokOperator {
+			return 0
+		}
+		switch toks[j+1].Val {
+		case ")":
+			return j + 1
+		case ",":
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+//skipRows return the index of the last ")" of the rows "(...), (...)" begin at toks[i], or 0.
+func skipRows(toks []Token, i int) int {
+	end := 0
+	for i < len(toks) && toks[i].Type == TokOperator && toks[i].Val == "(" {
+		depth := 0
+		for ; i < len(toks); i++ {
+			if toks[i].Type != TokOperator {
+				continue
+			}
+			if toks[i].Val == "(" {
+				depth++
+			} else if toks[i].Val == ")" {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		if i == len(toks) {
+			return end
+		}
+		end = i
+		if i+2 < len(toks) && toks[i+1].Val == "," && toks[i+1].Type == TokOperator {
+			i += 2
+		} else {
+			break
+		}
+	}
+	return end
+}
@@ -0,0 +1,1694 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//SyntaxError the error of the sql syntax.
+type SyntaxError struct {
+	Pos  int
+	Msg  string
+	Near string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s near '%s'", e.Msg, e.Near)
+}
+
+//Parse parse a single statement, the trailing semicolon is allowed.
+//The statements not understood by the parser return as *Other.
+func Parse(sql []byte) (Statement, error) {
+	toks, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{sql: sql, toks: toks}
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptOp(";")
+	if !p.eof() {
+		return nil, p.errorf("unexpected %s", p.peek().Val)
+	}
+	return stmt, nil
+}
+
+//the words can not be used as alias and column name.
+var reserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true, "BY": true,
+	"LIMIT": true, "HAVING": true, "UNION": true, "FOR": true, "LOCK": true, "INTO": true,
+	"ON": true, "USING": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"CROSS": true, "NATURAL": true, "STRAIGHT_JOIN": true, "OUTER": true, "SET": true,
+	"VALUES": true, "VALUE": true, "WINDOW": true, "AND": true, "OR": true, "XOR": true,
+	"NOT": true, "IS": true, "IN": true, "LIKE": true, "BETWEEN": true, "REGEXP": true,
+	"RLIKE": true, "AS": true, "ASC": true, "DESC": true, "FORCE": true, "IGNORE": true,
+	"USE": true, "PARTITION": true, "CASE": true, "WHEN": true, "THEN": true, "ELSE": true,
+	"END": true, "OFFSET": true, "DIV": true, "MOD": true, "WITH": true, "INTERVAL": true,
+	"SOUNDS": true, "ESCAPE": true, "COLLATE": true, "DUPLICATE": true,
+}
+
+type parser struct {
+	sql  []byte
+	toks []Token
+	pos  int
+}
+
+func (p *parser) peek() Token {
+	return p.peekN(0)
+}
+
+//peekN return the nth token after current, an empty operator token at the end.
+func (p *parser) peekN(n int) Token {
+	if p.pos+n < len(p.toks) {
+		return p.toks[p.pos+n]
+	}
+	end := 0
+	if len(p.toks) > 0 {
+		end = p.toks[len(p.toks)-1].End
+	}
+	return Token{Type: TokOperator, Pos: end, End: end}
+}
+
+func (p *parser) next() Token {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) is(kw string) bool {
+	return p.peek().Keyword() == kw
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.Type == TokOperator && t.Val == op
+}
+
+func (p *parser) acceptKw(kw string) bool {
+	if p.is(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+//acceptSeq accept all the keywords or none.
+func (p *parser) acceptSeq(kws ...string) bool {
+	for i, kw := range kws {
+		if p.peekN(i).Keyword() != kw {
+			return false
+		}
+	}
+	p.pos += len(kws)
+	return true
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKw(kw string) error {
+	if !p.acceptKw(kw) {
+		return p.errorf("expect %s", kw)
+	}
+	return nil
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf("expect %s", op)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	near := string(p.sql[t.Pos:])
+	if len(near) > 32 {
+		near = near[:32]
+	}
+	return &SyntaxError{Pos: t.Pos, Msg: fmt.Sprintf(format, args...), Near: near}
+}
+
+//skipRest skip the tokens to the end of the statement.
+func (p *parser) skipRest() {
+	for !p.eof() && !p.isOp(";") {
+		p.pos++
+	}
+}
+
+//skipParens skip the parenthesized tokens.
+func (p *parser) skipParens() error {
+	if err := p.expectOp("("); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		if p.eof() {
+			return p.errorf("expect )")
+		}
+		switch t := p.next(); {
+		case t.Type != TokOperator:
+		case t.Val == "(":
+			depth++
+		case t.Val == ")":
+			depth--
+		}
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t.Type != TokQuotedIdent && t.Type != TokIdent {
+		return "", p.errorf("expect identifier")
+	}
+	p.pos++
+	return t.Val, nil
+}
+
+func (p *parser) tableName() (*TableName, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	t := &TableName{Name: name}
+	if p.isOp(".") {
+		p.pos++
+		if t.Name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		t.Schema = name
+	}
+	return t, nil
+}
+
+//alias read the optional [AS] alias.
+func (p *parser) alias() (string, error) {
+	if p.acceptKw("AS") {
+		t := p.next()
+		if t.Type != TokIdent && t.Type != TokQuotedIdent && t.Type != TokString {
+			return "", p.errorf("expect alias")
+		}
+		return t.Val, nil
+	}
+	t := p.peek()
+	if (t.Type == TokIdent && !reserved[t.Keyword()]) || t.Type == TokQuotedIdent || t.Type == TokString {
+		p.pos++
+		return t.Val, nil
+	}
+	return "", nil
+}
+
+func (p *parser) statement() (Statement, error) {
+	if p.isOp("(") {
+		return p.selectStmt()
+	}
+	switch kw := p.peek().Keyword(); kw {
+	case "SELECT":
+		return p.selectStmt()
+	case "INSERT", "REPLACE":
+		return p.insert()
+	case "UPDATE":
+		return p.update()
+	case "DELETE":
+		return p.delete()
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME":
+		return p.ddl()
+	case "SET":
+		return p.set()
+	case "USE":
+		p.pos++
+		db, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		return &Use{DB: db}, nil
+	case "SHOW":
+		return p.show()
+	case "BEGIN", "START", "COMMIT", "ROLLBACK":
+		return p.transaction()
+	case "KILL":
+		return p.kill()
+	default:
+		p.skipRest()
+		return &Other{StmtType: firstWords[kw]}, nil
+	}
+}
+
+//selectStmt parse the SELECT and UNION.
+func (p *parser) selectStmt() (Statement, error) {
+	var selects []*Select
+	var all []bool
+	paren := false
+	for {
+		s, pr, err := p.selectCore()
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, s)
+		paren = pr
+		if !p.acceptKw("UNION") {
+			break
+		}
+		a := p.acceptKw("ALL")
+		if !a {
+			p.acceptKw("DISTINCT")
+		}
+		all = append(all, a)
+	}
+
+	//ORDER BY and LIMIT after the parenthesized select
+	orderBy, err := p.orderBy()
+	if err != nil {
+		return nil, err
+	}
+	limitAt := p.peek().Pos
+	limit, err := p.limit()
+	if err != nil {
+		return nil, err
+	}
+	if len(selects) == 1 {
+		s := selects[0]
+		if orderBy != nil {
+			s.OrderBy, s.LimitAt = orderBy, limitAt
+		}
+		if limit != nil {
+			s.Limit = limit
+		}
+		return s, nil
+	}
+
+	u := &Union{Selects: selects, All: all, OrderBy: orderBy, Limit: limit}
+	if last := selects[len(selects)-1]; !paren {
+		u.OrderBy, last.OrderBy = last.OrderBy, nil
+		u.Limit, last.Limit = last.Limit, nil
+	}
+	return u, nil
+}
+
+var selectModifiers = map[string]bool{
+	"ALL": true, "DISTINCT": true, "DISTINCTROW": true, "HIGH_PRIORITY": true,
+	"STRAIGHT_JOIN": true, "SQL_SMALL_RESULT": true, "SQL_BIG_RESULT": true,
+	"SQL_BUFFER_RESULT": true, "SQL_CACHE": true, "SQL_NO_CACHE": true, "SQL_CALC_FOUND_ROWS": true,
+}
+
+//selectCore parse a single select, return true if it is parenthesized.
+func (p *parser) selectCore() (*Select, bool, error) {
+	if p.acceptOp("(") {
+		stmt, err := p.selectStmt()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, false, err
+		}
+		s, ok := stmt.(*Select)
+		if !ok {
+			return nil, false, p.errorf("nested union is not supported")
+		}
+		return s, true, nil
+	}
+
+	if err := p.expectKw("SELECT"); err != nil {
+		return nil, false, err
+	}
+	s := &Select{}
+	for selectModifiers[p.peek().Keyword()] {
+		if kw := p.next().Keyword(); kw == "DISTINCT" || kw == "DISTINCTROW" {
+			s.Distinct = true
+		}
+	}
+
+	var err error
+	if s.Fields, err = p.selectFields(); err != nil {
+		return nil, false, err
+	}
+	if p.is("INTO") {
+		return nil, false, p.errorf("SELECT INTO is not supported")
+	}
+	if p.acceptKw("FROM") && !p.acceptKw("DUAL") {
+		if s.From, err = p.tableRefs(); err != nil {
+			return nil, false, err
+		}
+	}
+	if p.acceptKw("WHERE") {
+		if s.Where, err = p.expr(); err != nil {
+			return nil, false, err
+		}
+	}
+	if p.acceptSeq("GROUP", "BY") {
+		if s.GroupBy, err = p.exprList(); err != nil {
+			return nil, false, err
+		}
+		s.Rollup = p.acceptSeq("WITH", "ROLLUP")
+	}
+	if p.acceptKw("HAVING") {
+		if s.Having, err = p.expr(); err != nil {
+			return nil, false, err
+		}
+	}
+	if s.OrderBy, err = p.orderBy(); err != nil {
+		return nil, false, err
+	}
+	s.LimitAt = p.peek().Pos
+	if s.Limit, err = p.limit(); err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case p.acceptSeq("FOR", "UPDATE"):
+		s.Lock = "FOR UPDATE"
+	case p.acceptSeq("FOR", "SHARE"):
+		s.Lock = "FOR SHARE"
+	case p.acceptSeq("LOCK", "IN", "SHARE", "MODE"):
+		s.Lock = "LOCK IN SHARE MODE"
+	}
+	if s.Lock != "" {
+		if !p.acceptKw("NOWAIT") {
+			p.acceptSeq("SKIP", "LOCKED")
+		}
+	}
+	return s, false, nil
+}
+
+func (p *parser) selectFields() ([]*SelectField, error) {
+	var fields []*SelectField
+	for {
+		f := &SelectField{}
+		t := p.peek()
+		switch {
+		case p.acceptOp("*"):
+			f.Star = true
+		case (t.Type == TokIdent || t.Type == TokQuotedIdent) && p.peekN(1).Val == "." && p.peekN(2).Val == "*" && p.peekN(2).Type == TokOperator:
+			f.Star, f.Table = true, t.Val
+			p.pos += 3
+		default:
+			var err error
+			if f.Expr, err = p.expr(); err != nil {
+				return nil, err
+			}
+			if f.Alias, err = p.alias(); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+		if !p.acceptOp(",") {
+			return fields, nil
+		}
+	}
+}
+
+func (p *parser) orderBy() ([]*OrderItem, error) {
+	if !p.acceptSeq("ORDER", "BY") {
+		return nil, nil
+	}
+	var items []*OrderItem
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		item := &OrderItem{Expr: e}
+		if p.acceptKw("DESC") {
+			item.Desc = true
+		} else {
+			p.acceptKw("ASC")
+		}
+		items = append(items, item)
+		if !p.acceptOp(",") {
+			return items, nil
+		}
+	}
+}
+
+func (p *parser) limit() (*Limit, error) {
+	t := p.peek()
+	if !p.acceptKw("LIMIT") {
+		return nil, nil
+	}
+	l := &Limit{Pos: t.Pos}
+	a, err := p.limitValue()
+	if err != nil {
+		return nil, err
+	}
+	l.Count = a
+	switch {
+	case p.acceptOp(","):
+		l.Offset = a
+		l.Count, err = p.limitValue()
+	case p.acceptKw("OFFSET"):
+		l.Offset, err = p.limitValue()
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.End = p.toks[p.pos-1].End
+	return l, nil
+}
+
+func (p *parser) limitValue() (Expr, error) {
+	switch t := p.peek(); t.Type {
+	case TokNumber:
+		p.pos++
+		return &Literal{Kind: LitNumber, Val: t.Val}, nil
+	case TokParam:
+		p.pos++
+		return &Param{Index: t.Param}, nil
+	case TokIdent:
+		//the local variable of stored procedure
+		p.pos++
+		return &ColumnRef{Name: t.Val}, nil
+	}
+	return nil, p.errorf("expect limit value")
+}
+
+func (p *parser) tableRefs() ([]TableRef, error) {
+	var refs []TableRef
+	for {
+		r, err := p.tableRef()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+		if !p.acceptOp(",") {
+			return refs, nil
+		}
+	}
+}
+
+//joinKind read the join keywords.
+func (p *parser) joinKind() string {
+	switch {
+	case p.acceptKw("JOIN"), p.acceptSeq("INNER", "JOIN"):
+		return "JOIN"
+	case p.acceptSeq("CROSS", "JOIN"):
+		return "CROSS JOIN"
+	case p.acceptKw("STRAIGHT_JOIN"):
+		return "STRAIGHT_JOIN"
+	case p.acceptSeq("LEFT", "JOIN"), p.acceptSeq("LEFT", "OUTER", "JOIN"):
+		return "LEFT JOIN"
+	case p.acceptSeq("RIGHT", "JOIN"), p.acceptSeq("RIGHT", "OUTER", "JOIN"):
+		return "RIGHT JOIN"
+	case p.acceptSeq("NATURAL", "JOIN"):
+		return "NATURAL JOIN"
+	case p.acceptSeq("NATURAL", "LEFT", "JOIN"), p.acceptSeq("NATURAL", "LEFT", "OUTER", "JOIN"):
+		return "NATURAL LEFT JOIN"
+	case p.acceptSeq("NATURAL", "RIGHT", "JOIN"), p.acceptSeq("NATURAL", "RIGHT", "OUTER", "JOIN"):
+		return "NATURAL RIGHT JOIN"
+	}
+	return ""
+}
+
+func (p *parser) tableRef() (TableRef, error) {
+	left, err := p.tableFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		kind := p.joinKind()
+		if kind == "" {
+			return left, nil
+		}
+		right, err := p.tableFactor()
+		if err != nil {
+			return nil, err
+		}
+		j := &Join{Kind: kind, Left: left, Right: right}
+		switch {
+		case p.acceptKw("ON"):
+			if j.On, err = p.expr(); err != nil {
+				return nil, err
+			}
+		case p.acceptKw("USING"):
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			for {
+				col, err := p.ident()
+				if err != nil {
+					return nil, err
+				}
+				j.Using = append(j.Using, col)
+				if !p.acceptOp(",") {
+					break
+				}
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+		}
+		left = j
+	}
+}
+
+func (p *parser) tableFactor() (TableRef, error) {
+	if p.acceptOp("(") {
+		if p.is("SELECT") || p.isOp("(") {
+			sel, err := p.selectStmt()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			alias, err := p.alias()
+			if err != nil {
+				return nil, err
+			}
+			return &SubqueryTable{Select: sel, Alias: alias}, nil
+		}
+		refs, err := p.tableRefs()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return &ParenTable{Tables: refs}, nil
+	}
+
+	name, err := p.tableName()
+	if err != nil {
+		return nil, err
+	}
+	t := &AliasedTable{Table: name}
+	if p.acceptKw("PARTITION") {
+		if err := p.skipParens(); err != nil {
+			return nil, err
+		}
+	}
+	if t.Alias, err = p.alias(); err != nil {
+		return nil, err
+	}
+	//index hints
+	for {
+		switch p.peek().Keyword() {
+		case "USE", "IGNORE", "FORCE":
+		default:
+			return t, nil
+		}
+		if kw := p.peekN(1).Keyword(); kw != "INDEX" && kw != "KEY" {
+			return t, nil
+		}
+		p.pos += 2
+		if p.acceptKw("FOR") {
+			if p.acceptKw("ORDER") || p.acceptKw("GROUP") {
+				p.acceptKw("BY")
+			} else {
+				p.acceptKw("JOIN")
+			}
+		}
+		if err := p.skipParens(); err != nil {
+			return nil, err
+		}
+		p.acceptOp(",")
+	}
+}
+
+func (p *parser) exprList() ([]Expr, error) {
+	var list []Expr
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.acceptOp(",") {
+			return list, nil
+		}
+	}
+}
+
+// expr parse the expression by the operator precedence.
+// https://dev.mysql.com/doc/refman/5.7/en/operator-precedence.html
+func (p *parser) expr() (Expr, error) {
+	return p.logical(0)
+}
+
+var logicalOps = [][]string{{"OR", "||"}, {"XOR"}, {"AND", "&&"}}
+
+func (p *parser) logical(level int) (Expr, error) {
+	if level == len(logicalOps) {
+		return p.not()
+	}
+	l, err := p.logical(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.binaryOp(logicalOps[level])
+		if op == "" {
+			return l, nil
+		}
+		r, err := p.logical(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &BinaryExpr{Op: logicalOps[level][0], L: l, R: r}
+	}
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.acceptKw("NOT") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", X: x}, nil
+	}
+	return p.predicate()
+}
+
+var compareOps = map[string]bool{
+	"=": true, "<=>": true, ">=": true, ">": true, "<=": true, "<": true, "<>": true, "!=": true,
+}
+
+//predicate parse the comparison, IS, IN, LIKE, REGEXP and BETWEEN.
+func (p *parser) predicate() (Expr, error) {
+	l, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.Type == TokOperator && compareOps[t.Val] {
+			p.pos++
+			var r Expr
+			if kw := p.peek().Keyword(); (kw == "ANY" || kw == "ALL" || kw == "SOME") && p.peekN(1).Val == "(" {
+				p.pos += 2
+				sel, err := p.selectStmt()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectOp(")"); err != nil {
+					return nil, err
+				}
+				r = &FuncCall{Name: kw, Args: []Expr{&Subquery{Select: sel}}}
+			} else if r, err = p.binary(0); err != nil {
+				return nil, err
+			}
+			l = &BinaryExpr{Op: t.Val, L: l, R: r}
+			continue
+		}
+
+		kw := t.Keyword()
+		not := false
+		if kw == "NOT" {
+			switch p.peekN(1).Keyword() {
+			case "IN", "LIKE", "BETWEEN", "REGEXP", "RLIKE":
+				not = true
+				p.pos++
+				kw = p.peek().Keyword()
+			default:
+				return l, nil
+			}
+		}
+		switch kw {
+		case "IS":
+			p.pos++
+			e := &IsExpr{X: l, Not: p.acceptKw("NOT")}
+			switch what := p.next().Keyword(); what {
+			case "NULL", "TRUE", "FALSE", "UNKNOWN":
+				e.What = what
+			default:
+				return nil, p.errorf("expect NULL, TRUE, FALSE or UNKNOWN")
+			}
+			l = e
+
+		case "IN":
+			p.pos++
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			e := &InExpr{X: l, Not: not}
+			if p.is("SELECT") {
+				e.Select, err = p.selectStmt()
+			} else {
+				e.List, err = p.exprList()
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			l = e
+
+		case "LIKE", "REGEXP", "RLIKE":
+			p.pos++
+			r, err := p.binary(0)
+			if err != nil {
+				return nil, err
+			}
+			if kw == "LIKE" && p.acceptKw("ESCAPE") {
+				if _, err := p.primary(); err != nil {
+					return nil, err
+				}
+			}
+			if kw == "RLIKE" {
+				kw = "REGEXP"
+			}
+			if not {
+				kw = "NOT " + kw
+			}
+			l = &BinaryExpr{Op: kw, L: l, R: r}
+
+		case "BETWEEN":
+			p.pos++
+			e := &BetweenExpr{X: l, Not: not}
+			if e.Low, err = p.binary(0); err != nil {
+				return nil, err
+			}
+			if err := p.expectKw("AND"); err != nil {
+				return nil, err
+			}
+			if e.High, err = p.binary(0); err != nil {
+				return nil, err
+			}
+			l = e
+
+		case "SOUNDS":
+			if p.peekN(1).Keyword() != "LIKE" {
+				return l, nil
+			}
+			p.pos += 2
+			r, err := p.binary(0)
+			if err != nil {
+				return nil, err
+			}
+			l = &BinaryExpr{Op: "SOUNDS LIKE", L: l, R: r}
+
+		default:
+			return l, nil
+		}
+	}
+}
+
+var binaryOps = [][]string{
+	{"|"}, {"&"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%", "DIV", "MOD"}, {"^"},
+}
+
+func (p *parser) binaryOp(ops []string) string {
+	t := p.peek()
+	for _, op := range ops {
+		if (t.Type == TokOperator && t.Val == op) || t.Keyword() == op {
+			p.pos++
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *parser) binary(level int) (Expr, error) {
+	if level == len(binaryOps) {
+		return p.unary()
+	}
+	l, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.binaryOp(binaryOps[level])
+		if op == "" {
+			return l, nil
+		}
+		r, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if op == "%" {
+			op = "MOD"
+		}
+		l = &BinaryExpr{Op: op, L: l, R: r}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	t := p.peek()
+	if t.Type == TokOperator && (t.Val == "-" || t.Val == "+" || t.Val == "~" || t.Val == "!") {
+		p.pos++
+		x, err := p.unary()
+		if err != nil || t.Val == "+" {
+			return x, err
+		}
+		return &UnaryExpr{Op: t.Val, X: x}, nil
+	}
+	if t.Keyword() == "BINARY" && p.peekN(1).Val != "(" {
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "BINARY", X: x}, nil
+	}
+
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.acceptKw("COLLATE"):
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			x = &BinaryExpr{Op: "COLLATE", L: x, R: &Literal{Kind: LitString, Val: name}}
+		case p.isOp("->"), p.isOp("->>"):
+			op := p.next().Val
+			r, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			x = &BinaryExpr{Op: op, L: x, R: r}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (Expr, error) {
+	t := p.peek()
+	switch t.Type {
+	case TokNumber:
+		p.pos++
+		if c := t.Val[0]; c == 'x' || c == 'X' || c == 'b' || c == 'B' || (len(t.Val) > 1 && c == '0' && strings.ContainsAny(t.Val[1:2], "xXbB")) {
+			return &Literal{Kind: LitHex, Val: t.Val}, nil
+		}
+		return &Literal{Kind: LitNumber, Val: t.Val}, nil
+
+	case TokString:
+		p.pos++
+		val := t.Val
+		//the adjacent strings are concatenated
+		for p.peek().Type == TokString {
+			val += p.next().Val
+		}
+		return &Literal{Kind: LitString, Val: val}, nil
+
+	case TokParam:
+		p.pos++
+		return &Param{Index: t.Param}, nil
+
+	case TokVariable:
+		p.pos++
+		return &Variable{Name: t.Val}, nil
+
+	case TokQuotedIdent:
+		p.pos++
+		return p.columnRef(t)
+
+	case TokOperator:
+		if t.Val != "(" {
+			return nil, p.errorf("unexpected %s", t.Val)
+		}
+		p.pos++
+		if p.is("SELECT") {
+			sel, err := p.selectStmt()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return &Subquery{Select: sel}, nil
+		}
+		list, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		if len(list) == 1 {
+			return list[0], nil
+		}
+		return &RowExpr{Items: list}, nil
+	}
+
+	kw := t.Keyword()
+	switch kw {
+	case "NULL":
+		p.pos++
+		return &Literal{Kind: LitNull, Val: kw}, nil
+	case "TRUE", "FALSE":
+		p.pos++
+		return &Literal{Kind: LitBool, Val: kw}, nil
+	case "DEFAULT":
+		if p.peekN(1).Val != "(" {
+			p.pos++
+			return &DefaultExpr{}, nil
+		}
+	case "DATE", "TIME", "TIMESTAMP":
+		if s := p.peekN(1); s.Type == TokString {
+			p.pos += 2
+			return &Literal{Kind: LitString, Val: s.Val}, nil
+		}
+	case "EXISTS":
+		p.pos++
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		sel, err := p.selectStmt()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return &ExistsExpr{Select: sel}, nil
+	case "CASE":
+		p.pos++
+		return p.caseExpr()
+	case "INTERVAL":
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		unit := p.next().Keyword()
+		if unit == "" {
+			return nil, p.errorf("expect interval unit")
+		}
+		return &IntervalExpr{X: x, Unit: unit}, nil
+	case "CAST", "CONVERT":
+		if p.peekN(1).Val == "(" {
+			p.pos += 2
+			return p.cast(kw)
+		}
+	}
+
+	p.pos++
+	if p.isOp("(") {
+		return p.funcCall(t)
+	}
+	if reserved[kw] {
+		p.pos--
+		return nil, p.errorf("unexpected %s", t.Val)
+	}
+	return p.columnRef(t)
+}
+
+func (p *parser) columnRef(t Token) (Expr, error) {
+	c := &ColumnRef{Name: t.Val}
+	for i := 0; i < 2 && p.isOp("."); i++ {
+		p.pos++
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		c.Schema, c.Table, c.Name = c.Table, c.Name, name
+	}
+	return c, nil
+}
+
+func (p *parser) caseExpr() (Expr, error) {
+	c := &CaseExpr{}
+	var err error
+	if !p.is("WHEN") {
+		if c.Value, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptKw("WHEN") {
+		w := &When{}
+		if w.Cond, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKw("THEN"); err != nil {
+			return nil, err
+		}
+		if w.Result, err = p.expr(); err != nil {
+			return nil, err
+		}
+		c.Whens = append(c.Whens, w)
+	}
+	if len(c.Whens) == 0 {
+		return nil, p.errorf("expect WHEN")
+	}
+	if p.acceptKw("ELSE") {
+		if c.Else, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKw("END"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//cast parse the CAST(x AS type), CONVERT(x, type) and CONVERT(x USING charset) after the (.
+func (p *parser) cast(fn string) (Expr, error) {
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	c := &CastExpr{X: x}
+	switch {
+	case fn == "CAST" && p.acceptKw("AS"):
+	case fn == "CONVERT" && p.acceptOp(","):
+	case fn == "CONVERT" && p.acceptKw("USING"):
+		c.Type = "USING "
+	default:
+		return nil, p.errorf("expect type")
+	}
+	start := p.peek().Pos
+	for depth := 0; !(depth == 0 && p.isOp(")")); {
+		if p.eof() {
+			return nil, p.errorf("expect )")
+		}
+		switch t := p.next(); {
+		case t.Type != TokOperator:
+		case t.Val == "(":
+			depth++
+		case t.Val == ")":
+			depth--
+		}
+	}
+	c.Type += strings.ToUpper(string(p.sql[start:p.peek().Pos]))
+	c.Type = strings.TrimSpace(c.Type)
+	p.pos++
+	return c, nil
+}
+
+//funcCall parse the function call after the name, include the special syntax of
+//GROUP_CONCAT, TRIM, SUBSTRING, EXTRACT and POSITION.
+func (p *parser) funcCall(name Token) (Expr, error) {
+	p.pos++
+	f := &FuncCall{Name: strings.ToUpper(name.Val)}
+	switch {
+	case p.acceptOp(")"):
+		return p.over(f)
+	case p.acceptOp("*"):
+		f.Star = true
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return p.over(f)
+	case p.acceptKw("DISTINCT"):
+		f.Distinct = true
+	default:
+		p.acceptKw("ALL")
+	}
+	if f.Name == "TRIM" {
+		if !p.acceptKw("BOTH") && !p.acceptKw("LEADING") {
+			p.acceptKw("TRAILING")
+		}
+	}
+	if f.Name == "POSITION" {
+		x, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKw("IN"); err != nil {
+			return nil, err
+		}
+		f.Args = append(f.Args, x)
+	}
+
+	for {
+		args, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		f.Args = append(f.Args, args...)
+		if p.acceptOp(")") {
+			return p.over(f)
+		}
+		switch {
+		case p.acceptKw("FROM"), p.acceptKw("FOR"), p.acceptKw("SEPARATOR"), p.acceptKw("USING"):
+		case p.is("ORDER"):
+			//GROUP_CONCAT(x ORDER BY y DESC SEPARATOR ',')
+			items, err := p.orderBy()
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				f.Args = append(f.Args, item.Expr)
+			}
+			if p.acceptOp(")") {
+				return p.over(f)
+			}
+			if err := p.expectKw("SEPARATOR"); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.errorf("expect )")
+		}
+	}
+}
+
+//over skip the window of the window function.
+func (p *parser) over(f *FuncCall) (Expr, error) {
+	if !p.acceptKw("OVER") {
+		return f, nil
+	}
+	if p.isOp("(") {
+		return f, p.skipParens()
+	}
+	_, err := p.ident()
+	return f, err
+}
+
+func (p *parser) insert() (Statement, error) {
+	s := &Insert{Replace: p.next().Keyword() == "REPLACE"}
+	for {
+		switch kw := p.peek().Keyword(); kw {
+		case "LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY":
+			s.Priority = kw
+		case "IGNORE":
+			s.Ignore = true
+		default:
+			goto into
+		}
+		p.pos++
+	}
+
+into:
+	p.acceptKw("INTO")
+	var err error
+	if s.Table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if p.acceptKw("PARTITION") {
+		if err := p.skipParens(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isOp("(") && p.peekN(1).Keyword() != "SELECT" && p.peekN(1).Val != "(" {
+		p.pos++
+		for !p.acceptOp(")") {
+			col, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if p.isOp(".") {
+				p.pos++
+				if col, err = p.ident(); err != nil {
+					return nil, err
+				}
+			}
+			s.Columns = append(s.Columns, col)
+			if !p.acceptOp(",") && !p.isOp(")") {
+				return nil, p.errorf("expect )")
+			}
+		}
+	}
+
+	switch {
+	case p.acceptKw("VALUES"), p.acceptKw("VALUE"):
+		for {
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			row := []Expr{}
+			if !p.isOp(")") {
+				if row, err = p.exprList(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			s.Rows = append(s.Rows, row)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	case p.acceptKw("SET"):
+		if s.Set, err = p.assignments(); err != nil {
+			return nil, err
+		}
+	case p.is("SELECT"), p.isOp("("):
+		if s.Select, err = p.selectStmt(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf("expect VALUES, SET or SELECT")
+	}
+
+	if p.acceptSeq("ON", "DUPLICATE", "KEY", "UPDATE") {
+		if s.OnDup, err = p.assignments(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (p *parser) assignments() ([]*Assignment, error) {
+	var list []*Assignment
+	for {
+		t := p.peek()
+		if t.Type != TokIdent && t.Type != TokQuotedIdent {
+			return nil, p.errorf("expect column")
+		}
+		p.pos++
+		col, err := p.columnRef(t)
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOp("=") && !p.acceptOp(":=") {
+			return nil, p.errorf("expect =")
+		}
+		v, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &Assignment{Column: col.(*ColumnRef), Value: v})
+		if !p.acceptOp(",") {
+			return list, nil
+		}
+	}
+}
+
+//where parse the optional WHERE, ORDER BY and LIMIT of UPDATE and DELETE.
+func (p *parser) where() (where Expr, orderBy []*OrderItem, limit *Limit, err error) {
+	if p.acceptKw("WHERE") {
+		if where, err = p.expr(); err != nil {
+			return
+		}
+	}
+	if orderBy, err = p.orderBy(); err != nil {
+		return
+	}
+	limit, err = p.limit()
+	return
+}
+
+func (p *parser) update() (Statement, error) {
+	p.pos++
+	s := &Update{}
+	for {
+		if p.acceptKw("LOW_PRIORITY") {
+			continue
+		}
+		if p.acceptKw("IGNORE") {
+			s.Ignore = true
+			continue
+		}
+		break
+	}
+	var err error
+	if s.Tables, err = p.tableRefs(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKw("SET"); err != nil {
+		return nil, err
+	}
+	if s.Set, err = p.assignments(); err != nil {
+		return nil, err
+	}
+	if s.Where, s.OrderBy, s.Limit, err = p.where(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//targets read the `t1[.*], t2[.*]` of multiple table DELETE.
+func (p *parser) targets() ([]*TableName, error) {
+	var list []*TableName
+	for {
+		t, err := p.tableName()
+		if err != nil {
+			return nil, err
+		}
+		if p.isOp(".") && p.peekN(1).Val == "*" {
+			p.pos += 2
+		}
+		list = append(list, t)
+		if !p.acceptOp(",") {
+			return list, nil
+		}
+	}
+}
+
+func (p *parser) delete() (Statement, error) {
+	p.pos++
+	s := &Delete{}
+	for {
+		switch p.peek().Keyword() {
+		case "LOW_PRIORITY", "QUICK":
+		case "IGNORE":
+			s.Ignore = true
+		default:
+			goto from
+		}
+		p.pos++
+	}
+
+from:
+	var err error
+	if p.acceptKw("FROM") {
+		if s.From, err = p.tableRefs(); err != nil {
+			return nil, err
+		}
+		//DELETE FROM t1, t2 USING ...
+		if p.acceptKw("USING") {
+			for _, ref := range s.From {
+				t, ok := ref.(*AliasedTable)
+				if !ok {
+					return nil, p.errorf("expect table name")
+				}
+				s.Targets = append(s.Targets, t.Table)
+			}
+			if s.From, err = p.tableRefs(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		//DELETE t1, t2 FROM ...
+		if s.Targets, err = p.targets(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKw("FROM"); err != nil {
+			return nil, err
+		}
+		if s.From, err = p.tableRefs(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Where, s.OrderBy, s.Limit, err = p.where(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+var ddlObjects = map[string]string{
+	"TABLE": "TABLE", "TABLES": "TABLE", "DATABASE": "DATABASE", "SCHEMA": "DATABASE",
+	"INDEX": "INDEX", "VIEW": "VIEW", "TRIGGER": "TRIGGER", "PROCEDURE": "PROCEDURE",
+	"FUNCTION": "FUNCTION", "EVENT": "EVENT", "USER": "USER", "TABLESPACE": "TABLESPACE",
+	"SERVER": "SERVER", "ROLE": "ROLE",
+}
+
+//ddl parse the object and names of the DDL, the definitions are skipped.
+func (p *parser) ddl() (Statement, error) {
+	s := &DDL{Action: firstWords[p.next().Keyword()]}
+	var err error
+	switch s.Action {
+	case StmtTruncate:
+		p.acceptKw("TABLE")
+		s.Object = "TABLE"
+		t, err := p.tableName()
+		if err != nil {
+			return nil, err
+		}
+		s.Tables = append(s.Tables, t)
+		return s, nil
+
+	case StmtRename:
+		if s.Object = ddlObjects[p.peek().Keyword()]; s.Object != "TABLE" {
+			s.Object = p.peek().Keyword()
+			p.skipRest()
+			return s, nil
+		}
+		p.pos++
+		for {
+			from, err := p.tableName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectKw("TO"); err != nil {
+				return nil, err
+			}
+			to, err := p.tableName()
+			if err != nil {
+				return nil, err
+			}
+			s.Tables = append(s.Tables, from, to)
+			if !p.acceptOp(",") {
+				return s, nil
+			}
+		}
+	}
+
+	//skip the modifiers, such as TEMPORARY, UNIQUE, OR REPLACE, DEFINER = user
+	for !p.eof() && ddlObjects[p.peek().Keyword()] == "" {
+		p.pos++
+	}
+	if p.eof() {
+		return s, nil
+	}
+	s.Object = ddlObjects[p.next().Keyword()]
+	s.IfNotExists = p.acceptSeq("IF", "NOT", "EXISTS")
+	s.IfExists = p.acceptSeq("IF", "EXISTS")
+
+	switch s.Object {
+	case "TABLE", "VIEW":
+		for {
+			t, err := p.tableName()
+			if err != nil {
+				return nil, err
+			}
+			s.Tables = append(s.Tables, t)
+			if s.Action != StmtDrop || !p.acceptOp(",") {
+				break
+			}
+		}
+		if s.Action == StmtCreate && s.Object == "TABLE" {
+			paren := p.acceptOp("(")
+			if p.acceptKw("LIKE") {
+				t, err := p.tableName()
+				if err != nil {
+					return nil, err
+				}
+				s.Tables = append(s.Tables, t)
+			} else if paren {
+				p.pos--
+			}
+		}
+	case "INDEX":
+		if s.Name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if p.acceptKw("USING") {
+			p.pos++
+		}
+		if p.acceptKw("ON") {
+			t, err := p.tableName()
+			if err != nil {
+				return nil, err
+			}
+			s.Tables = append(s.Tables, t)
+		}
+	default:
+		if t := p.next(); t.Type != TokOperator {
+			s.Name = t.Val
+		}
+	}
+	p.skipRest()
+	return s, nil
+}
+
+//scope read the GLOBAL, SESSION and LOCAL of SET and SHOW, LOCAL is SESSION.
+func (p *parser) scope() string {
+	switch kw := p.peek().Keyword(); kw {
+	case "GLOBAL", "SESSION", "PERSIST", "PERSIST_ONLY":
+		p.pos++
+		return kw
+	case "LOCAL":
+		p.pos++
+		return "SESSION"
+	}
+	return ""
+}
+
+func (p *parser) set() (Statement, error) {
+	p.pos++
+	s := &Set{}
+	for {
+		v := &SetVar{Scope: p.scope()}
+		t := p.next()
+		switch {
+		case t.Keyword() == "TRANSACTION":
+			start := p.peek().Pos
+			p.skipRest()
+			v.Name = "transaction"
+			v.Value = &Literal{Kind: LitString, Val: strings.ToUpper(strings.TrimSpace(string(p.sql[start:p.peek().Pos])))}
+			s.Vars = append(s.Vars, v)
+			return s, nil
+
+		case t.Type == TokVariable:
+			name := t.Val
+			if strings.HasPrefix(name, "@@") {
+				name = name[2:]
+				if i := strings.IndexByte(name, '.'); i >= 0 {
+					v.Scope = strings.ToUpper(name[:i])
+					if v.Scope == "LOCAL" {
+						v.Scope = "SESSION"
+					}
+					name = name[i+1:]
+				}
+			} else {
+				v.Scope = "USER"
+				name = strings.Trim(name[1:], "'\"`")
+			}
+			v.Name = strings.ToLower(name)
+
+		case t.Keyword() == "NAMES", t.Keyword() == "CHARSET", t.Keyword() == "CHARACTER" && p.acceptKw("SET"):
+			v.Name = "names"
+			if t.Keyword() != "NAMES" {
+				v.Name = "charset"
+			}
+			cs := p.next()
+			if cs.Type == TokOperator {
+				return nil, p.errorf("expect charset")
+			}
+			v.Value = &Literal{Kind: LitString, Val: cs.Val}
+			if v.Name == "names" && p.acceptKw("COLLATE") {
+				coll := p.next()
+				s.Vars = append(s.Vars, v)
+				v = &SetVar{Name: "collation_connection", Value: &Literal{Kind: LitString, Val: coll.Val}}
+			}
+
+		case t.Type == TokIdent, t.Type == TokQuotedIdent:
+			v.Name = strings.ToLower(t.Val)
+
+		default:
+			p.pos--
+			return nil, p.errorf("expect variable")
+		}
+
+		if v.Value == nil {
+			if !p.acceptOp("=") && !p.acceptOp(":=") {
+				return nil, p.errorf("expect =")
+			}
+			var err error
+			if v.Value, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		s.Vars = append(s.Vars, v)
+		if !p.acceptOp(",") {
+			return s, nil
+		}
+	}
+}
+
+//the SHOW kinds followed by FROM table.
+var showTableKinds = map[string]bool{
+	"COLUMNS": true, "FIELDS": true, "INDEX": true, "INDEXES": true, "KEYS": true,
+}
+
+func (p *parser) show() (Statement, error) {
+	p.pos++
+	s := &Show{}
+	var words []string
+	var err error
+	for p.peek().Type == TokIdent {
+		kw := p.peek().Keyword()
+		if kw == "FULL" && len(words) == 0 {
+			s.Full = true
+			p.pos++
+			continue
+		}
+		if len(words) == 0 {
+			if s.Scope = p.scope(); s.Scope != "" {
+				continue
+			}
+		}
+		switch kw {
+		case "FROM", "IN", "LIKE", "WHERE", "LIMIT", "FOR":
+			goto kind
+		}
+		words = append(words, kw)
+		p.pos++
+		if words[0] == "CREATE" && len(words) == 2 {
+			if s.Table, err = p.tableName(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+kind:
+	s.Kind = strings.Join(words, " ")
+	for !p.eof() && !p.isOp(";") {
+		switch {
+		case p.acceptKw("FROM"), p.acceptKw("IN"):
+			t, err := p.tableName()
+			if err != nil {
+				return nil, err
+			}
+			if s.Table == nil && showTableKinds[s.Kind] {
+				s.Table = t
+			} else {
+				s.From = t.Name
+			}
+		case p.acceptKw("LIKE"):
+			t := p.next()
+			if t.Type != TokString {
+				return nil, p.errorf("expect string")
+			}
+			s.Like = t.Val
+		case p.acceptKw("WHERE"):
+			if s.Where, err = p.expr(); err != nil {
+				return nil, err
+			}
+		default:
+			p.skipRest()
+		}
+	}
+	if s.Table != nil && s.Table.Schema == "" && s.From != "" {
+		s.Table.Schema = s.From
+	}
+	return s, nil
+}
+
+func (p *parser) transaction() (Statement, error) {
+	switch p.next().Keyword() {
+	case "BEGIN":
+		p.acceptKw("WORK")
+		return &Transaction{Action: StmtBegin}, nil
+	case "START":
+		if err := p.expectKw("TRANSACTION"); err != nil {
+			return nil, err
+		}
+		p.skipRest()
+		return &Transaction{Action: StmtBegin}, nil
+	case "COMMIT":
+		p.skipRest()
+		return &Transaction{Action: StmtCommit}, nil
+	}
+	p.acceptKw("WORK")
+	if p.is("TO") {
+		//ROLLBACK TO SAVEPOINT not end the transaction
+		p.skipRest()
+		return &Transaction{Action: StmtSavepoint}, nil
+	}
+	p.skipRest()
+	return &Transaction{Action: StmtRollback}, nil
+}
+
+func (p *parser) kill() (Statement, error) {
+	p.pos++
+	k := &Kill{}
+	if p.acceptKw("QUERY") {
+		k.Query = true
+	} else {
+		p.acceptKw("CONNECTION")
+	}
+	t := p.peek()
+	if t.Type != TokNumber {
+		return nil, p.errorf("expect thread id")
+	}
+	id, err := strconv.ParseUint(t.Val, 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid thread id")
+	}
+	p.pos++
+	k.ID = id
+	return k, nil
+}
@@ -0,0 +1,259 @@
+package parser
+
+import (
+	"testing"
+)
+
+func Test_Tokenize(t *testing.T) {
+	toks, err := Tokenize([]byte("SELECT `a``b`, 'it''s', 'x\\'y\\n', 1.5e-3, 0x1F, ? /* c */ FROM t -- tail\n WHERE a <=> @@session.x # end"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		typ TokenType
+		val string
+	}{
+		{TokIdent, "SELECT"}, {TokQuotedIdent, "a`b"}, {TokOperator, ","}, {TokString, "it's"}, {TokOperator, ","},
+		{TokString, "x'y\n"}, {TokOperator, ","}, {TokNumber, "1.5e-3"}, {TokOperator, ","}, {TokNumber, "0x1F"},
+		{TokOperator, ","}, {TokParam, "?"}, {TokIdent, "FROM"}, {TokIdent, "t"}, {TokIdent, "WHERE"},
+		{TokIdent, "a"}, {TokOperator, "<=>"}, {TokVariable, "@@session.x"},
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("got %d tokens %v, want %d", len(toks), toks, len(want))
+	}
+	for i, w := range want {
+		if toks[i].Type != w.typ || toks[i].Val != w.val {
+			t.Fatalf("token %d: got %v %q, want %v %q", i, toks[i].Type, toks[i].Val, w.typ, w.val)
+		}
+	}
+
+	tk := NewTokenizer([]byte(`'a\' /*c*/ /*!40101 x */`))
+	tk.NoBackslashEscapes = true
+	tk.KeepComments = true
+	for _, w := range []struct {
+		typ TokenType
+		val string
+	}{{TokString, `a\`}, {TokComment, "c"}, {TokIdent, "x"}} {
+		tok, err := tk.Next()
+		if err != nil || tok.Type != w.typ || tok.Val != w.val {
+			t.Fatalf("got %v %q %v, want %v %q", tok.Type, tok.Val, err, w.typ, w.val)
+		}
+	}
+
+	if _, err := Tokenize([]byte("select 'abc")); err == nil {
+		t.Fatal("expect unterminated string error")
+	}
+}
+
+func Test_Classify(t *testing.T) {
+	cases := map[string]StmtType{
+		"  /* hint */ select 1":      StmtSelect,
+		"(select 1) union select 2":  StmtSelect,
+		"insert into t values (1)":   StmtInsert,
+		"Replace into t values (1)":  StmtReplace,
+		"-- c\nupdate t set a = 1":   StmtUpdate,
+		"/*!40101 SET NAMES utf8 */": StmtSet,
+		"start transaction":          StmtBegin,
+		"desc t":                     StmtExplain,
+		"`select`":                   StmtUnknown,
+		"":                           StmtUnknown,
+	}
+	for sql, want := range cases {
+		if got := Classify([]byte(sql)); got != want {
+			t.Fatalf("%q: got %v, want %v", sql, got, want)
+		}
+	}
+
+	//the statement after the CTE list
+	cases = map[string]StmtType{
+		"with x as (select 1) select * from x":                                   StmtSelect,
+		"WITH x AS (SELECT 1) DELETE FROM t WHERE id IN (SELECT * FROM x)":       StmtDelete,
+		"with recursive x (n) as (select 1), y as (select 2) update t set a = 1": StmtUpdate,
+		"with x as (select 1) (select * from x)":                                 StmtSelect,
+		"with x as (select 1)":                                                   StmtUnknown,
+	}
+	for sql, want := range cases {
+		if got := Classify([]byte(sql)); got != want {
+			t.Fatalf("%q: got %v, want %v", sql, got, want)
+		}
+	}
+}
+
+func Test_ParseSelect(t *testing.T) {
+	stmt, err := Parse([]byte("SELECT DISTINCT u.id, count(*) AS c, name n FROM db.user u LEFT JOIN t2 ON u.id = t2.uid " +
+		"WHERE u.id IN (1, 2) AND NOT name LIKE 'a%' OR age BETWEEN ? AND 10 GROUP BY u.id HAVING c > 1 " +
+		"ORDER BY c DESC, 2 LIMIT 5, 10 FOR UPDATE;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := stmt.(*Select)
+	if !ok {
+		t.Fatalf("got %T", stmt)
+	}
+	if !s.Distinct || len(s.Fields) != 3 || s.Fields[1].Alias != "c" || s.Fields[2].Alias != "n" {
+		t.Fatalf("fields %+v", s.Fields)
+	}
+	if f, ok := s.Fields[1].Expr.(*FuncCall); !ok || f.Name != "COUNT" || !f.Star {
+		t.Fatalf("count %+v", s.Fields[1].Expr)
+	}
+	j, ok := s.From[0].(*Join)
+	if !ok || j.Kind != "LEFT JOIN" || j.On == nil {
+		t.Fatalf("from %+v", s.From[0])
+	}
+	if l := j.Left.(*AliasedTable); l.Table.Schema != "db" || l.Table.Name != "user" || l.Alias != "u" {
+		t.Fatalf("table %+v", l)
+	}
+	if or, ok := s.Where.(*BinaryExpr); !ok || or.Op != "OR" {
+		t.Fatalf("where %+v", s.Where)
+	}
+	if len(s.OrderBy) != 2 || !s.OrderBy[0].Desc || s.Limit == nil || s.Lock != "FOR UPDATE" {
+		t.Fatalf("select %+v", s)
+	}
+	if s.Limit.Offset.(*Literal).Val != "5" || s.Limit.Count.(*Literal).Val != "10" {
+		t.Fatalf("limit %+v", s.Limit)
+	}
+
+	tables := Tables(stmt)
+	if len(tables) != 2 || tables[1].Name != "t2" {
+		t.Fatalf("tables %v", tables)
+	}
+
+	sql := "select a from t where b = (select max(b) from t3) order by a"
+	stmt, err = Parse([]byte(sql))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := stmt.(*Select).LimitAt; at != len(sql) {
+		t.Fatalf("limit at %d", at)
+	}
+	if tables := Tables(stmt); len(tables) != 2 || tables[1].Name != "t3" {
+		t.Fatalf("tables %v", tables)
+	}
+
+	stmt, err = Parse([]byte("select a from t1 union all select b from t2 order by 1 limit 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, ok := stmt.(*Union)
+	if !ok || len(u.Selects) != 2 || !u.All[0] || u.Limit == nil || u.Selects[1].Limit != nil {
+		t.Fatalf("union %+v", stmt)
+	}
+}
+
+func Test_ParseStatements(t *testing.T) {
+	sqls := []string{
+		"select 1",
+		"select * from t where a is not null and b not in (select c from d) limit 1 offset 2",
+		"select case when a > 1 then 'x' else 'y' end, cast(a as unsigned), date_add(d, interval 1 day) from t",
+		"select group_concat(a order by b desc separator ','), trim(leading 'x' from s), position('a' in s) from t",
+		"select t.* from t force index (idx) join (select id from s) x using (id) lock in share mode",
+		"select -a + ~b * (c div 2) % 3, a->'$.k', binary a = 'x' collate utf8_bin from t",
+		"insert ignore into db.t (a, `b`) values (1, default), (?, 'x') on duplicate key update a = values(a)",
+		"insert into t set a = 1, b = now()",
+		"replace into t select * from s",
+		"update low_priority t1 join t2 on t1.id = t2.id set t1.a = t2.b where t2.c = 1 order by t1.id limit 10",
+		"delete from t where id = 1 limit 1",
+		"delete t1, t2 from t1 inner join t2 where t1.id = t2.id",
+		"delete from t1 using t1 join t2",
+		"create table if not exists t (id int primary key) engine = innodb",
+		"create unique index idx on t (a, b)",
+		"drop table if exists t1, t2",
+		"alter table t add column c int",
+		"truncate t",
+		"rename table a to b, c to d",
+		"set names utf8mb4 collate utf8mb4_bin, autocommit = 1, @@session.sql_mode = '', @x := 5",
+		"set session transaction isolation level read committed",
+		"use db",
+		"show full processlist",
+		"show global variables like 'max%'",
+		"show columns from t from db",
+		"show create table db.t",
+		"begin",
+		"start transaction read only",
+		"commit",
+		"rollback to savepoint s",
+		"kill query 42",
+		"load data infile 'x' into table t",
+	}
+	for _, sql := range sqls {
+		if _, err := Parse([]byte(sql)); err != nil {
+			t.Fatalf("%v: %v", sql, err)
+		}
+	}
+
+	for _, sql := range []string{
+		"select from t",
+		"select a from t where",
+		"insert into t",
+		"select a from t limit x y",
+		"kill abc",
+	} {
+		if _, err := Parse([]byte(sql)); err == nil {
+			t.Fatalf("%v: expect error", sql)
+		}
+	}
+}
+
+func Test_ParseDetail(t *testing.T) {
+	stmt, _ := Parse([]byte("set names utf8mb4 collate utf8mb4_bin, @@global.max_connections = 10, @`u` = 1"))
+	vars := stmt.(*Set).Vars
+	if len(vars) != 4 || vars[0].Name != "names" || vars[1].Name != "collation_connection" ||
+		vars[2].Scope != "GLOBAL" || vars[2].Name != "max_connections" || vars[3].Scope != "USER" || vars[3].Name != "u" {
+		t.Fatalf("set %+v %+v %+v %+v", vars[0], vars[1], vars[2], vars[3])
+	}
+
+	stmt, _ = Parse([]byte("show full processlist"))
+	if s := stmt.(*Show); !s.Full || s.Kind != "PROCESSLIST" {
+		t.Fatalf("show %+v", s)
+	}
+	stmt, _ = Parse([]byte("show index from t in db"))
+	if s := stmt.(*Show); s.Kind != "INDEX" || s.Table.Name != "t" || s.Table.Schema != "db" {
+		t.Fatalf("show %+v", s)
+	}
+
+	stmt, _ = Parse([]byte("kill 7"))
+	if k := stmt.(*Kill); k.Query || k.ID != 7 {
+		t.Fatalf("kill %+v", k)
+	}
+
+	stmt, _ = Parse([]byte("drop index idx on db.t"))
+	if d := stmt.(*DDL); d.Action != StmtDrop || d.Object != "INDEX" || d.Name != "idx" || d.Tables[0].Name != "t" {
+		t.Fatalf("ddl %+v", d)
+	}
+
+	stmt, _ = Parse([]byte("insert into t (a, b) values (1, 2), (3, 4)"))
+	if s := stmt.(*Insert); len(s.Columns) != 2 || len(s.Rows) != 2 || s.Rows[1][0].(*Literal).Val != "3" {
+		t.Fatalf("insert %+v", s)
+	}
+
+	stmt, _ = Parse([]byte("delete t1 from t1 join t2 on t1.id = t2.id"))
+	if d := stmt.(*Delete); len(d.Targets) != 1 || d.Targets[0].Name != "t1" || len(Tables(d)) != 3 {
+		t.Fatalf("delete %+v", d)
+	}
+
+	stmt, _ = Parse([]byte("rollback"))
+	if stmt.Type() != StmtRollback {
+		t.Fatalf("got %v", stmt.Type())
+	}
+}
+
+func Test_Fingerprint(t *testing.T) {
+	for _, c := range []struct{ sql, want string }{
+		{"SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'a'", "select * from t where id in(?+) and name = ?"},
+		{"select  a.b,count(*) from `T` /*igo:master*/ where x=-1.5 and y > ? limit 10;", "select a.b, count(*) from t where x = ? and y > ? limit ?"},
+		{"select a - 1 from t where id in (select id from u)", "select a - ? from t where id in(select id from u)"},
+		{"INSERT INTO t(a, b) VALUES (1, 'x'), (2, now()) ON DUPLICATE KEY UPDATE b = VALUES(b)",
+			"insert into t(a, b) values(?+) on duplicate key update b = values(b)"},
+		{"insert into t values (?, ?)", "insert into t values(?+)"},
+	} {
+		if got := Fingerprint([]byte(c.sql)); got != c.want {
+			t.Fatalf("%s:\ngot  %s\nwant %s", c.sql, got, c.want)
+		}
+	}
+	if Fingerprint([]byte("select 1")) != Fingerprint([]byte("SELECT  2")) {
+		t.Fatal("same shape, different digest")
+	}
+	if id := DigestID("select ?"); len(id) != 16 {
+		t.Fatal(id)
+	}
+}
@@ -0,0 +1,377 @@
+//Package parser is the sql tokenizer, parser and statement classifier of igo.
+//It understands the common MySQL DML and DDL statements, which is enough for
+//routing, firewall, digest and rewriting, it is not a full MySQL grammar.
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//TokenType the type of the token.
+type TokenType int
+
+//token types
+const (
+	TokIdent       TokenType = iota //identifier and keyword
+	TokQuotedIdent                  //`identifier`
+	TokNumber                       //number, hex and bit literal
+	TokString                       //quoted string
+	TokParam                        //? placeholder
+	TokVariable                     //@user_var, @@system_var
+	TokComment                      //comment, only returned when KeepComments
+	TokOperator                     //punctuation and operator
+)
+
+var tokenNames = map[TokenType]string{
+	TokIdent:       "ident",
+	TokQuotedIdent: "quoted ident",
+	TokNumber:      "number",
+	TokString:      "string",
+	TokParam:       "param",
+	TokVariable:    "variable",
+	TokComment:     "comment",
+	TokOperator:    "operator",
+}
+
+func (t TokenType) String() string {
+	if s, ok := tokenNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+var (
+	errUnterminatedString  = errors.New("unterminated string")
+	errUnterminatedComment = errors.New("unterminated comment")
+	errUnterminatedQuoted  = errors.New("unterminated quoted identifier")
+)
+
+//Token a token of the sql.
+type Token struct {
+	Type TokenType
+	//Val is the unescaped content of string and quoted identifier,
+	//the comment text without delimiters, or the raw text for others.
+	Val   string
+	Pos   int //offset of the token in the sql
+	End   int
+	Param int //placeholder index of TokParam
+}
+
+//Keyword return the upper case word, empty if the token is not a bare word.
+func (t Token) Keyword() string {
+	if t.Type != TokIdent {
+		return ""
+	}
+	return strings.ToUpper(t.Val)
+}
+
+//Name return the lower case identifier, empty if the token is not an identifier.
+func (t Token) Name() string {
+	if t.Type != TokIdent && t.Type != TokQuotedIdent {
+		return ""
+	}
+	return strings.ToLower(t.Val)
+}
+
+//IsLiteral the token is a number, string or placeholder.
+func (t Token) IsLiteral() bool {
+	return t.Type == TokNumber || t.Type == TokString || t.Type == TokParam
+}
+
+//multi characters operators, longest first.
+var operators = []string{
+	"<=>", "->>",
+	"<=", ">=", "<>", "!=", "||", "&&", ":=", "<<", ">>", "->",
+}
+
+//Tokenizer split the sql into tokens.
+type Tokenizer struct {
+	sql []byte
+	pos int
+
+	//NoBackslashEscapes treat backslash as a normal character in strings,
+	//as the NO_BACKSLASH_ESCAPES sql mode, only doubled quote is escape.
+	NoBackslashEscapes bool
+	//KeepComments return the comments as TokComment,
+	//the executable comment /*!...*/ is always tokenized as sql.
+	KeepComments bool
+
+	params    int
+	versioned bool //in /*! ... */
+}
+
+//NewTokenizer new tokenizer of the sql.
+func NewTokenizer(sql []byte) *Tokenizer {
+	return &Tokenizer{sql: sql}
+}
+
+//Tokenize split the sql into tokens without comments, the tokens before the error are returned.
+func Tokenize(sql []byte) ([]Token, error) {
+	t := NewTokenizer(sql)
+	toks := make([]Token, 0, len(sql)/4)
+	for {
+		tok, err := t.Next()
+		if err == io.EOF {
+			return toks, nil
+		}
+		if err != nil {
+			return toks, err
+		}
+		toks = append(toks, tok)
+	}
+}
+
+//Next return the next token, io.EOF at the end of the sql.
+func (t *Tokenizer) Next() (Token, error) {
+	for t.pos < len(t.sql) {
+		start := t.pos
+		c := t.sql[t.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			t.pos++
+
+		case c == '#' || (c == '-' && t.peek(1) == '-' && (isSpace(t.peek(2)) || t.pos+2 == len(t.sql))):
+			end := t.pos
+			for end < len(t.sql) && t.sql[end] != '\n' {
+				end++
+			}
+			t.pos = end
+			if t.KeepComments {
+				skip := 1
+				if c == '-' {
+					skip = 2
+				}
+				return Token{Type: TokComment, Val: string(t.sql[start+skip : end]), Pos: start, End: end}, nil
+			}
+
+		case c == '/' && t.peek(1) == '*':
+			if t.peek(2) == '!' {
+				//executable comment, skip the version and tokenize the content
+				t.pos += 3
+				for t.pos < len(t.sql) && t.sql[t.pos] >= '0' && t.sql[t.pos] <= '9' {
+					t.pos++
+				}
+				t.versioned = true
+				continue
+			}
+			end := strings.Index(string(t.sql[t.pos+2:]), "*/")
+			if end < 0 {
+				t.pos = len(t.sql)
+				return Token{}, errUnterminatedComment
+			}
+			t.pos += 2 + end + 2
+			if t.KeepComments {
+				return Token{Type: TokComment, Val: string(t.sql[start+2 : t.pos-2]), Pos: start, End: t.pos}, nil
+			}
+
+		case c == '*' && t.peek(1) == '/' && t.versioned:
+			t.versioned = false
+			t.pos += 2
+
+		case c == '\'' || c == '"':
+			s, err := t.scanString(c)
+			if err != nil {
+				return Token{}, err
+			}
+			return Token{Type: TokString, Val: s, Pos: start, End: t.pos}, nil
+
+		case c == '`':
+			s, err := t.scanQuoted()
+			if err != nil {
+				return Token{}, err
+			}
+			return Token{Type: TokQuotedIdent, Val: s, Pos: start, End: t.pos}, nil
+
+		case (c == 'x' || c == 'X' || c == 'b' || c == 'B') && t.peek(1) == '\'':
+			//X'4D7953514C', B'0101'
+			end := strings.IndexByte(string(t.sql[t.pos+2:]), '\'')
+			if end < 0 {
+				t.pos = len(t.sql)
+				return Token{}, errUnterminatedString
+			}
+			t.pos += 2 + end + 1
+			return Token{Type: TokNumber, Val: string(t.sql[start:t.pos]), Pos: start, End: t.pos}, nil
+
+		case (c == 'n' || c == 'N') && t.peek(1) == '\'':
+			//N'national string'
+			t.pos++
+			s, err := t.scanString('\'')
+			if err != nil {
+				return Token{}, err
+			}
+			return Token{Type: TokString, Val: s, Pos: start, End: t.pos}, nil
+
+		case isDigit(c) || (c == '.' && isDigit(t.peek(1))):
+			t.scanNumber()
+			return Token{Type: TokNumber, Val: string(t.sql[start:t.pos]), Pos: start, End: t.pos}, nil
+
+		case isIdentChar(c):
+			for t.pos < len(t.sql) && isIdentChar(t.sql[t.pos]) {
+				t.pos++
+			}
+			return Token{Type: TokIdent, Val: string(t.sql[start:t.pos]), Pos: start, End: t.pos}, nil
+
+		case c == '?':
+			t.pos++
+			t.params++
+			return Token{Type: TokParam, Val: "?", Pos: start, End: t.pos, Param: t.params - 1}, nil
+
+		case c == '@':
+			return t.scanVariable()
+
+		default:
+			for _, op := range operators {
+				if strings.HasPrefix(string(t.sql[t.pos:min(t.pos+len(op), len(t.sql))]), op) {
+					t.pos += len(op)
+					return Token{Type: TokOperator, Val: op, Pos: start, End: t.pos}, nil
+				}
+			}
+			t.pos++
+			return Token{Type: TokOperator, Val: string(c), Pos: start, End: t.pos}, nil
+		}
+	}
+	return Token{}, io.EOF
+}
+
+func (t *Tokenizer) peek(n int) byte {
+	if t.pos+n < len(t.sql) {
+		return t.sql[t.pos+n]
+	}
+	return 0
+}
+
+// scanString read the quoted string, support the backslash escapes the
+// escapeBytesBackslash produced, and the doubled quote escapeBytesQuotes produced.
+// https://dev.mysql.com/doc/refman/5.7/en/string-literals.html
+func (t *Tokenizer) scanString(q byte) (string, error) {
+	t.pos++
+	var b []byte
+	for t.pos < len(t.sql) {
+		c := t.sql[t.pos]
+		switch {
+		case c == '\\' && !t.NoBackslashEscapes && t.pos+1 < len(t.sql):
+			t.pos++
+			switch e := t.sql[t.pos]; e {
+			case '0':
+				b = append(b, 0)
+			case 'b':
+				b = append(b, '\b')
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'Z':
+				b = append(b, '\x1a')
+			case '%', '_':
+				b = append(b, '\\', e)
+			default:
+				b = append(b, e)
+			}
+		case c == q:
+			if t.peek(1) != q {
+				t.pos++
+				return string(b), nil
+			}
+			b = append(b, q)
+			t.pos++
+		default:
+			b = append(b, c)
+		}
+		t.pos++
+	}
+	return "", errUnterminatedString
+}
+
+func (t *Tokenizer) scanQuoted() (string, error) {
+	t.pos++
+	var b []byte
+	for t.pos < len(t.sql) {
+		c := t.sql[t.pos]
+		if c == '`' {
+			if t.peek(1) != '`' {
+				t.pos++
+				return string(b), nil
+			}
+			t.pos++
+		}
+		b = append(b, c)
+		t.pos++
+	}
+	return "", errUnterminatedQuoted
+}
+
+func (t *Tokenizer) scanNumber() {
+	//hex 0x1F and bit 0b01
+	if t.sql[t.pos] == '0' && (t.peek(1) == 'x' || t.peek(1) == 'X' || t.peek(1) == 'b' || t.peek(1) == 'B') {
+		t.pos += 2
+		for t.pos < len(t.sql) && isIdentChar(t.sql[t.pos]) {
+			t.pos++
+		}
+		return
+	}
+	for t.pos < len(t.sql) {
+		c := t.sql[t.pos]
+		switch {
+		case isDigit(c) || c == '.':
+			t.pos++
+		case (c == 'e' || c == 'E') && (isDigit(t.peek(1)) || ((t.peek(1) == '-' || t.peek(1) == '+') && isDigit(t.peek(2)))):
+			t.pos += 2
+		case isIdentChar(c):
+			//identifier begin with digits, such as 1a
+			for t.pos < len(t.sql) && isIdentChar(t.sql[t.pos]) {
+				t.pos++
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+//scanVariable read @var, @'var', @`var`, @@var and @@global.var
+func (t *Tokenizer) scanVariable() (Token, error) {
+	start := t.pos
+	t.pos++
+	if t.peek(0) == '@' {
+		t.pos++
+	}
+	switch c := t.peek(0); {
+	case c == '\'' || c == '"':
+		if _, err := t.scanString(c); err != nil {
+			return Token{}, err
+		}
+	case c == '`':
+		if _, err := t.scanQuoted(); err != nil {
+			return Token{}, err
+		}
+	default:
+		for t.pos < len(t.sql) && (isIdentChar(t.sql[t.pos]) || t.sql[t.pos] == '.') {
+			t.pos++
+		}
+	}
+	return Token{Type: TokVariable, Val: string(t.sql[start:t.pos]), Pos: start, End: t.pos}, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '$' || c >= 0x80
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,371 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+//Column the column definition of the result set built by igo.
+type Column struct {
+	Name     string
+	Table    string
+	Type     byte //FieldTypeXxx
+	Flags    FieldFlag
+	Charset  byte   //the collation id, 0 means utf8_general_ci for the strings and binary for the others
+	Length   uint32 //the max display length, 0 means the default of the type
+	Decimals byte
+}
+
+//StringColumns the VAR_STRING columns of the names.
+func StringColumns(names ...string) []Column {
+	cols := make([]Column, len(names))
+	for i, name := range names {
+		cols[i] = Column{Name: name, Type: FieldTypeVarString}
+	}
+	return cols
+}
+
+//isString the values of the type are sent as the strings in the binary protocol.
+func isString(t byte) bool {
+	switch t {
+	case FieldTypeTiny, FieldTypeShort, FieldTypeYear, FieldTypeInt24, FieldTypeLong, FieldTypeLongLong,
+		FieldTypeFloat, FieldTypeDouble, FieldTypeDate, FieldTypeNewDate, FieldTypeDateTime,
+		FieldTypeTimestamp, FieldTypeTime, FieldTypeNULL:
+		return false
+	}
+	return true
+}
+
+var defaultLength = map[byte]uint32{
+	FieldTypeTiny:      4,
+	FieldTypeShort:     6,
+	FieldTypeYear:      4,
+	FieldTypeInt24:     9,
+	FieldTypeLong:      11,
+	FieldTypeLongLong:  20,
+	FieldTypeFloat:     12,
+	FieldTypeDouble:    22,
+	FieldTypeDate:      10,
+	FieldTypeNewDate:   10,
+	FieldTypeTime:      10,
+	FieldTypeDateTime:  19,
+	FieldTypeTimestamp: 19,
+}
+
+//Packet the column definition packet payload of the protocol 41.
+func (c *Column) Packet() []byte {
+	charset := c.Charset
+	if charset == 0 {
+		charset = Collations["binary"]
+		if isString(c.Type) {
+			charset = Collations[DefaultCollation]
+		}
+	}
+	length := c.Length
+	if length == 0 {
+		if length = defaultLength[c.Type]; length == 0 {
+			length = 255 * 3
+		}
+	}
+	data := make([]byte, 0, 32+len(c.Name)*2+len(c.Table)*2)
+	data = appendLengthEncodedString(data, "def")
+	data = append(data, 0) //schema
+	data = appendLengthEncodedString(data, c.Table)
+	data = appendLengthEncodedString(data, c.Table)
+	data = appendLengthEncodedString(data, c.Name)
+	data = appendLengthEncodedString(data, c.Name)
+	data = append(data, 0x0c, charset, 0)
+	data = append(data, byte(length), byte(length>>8), byte(length>>16), byte(length>>24))
+	data = append(data, c.Type, byte(c.Flags), byte(c.Flags>>8), c.Decimals, 0, 0)
+	return data
+}
+
+//ResultSet build the result set packet payloads from the go values, which writeResultPackets can send.
+//The values can be nil, the integers, the floats, bool, string, []byte, time.Time and time.Duration.
+type ResultSet struct {
+	Columns []Column
+	Rows    [][]interface{}
+	Status  StatusFlag //the status of the EOF packets
+}
+
+//NewResultSet new the result set of the columns.
+func NewResultSet(columns ...Column) *ResultSet {
+	return &ResultSet{Columns: columns, Status: StatusInAutocommit}
+}
+
+//AddRow add a row, the values are in the order of the columns.
+func (r *ResultSet) AddRow(values ...interface{}) error {
+	if len(values) != len(r.Columns) {
+		return fmt.Errorf("resultset: %d values for %d columns", len(values), len(r.Columns))
+	}
+	r.Rows = append(r.Rows, values)
+	return nil
+}
+
+func (r *ResultSet) eof() []byte {
+	return []byte{HeaderEOF, 0, 0, byte(r.Status), byte(r.Status >> 8)}
+}
+
+//header the column count, the column definitions and EOF.
+func (r *ResultSet) header() [][]byte {
+	res := make([][]byte, 0, len(r.Columns)+len(r.Rows)+3)
+	res = append(res, appendLengthEncodedInteger(nil, uint64(len(r.Columns))))
+	for i := range r.Columns {
+		res = append(res, r.Columns[i].Packet())
+	}
+	return append(res, r.eof())
+}
+
+//TextPackets the payloads of the text protocol, the response of COM_QUERY.
+func (r *ResultSet) TextPackets() ([][]byte, error) {
+	res := r.header()
+	for _, row := range r.Rows {
+		var data []byte
+		for i, v := range row {
+			if v == nil {
+				data = append(data, 0xfb)
+				continue
+			}
+			s, err := textValue(&r.Columns[i], v)
+			if err != nil {
+				return nil, err
+			}
+			data = appendLengthEncodedInteger(data, uint64(len(s)))
+			data = append(data, s...)
+		}
+		res = append(res, data)
+	}
+	return append(res, r.eof()), nil
+}
+
+//BinaryPackets the payloads of the binary protocol, the response of COM_STMT_EXECUTE.
+func (r *ResultSet) BinaryPackets() ([][]byte, error) {
+	res := r.header()
+	for _, row := range r.Rows {
+		//header, the null bitmap with the offset 2
+		data := make([]byte, 1+(len(row)+7+2)/8, 64)
+		for i, v := range row {
+			if v == nil {
+				pos := i + 2
+				data[1+pos/8] |= 1 << uint(pos%8)
+				continue
+			}
+			var err error
+			if data, err = appendBinaryValue(data, &r.Columns[i], v); err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, data)
+	}
+	return append(res, r.eof()), nil
+}
+
+func textValue(c *Column, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+	case time.Time:
+		return []byte(formatTime(c, v)), nil
+	case time.Duration:
+		return []byte(formatDuration(c, v)), nil
+	}
+	if n, ok := toInt64(v); ok {
+		return strconv.AppendInt(nil, n, 10), nil
+	}
+	if n, ok := toUint64(v); ok {
+		return strconv.AppendUint(nil, n, 10), nil
+	}
+	return nil, fmt.Errorf("resultset: column %v: unsupported value %T", c.Name, v)
+}
+
+func appendBinaryValue(data []byte, c *Column, v interface{}) ([]byte, error) {
+	switch c.Type {
+	case FieldTypeTiny, FieldTypeShort, FieldTypeYear, FieldTypeInt24, FieldTypeLong, FieldTypeLongLong:
+		n, ok := toUint64(v)
+		if !ok {
+			return nil, fmt.Errorf("resultset: column %v: %T is not an integer", c.Name, v)
+		}
+		switch c.Type {
+		case FieldTypeTiny:
+			return append(data, byte(n)), nil
+		case FieldTypeShort, FieldTypeYear:
+			return append(data, byte(n), byte(n>>8)), nil
+		case FieldTypeInt24, FieldTypeLong:
+			return append(data, byte(n), byte(n>>8), byte(n>>16), byte(n>>24)), nil
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], n)
+		return append(data, b[:]...), nil
+
+	case FieldTypeFloat, FieldTypeDouble:
+		f, ok := toFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("resultset: column %v: %T is not a number", c.Name, v)
+		}
+		if c.Type == FieldTypeFloat {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(f)))
+			return append(data, b[:]...), nil
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		return append(data, b[:]...), nil
+
+	case FieldTypeDate, FieldTypeNewDate, FieldTypeDateTime, FieldTypeTimestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("resultset: column %v: %T is not a time.Time", c.Name, v)
+		}
+		return appendBinaryTime(data, c.Type, t), nil
+
+	case FieldTypeTime:
+		d, ok := v.(time.Duration)
+		if !ok {
+			return nil, fmt.Errorf("resultset: column %v: %T is not a time.Duration", c.Name, v)
+		}
+		return appendBinaryDuration(data, d), nil
+	}
+
+	s, err := textValue(c, v)
+	if err != nil {
+		return nil, err
+	}
+	data = appendLengthEncodedInteger(data, uint64(len(s)))
+	return append(data, s...), nil
+}
+
+//appendBinaryTime the length, year, month, day, hour, minute, second and microsecond, the trailing zeros are omitted.
+func appendBinaryTime(data []byte, typ byte, t time.Time) []byte {
+	year := uint16(t.Year())
+	micro := uint32(t.Nanosecond() / 1000)
+	switch {
+	case typ == FieldTypeDate || typ == FieldTypeNewDate || (t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && micro == 0):
+		return append(data, 4, byte(year), byte(year>>8), byte(t.Month()), byte(t.Day()))
+	case micro == 0:
+		return append(data, 7, byte(year), byte(year>>8), byte(t.Month()), byte(t.Day()),
+			byte(t.Hour()), byte(t.Minute()), byte(t.Second()))
+	}
+	return append(data, 11, byte(year), byte(year>>8), byte(t.Month()), byte(t.Day()),
+		byte(t.Hour()), byte(t.Minute()), byte(t.Second()),
+		byte(micro), byte(micro>>8), byte(micro>>16), byte(micro>>24))
+}
+
+//appendBinaryDuration the length, negative, days, hours, minutes, seconds and microseconds.
+func appendBinaryDuration(data []byte, d time.Duration) []byte {
+	if d == 0 {
+		return append(data, 0)
+	}
+	var neg byte
+	if d < 0 {
+		neg, d = 1, -d
+	}
+	days := uint32(d / (24 * time.Hour))
+	d -= time.Duration(days) * 24 * time.Hour
+	h, m, s := byte(d/time.Hour), byte(d%time.Hour/time.Minute), byte(d%time.Minute/time.Second)
+	micro := uint32(d % time.Second / time.Microsecond)
+	if micro == 0 {
+		return append(data, 8, neg, byte(days), byte(days>>8), byte(days>>16), byte(days>>24), h, m, s)
+	}
+	return append(data, 12, neg, byte(days), byte(days>>8), byte(days>>16), byte(days>>24), h, m, s,
+		byte(micro), byte(micro>>8), byte(micro>>16), byte(micro>>24))
+}
+
+func formatTime(c *Column, t time.Time) string {
+	switch c.Type {
+	case FieldTypeDate, FieldTypeNewDate:
+		return t.Format("2006-01-02")
+	case FieldTypeTime:
+		return t.Format("15:04:05")
+	}
+	if c.Decimals > 0 && c.Decimals <= 6 {
+		return t.Format("2006-01-02 15:04:05." + "000000"[:c.Decimals])
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func formatDuration(c *Column, d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+	if c.Decimals > 0 && c.Decimals <= 6 {
+		s += fmt.Sprintf(".%06d", d%time.Second/time.Microsecond)[:c.Decimals+1]
+	}
+	return s
+}
+
+func appendLengthEncodedString(b []byte, s string) []byte {
+	b = appendLengthEncodedInteger(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func toUint64(v interface{}) (uint64, bool) {
+	switch v := v.(type) {
+	case uint:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	}
+	if n, ok := toInt64(v); ok {
+		return uint64(n), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	if n, ok := toUint64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_ResultSet(t *testing.T) {
+	rs := NewResultSet(
+		Column{Name: "id", Type: FieldTypeLongLong, Flags: FlagNotNULL | FlagUnsigned},
+		Column{Name: "name", Type: FieldTypeVarString},
+		Column{Name: "at", Type: FieldTypeDateTime},
+		Column{Name: "rate", Type: FieldTypeDouble},
+	)
+	if err := rs.AddRow(1); err == nil {
+		t.Fatal("expect the count error")
+	}
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := rs.AddRow(uint32(7), "igo", at, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.AddRow(8, nil, at, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := rs.TextPackets()
+	if err != nil || len(text) != 1+4+1+2+1 {
+		t.Fatal(len(text), err)
+	}
+	if !bytes.Equal(text[0], []byte{4}) || text[5][0] != HeaderEOF || text[8][0] != HeaderEOF {
+		t.Fatal(text)
+	}
+	//catalog, schema, table, org_table, name, org_name, then the 12 bytes from 0x0c
+	col := text[2]
+	if !bytes.Equal(col[:12], []byte{3, 'd', 'e', 'f', 0, 0, 0, 4, 'n', 'a', 'm', 'e'}) || col[len(col)-12] != Collations[DefaultCollation] {
+		t.Fatal(col)
+	}
+	if col = text[1]; col[len(col)-6] != FieldTypeLongLong || col[len(col)-12] != Collations["binary"] || col[len(col)-5] != byte(FlagNotNULL|FlagUnsigned) {
+		t.Fatal(col)
+	}
+	want := "\x017\x03igo\x132020-01-02 03:04:05\x030.5"
+	if string(text[6]) != want {
+		t.Fatalf("%q", text[6])
+	}
+	if want := "\x018\xfb\x132020-01-02 03:04:05\xfb"; string(text[7]) != want {
+		t.Fatalf("%q", text[7])
+	}
+
+	bin, err := rs.BinaryPackets()
+	if err != nil || len(bin) != 9 {
+		t.Fatal(len(bin), err)
+	}
+	row := []byte{0, 0, 7, 0, 0, 0, 0, 0, 0, 0, 3, 'i', 'g', 'o', 7, 0xe4, 0x07, 1, 2, 3, 4, 5, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f}
+	if !bytes.Equal(bin[6], row) {
+		t.Fatalf("%v\n%v", bin[6], row)
+	}
+	//name is the bit 3, rate is the bit 5
+	if bin[7][1] != 1<<3|1<<5 || len(bin[7]) != 2+8+8 {
+		t.Fatal(bin[7])
+	}
+
+	rs = NewResultSet(Column{Name: "t", Type: FieldTypeTime}, Column{Name: "n", Type: FieldTypeTiny})
+	if err := rs.AddRow(-(26*time.Hour + 3*time.Second), true); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := rs.TextPackets(); string(text[4]) != "\x09-26:00:03\x011" {
+		t.Fatalf("%q", text[4])
+	}
+	if bin, _ := rs.BinaryPackets(); !bytes.Equal(bin[4], []byte{0, 0, 8, 1, 1, 0, 0, 0, 2, 0, 3, 1}) {
+		t.Fatal(bin[4])
+	}
+	if err := rs.AddRow("x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.BinaryPackets(); err == nil {
+		t.Fatal("expect the type error")
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+import (
+	"igo/log"
+	"igo/mysql"
+	"igo/utils"
+)
+
+//adminCommand an admin statement and its handler, the handler write the response.
+type adminCommand struct {
+	re     *regexp.Regexp
+	handle func(s *Server, c *Client, args []string) error
+}
+
+//adminCommands the statements of the admin port, the trailing ";" is trimmed before matching.
+var adminCommands = []adminCommand{
+	{regexp.MustCompile(`(?i)^show\s+proxy\s+clients$`), (*Server).showClients},
+	{regexp.MustCompile(`(?i)^show\s+proxy\s+backends$`), (*Server).showBackends},
+	{regexp.MustCompile(`(?i)^show\s+proxy\s+pools$`), (*Server).showPools},
+	{regexp.MustCompile("(?i)^set\\s+backend\\s+['\"`]?([^'\"`\\s]+)['\"`]?\\s+(offline|online)$"), (*Server).setBackend},
+	{regexp.MustCompile(`(?i)^kill\s+client\s+(\d+)$`), (*Server).killClient},
+	{regexp.MustCompile(`(?i)^reload\s+config$`), (*Server).reloadConfig},
+	//sent by the mysql cli on connect
+	{regexp.MustCompile(`(?i)^select\s+@@version_comment(\s+limit\s+1)?$`), (*Server).versionComment},
+}
+
+//runAdmin accept the admin clients, they speak the mysql protocol and the statements are answered by igo.
+func (s *Server) runAdmin(addr string) (net.Listener, error) {
+	ls, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Alertf("Admin Running on addr: %v", ls.Addr())
+	go func() {
+		for {
+			conn, err := ls.Accept()
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					continue
+				}
+				log.Error(err)
+				return
+			}
+			go s.handleAdmin(conn.(*net.TCPConn))
+		}
+	}()
+	return ls, nil
+}
+
+func (s *Server) handleAdmin(conn *net.TCPConn) {
+	defer utils.PrintPanicStack()
+	defer conn.Close()
+
+	c, _ := newClient(conn, &s.config().Server)
+	c.admin = true
+	if err := c.Handshake(); err != nil {
+		log.Errorf("Admin %v: %v", c.Addr(), err)
+		return
+	}
+	log.Warnf("Admin login: %v@%v", c.user, c.Addr())
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		switch data[0] {
+		case mysql.ComQuit:
+			return
+		case mysql.ComPing, mysql.ComInitDB:
+			err = c.writeOK()
+		case mysql.ComQuery:
+			err = s.execAdmin(c, strings.TrimRight(strings.TrimSpace(string(data[1:])), "; \t\r\n"))
+		default:
+			err = c.writeError(mysql.NewErr(mysql.ErrUnknownCom))
+		}
+		if err != nil {
+			log.Errorf("Admin %v: %v", c.Addr(), err)
+			return
+		}
+		c.sequence = 0
+	}
+}
+
+//execAdmin run the admin statement.
+func (s *Server) execAdmin(c *Client, sql string) error {
+	for _, cmd := range adminCommands {
+		if m := cmd.re.FindStringSubmatch(sql); m != nil {
+			log.Infof("Admin %v@%v: %v", c.user, c.Addr(), sql)
+			return cmd.handle(s, c, m[1:])
+		}
+	}
+	return c.writeError(mysql.NewErrf(mysql.ErrParse, "unknown admin statement: %.80s", sql))
+}
+
+func (s *Server) showClients(c *Client, args []string) error {
+	rs := mysql.NewResultSet(
+		mysql.Column{Name: "Id", Type: mysql.FieldTypeLongLong, Flags: mysql.FlagNotNULL | mysql.FlagUnsigned},
+		mysql.Column{Name: "User", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "Host", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "db", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "Command", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "Time", Type: mysql.FieldTypeLong, Flags: mysql.FlagNotNULL},
+		mysql.Column{Name: "Info", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "Backend", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "InTx", Type: mysql.FieldTypeTiny, Flags: mysql.FlagNotNULL},
+		mysql.Column{Name: "BytesIn", Type: mysql.FieldTypeLongLong, Flags: mysql.FlagNotNULL | mysql.FlagUnsigned},
+		mysql.Column{Name: "BytesOut", Type: mysql.FieldTypeLongLong, Flags: mysql.FlagNotNULL | mysql.FlagUnsigned},
+		mysql.Column{Name: "Queries", Type: mysql.FieldTypeLongLong, Flags: mysql.FlagNotNULL | mysql.FlagUnsigned},
+		mysql.Column{Name: "Connected", Type: mysql.FieldTypeLong, Flags: mysql.FlagNotNULL},
+	)
+	for _, se := range Sessions() {
+		err := rs.AddRow(se.ID, se.User, se.Addr, nullString(se.Schema), se.Command, se.Time(), nullString(se.Info),
+			nullString(se.Backend), se.InTx, se.BytesIn, se.BytesOut, se.Queries,
+			int64(time.Since(se.ConnectedAt)/time.Second))
+		if err != nil {
+			return c.writeError(err)
+		}
+	}
+	return c.writeResultSet(rs)
+}
+
+//nullString nil for the empty string, it is sent as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+//eachBackend call fn with the databases of the nodes ordered by node name.
+func eachBackend(fn func(name string, db *MysqlDB)) {
+	nodes := getNodes()
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := nodes[name]
+		for _, db := range append([]*MysqlDB{n.master}, n.slaves...) {
+			fn(name, db)
+		}
+	}
+}
+
+func (s *Server) showBackends(c *Client, args []string) error {
+	rs := mysql.NewResultSet(mysql.StringColumns("Node", "Role", "Addr", "State")...)
+	var err error
+	eachBackend(func(name string, db *MysqlDB) {
+		if err == nil {
+			err = rs.AddRow(name, db.role, db.addr, db.health())
+		}
+	})
+	if err != nil {
+		return c.writeError(err)
+	}
+	return c.writeResultSet(rs)
+}
+
+func (s *Server) showPools(c *Client, args []string) error {
+	cols := mysql.StringColumns("Node", "Role", "Addr")
+	for _, name := range []string{"Open", "Idle", "InUse", "Max"} {
+		cols = append(cols, mysql.Column{Name: name, Type: mysql.FieldTypeLong, Flags: mysql.FlagNotNULL})
+	}
+	rs := mysql.NewResultSet(cols...)
+	var err error
+	eachBackend(func(name string, db *MysqlDB) {
+		if err == nil {
+			open, idle, max := db.stats()
+			err = rs.AddRow(name, db.role, db.addr, open, idle, open-idle, max)
+		}
+	})
+	if err != nil {
+		return c.writeError(err)
+	}
+	return c.writeResultSet(rs)
+}
+
+func (s *Server) setBackend(c *Client, args []string) error {
+	offline := strings.EqualFold(args[1], "offline")
+	found := 0
+	eachBackend(func(name string, db *MysqlDB) {
+		if db.addr == args[0] {
+			db.setOffline(offline)
+			found++
+		}
+	})
+	if found == 0 {
+		return c.writeError(mysql.NewErrf(mysql.ErrUnknown, "backend %v not found", args[0]))
+	}
+	log.Warnf("Admin %v: backend %v %v", c.user, args[0], strings.ToLower(args[1]))
+	return c.writeOK()
+}
+
+func (s *Server) killClient(c *Client, args []string) error {
+	id, _ := strconv.ParseUint(args[0], 10, 32)
+	cl := getClient(uint32(id))
+	if cl == nil {
+		return c.writeError(mysql.NewErrf(mysql.ErrNoSuchThread, "Unknown thread id: %v", args[0]))
+	}
+	cl.kill()
+	log.Warnf("Admin %v: kill client %v %v@%v", c.user, id, cl.user, cl.Addr())
+	return c.writeOK()
+}
+
+func (s *Server) reloadConfig(c *Client, args []string) error {
+	if err := s.reload(); err != nil {
+		return c.writeError(mysql.NewErrf(mysql.ErrUnknown, "reload config: %v", err))
+	}
+	return c.writeOK()
+}
+
+func (s *Server) versionComment(c *Client, args []string) error {
+	rs := mysql.NewResultSet(mysql.StringColumns("@@version_comment")...)
+	if err := rs.AddRow("igo admin"); err != nil {
+		return c.writeError(err)
+	}
+	return c.writeResultSet(rs)
+}
+
+//the states of the backend
+const (
+	stateOnline  = "online"
+	stateOffline = "offline"
+	stateClosed  = "closed"
+)
+
+//kill close the connection of the client, the blocked read of the client returns.
+func (c *Client) kill() {
+	c.netConn.Close()
+}
+
+func (m *MysqlDB) isOffline() bool {
+	return atomic.LoadInt32(&m.offline) == 1
+}
+
+//setOffline stop or resume routing the statements to the database, the idle connections are closed when offline.
+func (m *MysqlDB) setOffline(offline bool) {
+	if !offline {
+		atomic.StoreInt32(&m.offline, 0)
+		return
+	}
+	atomic.StoreInt32(&m.offline, 1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		select {
+		case mc := <-m.freeConn:
+			m.numOpen--
+			mc.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (m *MysqlDB) health() string {
+	switch {
+	case m.isClosed():
+		return stateClosed
+	case m.isOffline():
+		return stateOffline
+	}
+	return stateOnline
+}
+
+//errBackendOffline the statement is routed to an offline database.
+func errBackendOffline(db *MysqlDB) error {
+	return fmt.Errorf("backend %v is offline", db.addr)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_Admin(t *testing.T) {
+	conf := &config.Config{Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: "127.0.0.1:3306",
+		Slaves: []string{"127.0.0.1:3316"}, MaxConnNum: 10, AdminUser: "admin", AdminPasswd: "secret"}}
+	s := NewServer(conf)
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	ls, err := s.runAdmin("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+
+	if _, err := (&MysqlDB{addr: ls.Addr().String(), user: "admin", passwd: "wrong"}).newConn(); err == nil {
+		t.Fatal("expect access denied")
+	}
+	mc, err := (&MysqlDB{addr: ls.Addr().String(), user: "admin", passwd: "secret"}).newConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.Close()
+	query := func(sql string) ([][]byte, error) {
+		return mc.Query(append([]byte{mysql.ComQuery}, sql...))
+	}
+
+	if res, err := query("select @@version_comment limit 1"); err != nil || len(res) != 5 || string(res[3][1:]) != "igo admin" {
+		t.Fatal(res, err)
+	}
+	if _, err := query("set backend '127.0.0.1:3316' offline;"); err != nil {
+		t.Fatal(err)
+	}
+	if db := GetDB("select 1"); db == nil || db.addr != "127.0.0.1:3306" {
+		t.Fatal("offline slave is used")
+	}
+	res, err := query("SHOW PROXY BACKENDS")
+	if err != nil || len(res) != 1+4+1+2+1 || !strings.Contains(string(res[7]), "offline") {
+		t.Fatal(res, err)
+	}
+	query("set backend 127.0.0.1:3316 online")
+	if db := GetDB("select 1"); db.addr != "127.0.0.1:3316" {
+		t.Fatal("online slave is not used")
+	}
+	if res, err := query("show proxy pools"); err != nil || len(res) != 1+7+1+2+1 {
+		t.Fatal(res, err)
+	}
+	if _, err := query("set backend 127.0.0.1:1 offline"); err == nil {
+		t.Fatal("expect unknown backend")
+	}
+	if _, err := query("kill client 1"); err == nil {
+		t.Fatal("expect unknown client")
+	}
+	if _, err := query("drop table t"); err == nil {
+		t.Fatal("expect unknown admin statement")
+	}
+	if res, err := query("show proxy clients"); err != nil || len(res) != 1+13+1+1 {
+		t.Fatal(res, err)
+	}
+}
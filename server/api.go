@@ -0,0 +1,226 @@
+package server
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+)
+
+//defaultHTTPListen the addr of pprof, /metrics and the /api when httpListen is not set.
+const defaultHTTPListen = ":6060"
+
+//ListenHTTP serve the handler on the httpListen of the config,
+//https if httpCert is set, and the client certificates are verified if httpClientCA is set.
+func ListenHTTP(conf *config.ServerConfig, h http.Handler) error {
+	addr := conf.HTTPListen
+	if addr == "" {
+		addr = defaultHTTPListen
+	}
+	srv := &http.Server{Addr: addr, Handler: h}
+	if conf.HTTPCert == "" {
+		return srv.ListenAndServe()
+	}
+	if conf.HTTPClientCA != "" {
+		pem, err := ioutil.ReadFile(conf.HTTPClientCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("httpClientCA %v: no certificate found", conf.HTTPClientCA)
+		}
+		srv.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	return srv.ListenAndServeTLS(conf.HTTPCert, conf.HTTPKey)
+}
+
+//APIHandler the json admin api under /api/, the calls are authorized by the bearer token or the verified client certificate.
+//
+//	GET    /api/sessions                the connected clients
+//	DELETE /api/sessions/{id}           kill the client
+//	GET    /api/backends                the backends with the health and the pool stats
+//	POST   /api/backends/{addr}/drain   stop routing to the backend, undrain to resume
+//	GET    /api/log/level               the log level, PUT {"level":"debug"} to change it
+//	POST   /api/reload                  reload the config
+//	GET    /api/config                  the effective config, the secrets are redacted
+//	GET    /api/queries/top             the top query digests, DELETE to reset the stats
+func (s *Server) APIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/sessions", s.apiSessions)
+	mux.HandleFunc("/api/sessions/", s.apiSessions)
+	mux.HandleFunc("/api/backends", s.apiBackends)
+	mux.HandleFunc("/api/backends/", s.apiBackends)
+	mux.HandleFunc("/api/log/level", s.apiLogLevel)
+	mux.HandleFunc("/api/reload", s.apiReload)
+	mux.HandleFunc("/api/config", s.apiConfig)
+	mux.Handle("/api/queries/top", digestHandler())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, msg := s.authorize(r); code != http.StatusOK {
+			http.Error(w, msg, code)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+//authorize check the client certificate or the bearer token of the request.
+func (s *Server) authorize(r *http.Request) (int, string) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return http.StatusOK, ""
+	}
+	token := string(s.config().Server.HTTPToken)
+	if token == "" {
+		return http.StatusForbidden, "api disabled: httpToken or httpClientCA is not set"
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+		return http.StatusUnauthorized, "invalid token"
+	}
+	return http.StatusOK, ""
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) apiSessions(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/sessions"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, Sessions())
+
+	case id != "" && r.Method == http.MethodDelete:
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid session id", http.StatusBadRequest)
+			return
+		}
+		c := getClient(uint32(n))
+		if c == nil {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		c.kill()
+		log.Warnf("API %v: kill client %v %v@%v", r.RemoteAddr, n, c.user, c.Addr())
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+//apiBackend a backend database and its pool.
+type apiBackend struct {
+	Node  string `json:"node"`
+	Role  string `json:"role"`
+	Addr  string `json:"addr"`
+	State string `json:"state"`
+	Open  int    `json:"open"`
+	Idle  int    `json:"idle"`
+	InUse int    `json:"in_use"`
+	Max   int    `json:"max"`
+}
+
+func (s *Server) apiBackends(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/backends"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		backends := []apiBackend{}
+		eachBackend(func(name string, db *MysqlDB) {
+			open, idle, max := db.stats()
+			backends = append(backends, apiBackend{name, db.role, db.addr, db.health(), open, idle, open - idle, max})
+		})
+		writeJSON(w, backends)
+		return
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i < 0 || (path[i+1:] != "drain" && path[i+1:] != "undrain") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addr, drain := path[:i], path[i+1:] == "drain"
+	found := 0
+	eachBackend(func(name string, db *MysqlDB) {
+		if db.addr == addr {
+			db.setOffline(drain)
+			found++
+		}
+	})
+	if found == 0 {
+		http.Error(w, "backend not found", http.StatusNotFound)
+		return
+	}
+	log.Warnf("API %v: backend %v %v", r.RemoteAddr, addr, path[i+1:])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//apiLevel the body of the log level.
+type apiLevel struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) apiLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var body apiLevel
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		l, err := log.ParseLevel(body.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.SetLevel(l)
+		log.Warnf("API %v: log level %v", r.RemoteAddr, log.LevelName(l))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, apiLevel{log.LevelName(log.GetLevel())})
+}
+
+func (s *Server) apiReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	log.Warnf("API %v: reload config", r.RemoteAddr)
+	if err := s.reload(); err != nil {
+		http.Error(w, "reload config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) apiConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	//the passwords and the token are marshaled as ******
+	writeJSON(w, s.config())
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"igo/config"
+	"igo/log"
+)
+
+func Test_API(t *testing.T) {
+	conf := &config.Config{Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: "127.0.0.1:3306",
+		Passwd: "dbsecret", Slaves: []string{"127.0.0.1:3316"}, MaxConnNum: 10}}
+	s := NewServer(conf)
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	h := s.APIHandler()
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer tok")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := call("GET", "/api/backends", ""); w.Code != http.StatusForbidden {
+		t.Fatal("api without token should be disabled", w.Code)
+	}
+	if w := call("DELETE", "/api/queries/top", ""); w.Code != http.StatusForbidden {
+		t.Fatal("the digests should not be reset without token", w.Code)
+	}
+	conf.Server.HTTPToken = "tok"
+	r := httptest.NewRequest("GET", "/api/backends", nil)
+	r.Header.Set("Authorization", "Bearer bad")
+	w := httptest.NewRecorder()
+	if h.ServeHTTP(w, r); w.Code != http.StatusUnauthorized {
+		t.Fatal("expect invalid token", w.Code)
+	}
+
+	w = call("GET", "/api/backends", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"addr":"127.0.0.1:3316","state":"online"`) {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if w := call("POST", "/api/backends/127.0.0.1:3316/drain", ""); w.Code != http.StatusNoContent {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if db := GetDB("select 1"); db.addr != "127.0.0.1:3306" {
+		t.Fatal("drained slave is used")
+	}
+	call("POST", "/api/backends/127.0.0.1:3316/undrain", "")
+	if db := GetDB("select 1"); db.addr != "127.0.0.1:3316" {
+		t.Fatal("undrained slave is not used")
+	}
+	if w := call("POST", "/api/backends/127.0.0.1:1/drain", ""); w.Code != http.StatusNotFound {
+		t.Fatal("expect unknown backend", w.Code)
+	}
+
+	if w := call("GET", "/api/sessions", ""); w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if w := call("DELETE", "/api/sessions/1", ""); w.Code != http.StatusNotFound {
+		t.Fatal("expect unknown session", w.Code)
+	}
+
+	defer log.SetLevel(log.GetLevel())
+	if w := call("PUT", "/api/log/level", `{"level":"warn"}`); w.Code != http.StatusOK || w.Body.String() != `{"level":"warning"}`+"\n" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if log.GetLevel() != log.LevelWarning {
+		t.Fatal("log level is not set")
+	}
+	if w := call("PUT", "/api/log/level", `{"level":"loud"}`); w.Code != http.StatusBadRequest {
+		t.Fatal("expect unknown level", w.Code)
+	}
+
+	w = call("GET", "/api/config", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "tok\"") {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	if w := call("POST", "/api/reload", ""); w.Code != http.StatusInternalServerError {
+		t.Fatal("reload without config source should fail", w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+//the audit modes
+const (
+	auditOff    int32 = iota
+	auditSQL          //record the sql text
+	auditDigest       //record the digest only
+)
+
+//the audit log settings, set by setAuditLog on startup and reload.
+var (
+	_auditMode = auditOff //atomic
+	_auditFile string     //the opened audit log file, guarded by Server.mu
+)
+
+//setAuditLog open, reopen or close the audit log file, and set the mode.
+func setAuditLog(conf *config.ServerConfig) error {
+	if conf.AuditLog == "" {
+		atomic.StoreInt32(&_auditMode, auditOff)
+		if _auditFile != "" {
+			log.DelAuditLogger()
+			_auditFile = ""
+		}
+		return nil
+	}
+	if conf.AuditLog != _auditFile {
+		jsonconf, _ := json.Marshal(map[string]string{"filename": conf.AuditLog})
+		if err := log.SetAuditLogger("audit", string(jsonconf)); err != nil {
+			return err
+		}
+		_auditFile = conf.AuditLog
+	}
+	mode := auditSQL
+	if conf.AuditMode == "digest" {
+		mode = auditDigest
+	}
+	atomic.StoreInt32(&_auditMode, mode)
+	return nil
+}
+
+//auditRecord the audit record of the statement, nil if the audit is off or the command has no statement.
+//The affected rows and the error code are filled by audit when the command is done.
+func (c *Client) auditRecord(data []byte, digest string, start time.Time) *log.AuditRecord {
+	mode := atomic.LoadInt32(&_auditMode)
+	if mode == auditOff {
+		return nil
+	}
+	switch data[0] {
+	case mysql.ComQuery, mysql.ComStmtPrepare, mysql.ComStmtExecute, mysql.ComInitDB:
+	default:
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(c.Addr())
+	r := &log.AuditRecord{
+		Time:     start,
+		ConnID:   c.connectID,
+		User:     c.user,
+		ClientIP: host,
+		Schema:   c.dbname,
+	}
+	if mode == auditDigest {
+		if digest == "" && data[0] != mysql.ComStmtExecute {
+			digest = parser.Fingerprint(data[1:])
+		}
+		r.Digest = digest
+	} else {
+		r.SQL = c.commandText(data)
+	}
+	return r
+}
+
+//audit write the record of the done command, the failure is logged and counted.
+func (c *Client) audit(r *log.AuditRecord, code uint16) {
+	r.AffectedRows = c.sent.affected
+	r.ErrorCode = code
+	if err := log.Audit(r); err != nil {
+		_auditErrors.Inc()
+		log.Errorf("audit conn %v: %v", r.ConnID, err)
+	}
+}
+
+//errorCode the mysql error code of the error, ER_UNKNOWN_ERROR if it is not a mysql error.
+func errorCode(err error) uint16 {
+	if e, ok := err.(*mysql.SQLError); ok {
+		return e.Code
+	}
+	return mysql.ErrUnknown
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+)
+
+func Test_Audit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "igo_audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := &config.ServerConfig{AuditLog: filepath.Join(dir, "audit.log")}
+	if err := setAuditLog(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer setAuditLog(&config.ServerConfig{})
+
+	c, done := testClient(t, conf)
+	defer done()
+	c.user, c.dbname = "app", "test"
+
+	if r := c.auditRecord([]byte{mysql.ComPing}, "", time.Now()); r != nil {
+		t.Fatal("ping audited")
+	}
+	query := append([]byte{mysql.ComQuery}, "update t set a = 1 where id = 2"...)
+	r := c.auditRecord(query, "", time.Now())
+	c.sent.affected = 3
+	c.audit(r, 0)
+
+	conf.AuditMode = "digest"
+	setAuditLog(conf)
+	c.sent = cmdStat{}
+	r = c.auditRecord(query, "", time.Now())
+	c.writeError(mysql.NewErr(mysql.ErrNoSuchTable, "test", "t"))
+	c.audit(r, c.sent.errCode)
+
+	f, err := os.Open(conf.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []log.AuditRecord
+	for s := bufio.NewScanner(f); s.Scan(); {
+		var rec log.AuditRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatal(s.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("%+v", recs)
+	}
+	if r := recs[0]; r.User != "app" || r.ClientIP != "127.0.0.1" || r.Schema != "test" || r.ConnID != c.connectID ||
+		r.SQL != "update t set a = 1 where id = 2" || r.Digest != "" || r.AffectedRows != 3 || r.ErrorCode != 0 {
+		t.Fatalf("%+v", r)
+	}
+	if r := recs[1]; r.SQL != "" || r.Digest != "update t set a = ? where id = ?" || r.ErrorCode != mysql.ErrNoSuchTable {
+		t.Fatalf("%+v", r)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -14,12 +17,14 @@ import (
 	"igo/config"
 	"igo/log"
 	"igo/mysql"
+	"igo/mysql/parser"
 )
 
 var (
 	baseConnectID    = uint32(1000) //atomic
 	errNotfoundDB    = errors.New("not found db")
 	errCannotGetConn = errors.New("can not get conn")
+	errBroadcastStmt = errors.New("prepare statement can not broadcast to shards")
 )
 
 //Client the client connection object
@@ -33,6 +38,13 @@ type Client struct {
 	user      string
 	dbname    string
 	connectID uint32
+	gtid      string  //the gtid of the last write, for the session consistency
+	token     string  //the consistency token of the current statement
+	route     string  //the route of the current command, for the metrics
+	backend   string  //the mysql addrs of the current command, for the slow log
+	sent      cmdStat //the result of the current command, for the slow log and the digests
+	admin     bool    //the client of the admin port
+	session   sessionState
 
 	salt             []byte
 	status           uint16
@@ -41,6 +53,7 @@ type Client struct {
 	sequence         uint8
 	maxPacketAllowed int
 	writeTimeout     time.Duration
+	connectedAt      time.Time
 }
 
 func newClient(conn *net.TCPConn, conf *config.ServerConfig) (*Client, chan struct{}) {
@@ -53,6 +66,7 @@ func newClient(conn *net.TCPConn, conf *config.ServerConfig) (*Client, chan stru
 		maxPacketAllowed: mysql.MaxPacketSize,
 		writeTimeout:     time.Duration(defaultWriteTimeout * time.Second),
 		cfg:              conf,
+		connectedAt:      time.Now(),
 	}
 
 	return c, c.die
@@ -63,6 +77,14 @@ func (c *Client) Addr() string {
 	return c.netConn.RemoteAddr().String()
 }
 
+//ip the ip of the client
+func (c *Client) ip() net.IP {
+	if addr, ok := c.netConn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
 //ConnectID connect id
 func (c *Client) ConnectID() uint32 {
 	return c.connectID
@@ -152,21 +174,27 @@ func (c *Client) readHandshakeRespone() error {
 	c.user = string(data[pos : pos+bytes.IndexByte(data[pos:], 0)])
 
 	pos += len(c.user) + 1
+	if !c.admin && !hostAllowed(c.user, c.ip()) {
+		return errHostNotPrivileged(c.ip())
+	}
 
 	//auth length and auth
 	authLen := int(data[pos])
 	pos++
-	// if authLen == 0 {
-	// 	return mysql.NewErr(mysql.ErrAccessDenied, c.user, c.netConn.RemoteAddr().String(), "NO")
-	// }
-
-	// auth := data[pos : pos+authLen]
-	// checkAuth := mysql.ScramblePassword(c.salt, []byte(c.cfg.Passwd))
-	// if c.user != c.cfg.User || !bytes.Equal(auth, checkAuth) {
-	// 	log.Error("readHandshakeResponse error: 0, auth: ", auth,
-	// 		",checkAuth: ", checkAuth, ",client_user: ", c.user, ",config_set_user: ", c.cfg.User, ",passworld: ", c.cfg.Passwd)
-	// 	return mysql.NewErr(mysql.ErrAccessDenied, c.user, c.netConn.RemoteAddr().String(), "Yes")
-	// }
+	auth := data[pos : pos+authLen]
+	ok := false
+	if c.admin {
+		ok = checkAdminAuth(c.cfg, c.user, c.salt, auth)
+	} else {
+		ok = checkAuth(c.user, c.salt, auth)
+	}
+	if !ok {
+		using := "YES"
+		if authLen == 0 {
+			using = "NO"
+		}
+		return mysql.NewErr(mysql.ErrAccessDenied, c.user, c.netConn.RemoteAddr().String(), using)
+	}
 
 	pos += authLen
 
@@ -206,6 +234,18 @@ func (c *Client) writeOK() error {
 
 	return c.writePacket(data)
 }
+
+//okPacket build the ok packet payload.
+func okPacket(affectedRows, insertID uint64, status mysql.StatusFlag) []byte {
+	data := make([]byte, 0, 16)
+	data = append(data, mysql.HeaderOK)
+	data = mysql.AppendLengthEncodedInteger(data, affectedRows)
+	data = mysql.AppendLengthEncodedInteger(data, insertID)
+	data = append(data, byte(status), byte(status>>8))
+	data = append(data, 0, 0)
+	return data
+}
+
 func (c *Client) writeError(e error) error {
 	var m *mysql.SQLError
 	var ok bool
@@ -216,6 +256,7 @@ func (c *Client) writeError(e error) error {
 
 	data = append(data, mysql.HeaderERR)
 	data = append(data, byte(m.Code), byte(m.Code>>8))
+	c.sent.errCode = m.Code
 
 	if c.capability&uint32(mysql.ClientProtocol41) > 0 {
 		data = append(data, '#')
@@ -242,9 +283,43 @@ func (c *Client) Accept() error {
 	return nil
 }
 
-func (c *Client) dispatch(data []byte) error {
+func (c *Client) dispatch(data []byte) (err error) {
 	log.Debugf("dispatch cmd:%v, data: %v", data[0], string(data[1:]))
-	var err error
+	if cmd, start := data[0], time.Now(); cmd != mysql.ComQuit {
+		c.route, c.backend, c.sent = "", "", cmdStat{}
+		//the packet is in the read buffer, which is reused by the result packets
+		digest := c.digestOf(data)
+		var query string
+		if slowLogOn() {
+			query = c.commandText(data)
+		}
+		rec := c.auditRecord(data, digest, start)
+		c.beginCommand(data)
+		defer func() {
+			c.endCommand()
+			observeCommand(cmd, c.route, start)
+			code := c.sent.errCode
+			if err != nil && code == 0 {
+				code = errorCode(err)
+			}
+			if digest != "" {
+				recordDigest(digest, time.Since(start), c.sent.rows+c.sent.affected, code != 0)
+			}
+			if query != "" {
+				c.logSlow(cmd, query, start)
+			}
+			if rec != nil {
+				c.audit(rec, code)
+			}
+		}()
+		if digest != "" {
+			release, err := c.admit(digest)
+			if err != nil {
+				return c.writeError(err)
+			}
+			defer release()
+		}
+	}
 	switch data[0] {
 	case mysql.ComQuit:
 		err = c.handleQuit()
@@ -256,6 +331,13 @@ func (c *Client) dispatch(data []byte) error {
 
 	case mysql.ComFieldList:
 		err = c.handleFieldList(data)
+
+	case mysql.ComProcessInfo:
+		err = c.showProcesslist(false)
+
+	case mysql.ComProcessKill:
+		err = c.handleProcessKill(data)
+
 	case
 		mysql.ComStmtPrepare:
 		err = c.handleStmtPrepare(data)
@@ -289,73 +371,313 @@ func (c *Client) handleQuit() error {
 
 func (c *Client) handleStmtClose(data []byte) error {
 	stmtID := binary.LittleEndian.Uint32(data[1:])
+	if c.stmt == nil {
+		//dropped with the broken connection
+		return nil
+	}
 	if stmtID != c.stmt.id {
 		return mysql.NewErr(mysql.ErrUnknownStmtHandler, stmtID)
 	}
+	if c.stmt.shard != nil {
+		c.stmt.shard.close(c.stmt)
+	}
 	err := c.stmt.Close()
 	c.stmt = nil
 	return err
 }
 
 func (c *Client) handleStmtPrepare(data []byte) error {
-	db := GetDB(string(data))
-	if db == nil {
-		return errNotfoundDB
+	h, query := parseHints(data[1:], !c.cfg.KeepHints)
+	if err := c.checkFirewall(query); err != nil {
+		return c.writeError(err)
+	}
+	h = c.readYourWrites(h)
+	plan := planShard(query)
+	//the execute args choose the node, unless the hint decide it.
+	byArgs := plan != nil && plan.hasParam() && (h == nil || (h.shard < 0 && h.cluster == ""))
+	var db *MysqlDB
+	if byArgs {
+		//prepare on the first node for the response, the execute choose the node by the args.
+		if db = GetNode(plan.rule.nodes[0]); db == nil {
+			return c.writeError(errNotfoundDB)
+		}
+	} else {
+		dbs, err := route(h, query, plan, nil)
+		if err != nil {
+			return c.writeError(err)
+		}
+		if len(dbs) != 1 {
+			return c.writeError(errBroadcastStmt)
+		}
+		db = dbs[0]
+	}
+
+	c.setRoute(db)
+	res, stmt, err := c.prepareOn(db, string(query))
+	if err != nil {
+		return err
+	}
+	if byArgs {
+		stmt.shard = &shardStmt{
+			plan:  plan,
+			query: string(query),
+			stmts: map[*MysqlDB]*mysqlStmt{db: stmt},
+		}
 	}
+	c.stmt = stmt
+	err = c.writeResultPackets(res)
+	return err
+}
+
+//prepareOn prepare the query on the db, the connection is hold by the statement.
+func (c *Client) prepareOn(db *MysqlDB, query string) ([][]byte, *mysqlStmt, error) {
 	conn := db.getConn()
 	if conn == nil {
-		return errCannotGetConn
+		return nil, nil, errCannotGetConn
 	}
 	// defer db.putConn(conn)
 
 	useCmd := []byte(string(mysql.ComInitDB) + c.dbname)
 	_, err := conn.Exec(useCmd)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	res, stmt, err := conn.Prepare(string(data[1:]))
+	res, stmt, err := conn.Prepare(query)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	c.stmt = stmt
-	err = c.writeResultPackets(res)
-	return err
+	stmt.db = db
+	stmt.query = query
+	stmt.digest = parser.Fingerprint([]byte(query))
+	return res, stmt, nil
 }
 
 func (c *Client) handlestmtExec(data []byte) error {
-	if c.stmt == nil || c.stmt.mc == nil {
+	if c.stmt == nil {
+		return c.writeError(mysql.NewErrf(mysql.ErrUnknownStmtHandler,
+			"Unknown prepared statement handler given to mysqld_stmt_execute"))
+	}
+	if c.stmt.mc == nil {
 		return errCannotGetConn
 	}
-	res, err := c.stmt.Query(data)
+	stmt := c.stmt
+	if stmt.shard != nil {
+		var err error
+		if stmt, data, err = c.routeStmt(data); err != nil {
+			return c.writeError(err)
+		}
+	}
+	c.setRoute(stmt.db)
+	c.pin(stmt.mc)
+	w := watch(stmt.mc, c.maxExecTime())
+	res, err := stmt.QueryLimit(data, c.resultLimit())
+	if err == errResultTooLarge {
+		stmt.mc.stopResult()
+	}
+	killed := w.stop()
+	c.unpin(stmt.mc)
+	if killed {
+		stmt.mc.resetAfterKill(err)
+		if stmt.mc.broken {
+			c.dropStmt(stmt)
+		}
+		return c.writeError(errQueryTimeout)
+	}
+	if err == errResultTooLarge {
+		if stmt.mc.broken {
+			c.dropStmt(stmt)
+		}
+		return c.writeError(err)
+	}
+	c.trackGTID(stmt.mc)
 	err = c.writeResultPackets(res)
 	return err
 }
 
+//dropStmt close the statement whose connection is broken by the kill or the result limit, the connection is not reusable.
+//The front statement is dropped with the shard ones, the next execute of it gets an error,
+//the other shard statement is prepared again by the next execute routed to it.
+func (c *Client) dropStmt(stmt *mysqlStmt) {
+	log.Warnf("Client %v: drop the statement %v, the connection to %v is broken", c.ConnectID(), stmt.id, stmt.db.addr)
+	if stmt == c.stmt {
+		if stmt.shard != nil {
+			stmt.shard.close(stmt)
+		}
+		c.stmt = nil
+	} else {
+		delete(c.stmt.shard.stmts, stmt.db)
+	}
+	mc := stmt.mc
+	stmt.mc = nil
+	stmt.db.putConn(mc)
+}
+
 //handleQuery
 func (c *Client) handleQuery(data []byte) error {
-	db := GetDB(string(data))
-	if db == nil {
-		return errNotfoundDB
+	h, query := parseHints(data[1:], !c.cfg.KeepHints)
+	if err := c.checkFirewall(query); err != nil {
+		return c.writeError(err)
+	}
+	if handled, err := c.handleProcessStmt(query); handled {
+		return err
+	}
+	query = c.injectLimit(query)
+	if !bytes.Equal(query, data[1:]) {
+		data = append([]byte{data[0]}, query...)
+	}
+	h = c.readYourWrites(h)
+	dbs, err := route(h, query, planShard(query), nil)
+	if err != nil {
+		return c.writeError(err)
 	}
+	c.setRoute(dbs...)
+	if len(dbs) > 1 {
+		return c.broadcast(dbs, data)
+	}
+
+	res, err := c.execOn(dbs[0], data)
+	if err == errQueryTimeout || err == errResultTooLarge {
+		return c.writeError(err)
+	}
+	if err != nil {
+		return err
+	}
+	err = c.writeResultPackets(res)
+	return err
+}
+
+//execOn execute the command on the db and read all the result packets.
+func (c *Client) execOn(db *MysqlDB, data []byte) ([][]byte, error) {
+	res, gtid, err := c.queryOn(db, data)
+	c.saveGTID(gtid)
+	return res, err
+}
+
+//queryOn execute the command on the db like execOn, the gtid of the write is returned instead of saved,
+//so it is safe to run in parallel on the client.
+func (c *Client) queryOn(db *MysqlDB, data []byte) (res [][]byte, gtid string, err error) {
 	conn := db.getConn()
 	if conn == nil {
-		return errCannotGetConn
+		return nil, "", errCannotGetConn
 	}
 	defer db.putConn(conn)
+	defer func() {
+		//the connection state is unknown after the panic
+		if r := recover(); r != nil {
+			conn.broken = true
+			panic(r)
+		}
+	}()
+	c.pin(conn)
+	defer c.unpin(conn)
 
 	useCmd := []byte(string(mysql.ComInitDB) + c.dbname)
-	_, err := conn.Exec(useCmd)
+	if _, err = conn.Exec(useCmd); err != nil {
+		return nil, "", err
+	}
+
+	w := watch(conn, c.maxExecTime())
+	res, err = conn.QueryLimit(data, c.resultLimit())
+	if err == errResultTooLarge {
+		conn.stopResult()
+		res = nil
+	}
+	if w.stop() {
+		conn.resetAfterKill(err)
+		return nil, "", errQueryTimeout
+	}
+	gtid, conn.gtid = conn.gtid, ""
+	return res, gtid, err
+}
+
+//scatter execute the query on all the databases in parallel, and merge the result sets.
+func (c *Client) scatter(dbs []*MysqlDB, data []byte) error {
+	plan, err := newMergePlan(data[1:])
 	if err != nil {
-		return err
+		return c.writeError(err)
+	}
+	query := append([]byte{data[0]}, plan.query...)
+	log.Debugf("scatter to %d shards: %s", len(dbs), plan.query)
+
+	results := make([][][]byte, len(dbs))
+	gtids := make([]string, len(dbs))
+	errs := make([]error, len(dbs))
+	var wg sync.WaitGroup
+	for i, db := range dbs {
+		wg.Add(1)
+		go func(i int, db *MysqlDB) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("scatter to %v panic: %v\n%s", db.addr, r, debug.Stack())
+					errs[i] = mysql.NewErrf(mysql.ErrUnknown, "scatter to %v: %v", db.addr, r)
+				}
+			}()
+			results[i], gtids[i], errs[i] = c.queryOn(db, query)
+		}(i, db)
+	}
+	wg.Wait()
+	for _, gtid := range gtids {
+		c.saveGTID(gtid)
+	}
+	for _, err := range errs {
+		if _, ok := err.(*mysql.SQLError); ok {
+			return c.writeError(err)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	res, err := conn.Query(data)
+	res, err := plan.merge(results)
 	if err != nil {
-		return err
+		return c.writeError(err)
 	}
-	err = c.writeResultPackets(res)
-	return err
+	//each shard is limited alone, the client gets the merged one
+	if c.resultLimit().exceededBy(res) {
+		_resultLimits.Inc()
+		return c.writeError(errResultTooLarge)
+	}
+	return c.writeResultPackets(res)
+}
+
+//broadcast execute the statement on all the databases, and merge the ok packets.
+func (c *Client) broadcast(dbs []*MysqlDB, data []byte) error {
+	if returnRows(data[1:]) {
+		return c.scatter(dbs, data)
+	}
+
+	var affected uint64
+	var status mysql.StatusFlag
+	var applied []string
+	for _, db := range dbs {
+		res, err := c.execOn(db, data)
+		if err != nil {
+			return c.writeError(errBroadcast(err, db, applied))
+		}
+		applied = append(applied, db.addr)
+		n, _, m := readLengthEncodedInteger(res[0][1:])
+		_, _, k := readLengthEncodedInteger(res[0][1+m:])
+		affected += n
+		status = readStatus(res[0][1+m+k:])
+	}
+	return c.writeResultPackets([][]byte{okPacket(affected, 0, status)})
+}
+
+//errBroadcast the error of the broadcast failed on db, the statement is applied on the shards before it,
+//the client is told where it is applied instead of disconnected.
+func errBroadcast(err error, db *MysqlDB, applied []string) error {
+	code := errorCode(err)
+	if e, ok := err.(*mysql.MySQLError); ok {
+		code = e.Number
+	}
+	done := "none"
+	if len(applied) > 0 {
+		done = strings.Join(applied, ", ")
+	}
+	log.Errorf("broadcast failed on %v, applied on %v: %v", db.addr, done, err)
+	return mysql.NewErrf(code, "Broadcast failed on %v and applied on %v: %v", db.addr, done, err)
 }
 
 //handleUseDB
@@ -370,6 +692,7 @@ func (c *Client) handleFieldList(data []byte) error {
 	if db == nil {
 		return errNotfoundDB
 	}
+	c.setRoute(db)
 	conn := db.getConn()
 	if conn == nil {
 		return errCannotGetConn
@@ -389,6 +712,7 @@ func (c *Client) useDB(name string) error {
 	if db == nil {
 		return errNotfoundDB
 	}
+	c.setRoute(db)
 	conn := db.getConn()
 	if conn == nil {
 		return errCannotGetConn
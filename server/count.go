@@ -1,6 +1,9 @@
 package server
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 var (
 	_defaultMax = int64(1024)
@@ -8,33 +11,56 @@ var (
 
 //Counter count the client connection and limit
 type Counter interface {
-	SetMax(int64) //set the max count.
-	Size() int64  //get the current size of counter.
-	Incr() bool   //incr will block when out of max count.
+	SetMax(int64)                //set the max count.
+	Size() int64                 //get the current size of counter.
+	Incr() bool                  //incr returns false at the max count.
+	IncrWait(time.Duration) bool //incr will block up to the duration at the max count.
 	Decr()
 }
 
 var _ Counter = &ChanCount{}
 
-//ChanCount chan count will bolck when at max.
+//ChanCount chan count, IncrWait blocks when at max.
 type ChanCount struct {
-	max int64
-	ch  chan struct{}
+	mu      sync.Mutex
+	max     int64
+	over    int64 //the count over the max after the max reduced
+	ch      chan struct{}
+	changed chan struct{} //closed when the count is reduced or the max changed, wake the waiters
 }
 
-//Max max
+//Max max, the count is kept when the max changed.
 func (c *ChanCount) SetMax(m int64) {
 	if m <= 0 {
 		m = _defaultMax
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.max = m
-	if c.ch == nil {
-		c.ch = make(chan struct{}, c.max)
+	if c.ch != nil && int64(cap(c.ch)) == m {
+		return
 	}
+	ch := make(chan struct{}, m)
+	if c.ch != nil {
+		n := int64(len(c.ch)) + c.over
+		c.over = 0
+		for ; n > 0 && int64(len(ch)) < m; n-- {
+			ch <- struct{}{}
+		}
+		c.over = n
+	}
+	c.ch = ch
+	c.wake()
 }
 
 //Incr incr
 func (c *ChanCount) Incr() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.incr()
+}
+
+func (c *ChanCount) incr() bool {
 	select {
 	case c.ch <- struct{}{}:
 		return true
@@ -43,15 +69,62 @@ func (c *ChanCount) Incr() bool {
 	}
 }
 
+//wake wake the waiters of IncrWait, with mu locked.
+func (c *ChanCount) wake() {
+	if c.changed != nil {
+		close(c.changed)
+	}
+	c.changed = make(chan struct{})
+}
+
+//IncrWait incr, wait up to d for the count under the max.
+func (c *ChanCount) IncrWait(d time.Duration) bool {
+	var timer *time.Timer
+	for {
+		c.mu.Lock()
+		ok, changed := c.incr(), c.changed
+		c.mu.Unlock()
+		if ok {
+			return true
+		}
+		if timer == nil {
+			if d <= 0 {
+				return false
+			}
+			timer = time.NewTimer(d)
+			defer timer.Stop()
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
 //Decr decr
-func (c *ChanCount) Decr() { <-c.ch }
+func (c *ChanCount) Decr() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.over > 0 {
+		c.over--
+		return
+	}
+	select {
+	case <-c.ch:
+		c.wake()
+	default:
+	}
+}
 
 //Size size
 func (c *ChanCount) Size() int64 {
-	return int64(len(c.ch))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(len(c.ch)) + c.over
 }
 
-//IntCount int count will when return false when at max.
+//IntCount int count will return false when at max.
 type IntCount struct {
 	sync.Mutex
 	max int64
@@ -60,15 +133,20 @@ type IntCount struct {
 
 var _ Counter = &IntCount{}
 
+//intCountPoll the interval IncrWait of IntCount checks the count.
+const intCountPoll = 5 * time.Millisecond
+
 //Max max
 func (c *IntCount) SetMax(m int64) {
+	c.Lock()
 	c.max = m
+	c.Unlock()
 }
 
 //Incr incr
 func (c *IntCount) Incr() bool {
 	c.Lock()
-	if c.cur > c.max {
+	if c.cur >= c.max {
 		c.Unlock()
 		return false
 	}
@@ -77,6 +155,18 @@ func (c *IntCount) Incr() bool {
 	return true
 }
 
+//IncrWait incr, check the count every intCountPoll up to d.
+func (c *IntCount) IncrWait(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for !c.Incr() {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(intCountPoll)
+	}
+	return true
+}
+
 //Decr decrs
 func (c *IntCount) Decr() {
 	c.Lock()
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"net"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"igo/config"
+	"igo/mysql"
 )
 
 func Test_ChanIncr(t *testing.T) {
@@ -24,3 +29,108 @@ func Test_IntIncr(t *testing.T) {
 	<-time.After(5 * time.Second)
 	t.Log(c.Size())
 }
+
+func Test_ChanSetMax(t *testing.T) {
+	c := new(ChanCount)
+	c.SetMax(4)
+	for i := 0; i < 4; i++ {
+		c.Incr()
+	}
+	c.SetMax(2)
+	if c.Size() != 4 || c.Incr() {
+		t.Fatal("count should be kept over the reduced max", c.Size())
+	}
+	c.Decr()
+	c.Decr()
+	c.Decr()
+	if c.Size() != 1 || !c.Incr() || c.Incr() {
+		t.Fatal("size", c.Size())
+	}
+	c.SetMax(3)
+	if c.Size() != 2 || !c.Incr() {
+		t.Fatal("size", c.Size())
+	}
+}
+
+func Test_IncrWait(t *testing.T) {
+	c := new(IntCount)
+	c.SetMax(2)
+	if !c.Incr() || !c.Incr() || c.Incr() || c.Size() != 2 {
+		t.Fatal("int count should stop at the max", c.Size())
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.Decr()
+	}()
+	if c.IncrWait(0) || !c.IncrWait(time.Second) {
+		t.Fatal("int count should wait the decr")
+	}
+
+	cc := new(ChanCount)
+	cc.SetMax(1)
+	if !cc.IncrWait(0) || cc.IncrWait(20*time.Millisecond) {
+		t.Fatal("chan count should stop at the max")
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cc.Decr()
+	}()
+	if !cc.IncrWait(time.Second) || cc.Size() != 1 {
+		t.Fatal("chan count should wait the decr", cc.Size())
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cc.SetMax(2)
+	}()
+	if !cc.IncrWait(time.Second) || cc.Size() != 2 {
+		t.Fatal("chan count should wait the max raised", cc.Size())
+	}
+}
+
+func Test_MaxClient(t *testing.T) {
+	s := NewServer(&config.Config{Server: config.ServerConfig{MaxClient: 1}})
+	ls, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	go func() {
+		for {
+			conn, err := ls.AcceptTCP()
+			if err != nil {
+				return
+			}
+			go s.admit(conn)
+		}
+	}()
+	connect := func() (*mysqlConn, error) {
+		return (&MysqlDB{addr: ls.Addr().String(), user: "app"}).newConn()
+	}
+
+	first, err := connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := atomic.LoadUint32(&baseConnectID)
+	if _, err := connect(); err == nil || err.(*mysql.MySQLError).Number != mysql.ErrConCount {
+		t.Fatal("expect too many connections", err)
+	}
+	if atomic.LoadUint32(&baseConnectID) != id {
+		t.Fatal("the rejected client should not take a connection id")
+	}
+
+	next := *s.config()
+	next.Server.MaxClientWait = 1000
+	s.mu.Lock()
+	s.cfg = &next
+	s.mu.Unlock()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		first.Close()
+	}()
+	mc, err := connect()
+	if err != nil {
+		t.Fatal("should wait the first client closed", err)
+	}
+	mc.Close()
+}
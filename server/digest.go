@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+import (
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+const (
+	maxDigests     = 5000      //the new digests over it are counted in otherDigest
+	otherDigest    = "(other)" //the digest of the queries over maxDigests
+	latencyBuckets = 128       //the log latency buckets for p99, 4 per power of 2 from 1us
+	latencyBase    = time.Microsecond
+)
+
+//digestStat the rolling stats of a digest.
+type digestStat struct {
+	mu      sync.Mutex
+	id      string
+	text    string
+	count   uint64
+	errors  uint64
+	rows    uint64
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets [latencyBuckets]uint32
+	first   time.Time
+	last    time.Time
+}
+
+//the stats by the digest, reset by ResetDigests.
+var (
+	_digestMu sync.RWMutex
+	_digests  = make(map[string]*digestStat)
+)
+
+//digestOf the fingerprint of the query or the executed statement, empty for the other commands.
+func (c *Client) digestOf(data []byte) string {
+	switch data[0] {
+	case mysql.ComQuery:
+		return parser.Fingerprint(data[1:])
+	case mysql.ComStmtExecute:
+		if c.stmt != nil {
+			return c.stmt.digest
+		}
+	}
+	return ""
+}
+
+//recordDigest add the command to the stats of the digest.
+func recordDigest(text string, d time.Duration, rows uint64, failed bool) {
+	_digestMu.RLock()
+	s := _digests[text]
+	_digestMu.RUnlock()
+	if s == nil {
+		_digestMu.Lock()
+		if s = _digests[text]; s == nil {
+			if len(_digests) >= maxDigests {
+				text = otherDigest
+			}
+			if s = _digests[text]; s == nil {
+				s = &digestStat{id: parser.DigestID(text), text: text, min: d, first: time.Now()}
+				_digests[text] = s
+			}
+		}
+		_digestMu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.count++
+	if failed {
+		s.errors++
+	}
+	s.rows += rows
+	s.total += d
+	if d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+	s.buckets[latencyBucket(d)]++
+	s.last = time.Now()
+	s.mu.Unlock()
+}
+
+func latencyBucket(d time.Duration) int {
+	if d <= latencyBase {
+		return 0
+	}
+	i := int(4 * math.Log2(float64(d)/float64(latencyBase)))
+	if i >= latencyBuckets {
+		return latencyBuckets - 1
+	}
+	return i
+}
+
+//bucketUpper the upper bound of the latency bucket.
+func bucketUpper(i int) time.Duration {
+	return time.Duration(float64(latencyBase) * math.Exp2(float64(i+1)/4))
+}
+
+//DigestStat the stats of a query digest, the time in seconds.
+type DigestStat struct {
+	ID        string    `json:"id"`
+	Digest    string    `json:"digest"`
+	Count     uint64    `json:"count"`
+	Errors    uint64    `json:"errors"`
+	Rows      uint64    `json:"rows"`
+	TotalTime float64   `json:"total_time"`
+	AvgTime   float64   `json:"avg_time"`
+	MinTime   float64   `json:"min_time"`
+	MaxTime   float64   `json:"max_time"`
+	P99Time   float64   `json:"p99_time"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func (s *digestStat) snapshot() DigestStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := DigestStat{
+		ID:        s.id,
+		Digest:    s.text,
+		Count:     s.count,
+		Errors:    s.errors,
+		Rows:      s.rows,
+		TotalTime: s.total.Seconds(),
+		MinTime:   s.min.Seconds(),
+		MaxTime:   s.max.Seconds(),
+		FirstSeen: s.first,
+		LastSeen:  s.last,
+	}
+	if s.count > 0 {
+		st.AvgTime = st.TotalTime / float64(s.count)
+	}
+	//the upper bound of the bucket the 99th percentile falls in, not more than the max
+	rank := uint64(math.Ceil(float64(s.count) * 0.99))
+	var n uint64
+	for i, c := range s.buckets {
+		if n += uint64(c); n >= rank && n > 0 {
+			st.P99Time = math.Min(bucketUpper(i).Seconds(), st.MaxTime)
+			break
+		}
+	}
+	return st
+}
+
+//digestOrders the orders of the top digests.
+var digestOrders = map[string]func(a, b *DigestStat) bool{
+	"total":  func(a, b *DigestStat) bool { return a.TotalTime > b.TotalTime },
+	"count":  func(a, b *DigestStat) bool { return a.Count > b.Count },
+	"avg":    func(a, b *DigestStat) bool { return a.AvgTime > b.AvgTime },
+	"max":    func(a, b *DigestStat) bool { return a.MaxTime > b.MaxTime },
+	"p99":    func(a, b *DigestStat) bool { return a.P99Time > b.P99Time },
+	"rows":   func(a, b *DigestStat) bool { return a.Rows > b.Rows },
+	"errors": func(a, b *DigestStat) bool { return a.Errors > b.Errors },
+}
+
+//TopDigests the top n digests ordered by total, count, avg, max, p99, rows or errors, n <= 0 means all.
+func TopDigests(n int, by string) []DigestStat {
+	less, ok := digestOrders[by]
+	if !ok {
+		less = digestOrders["total"]
+	}
+	_digestMu.RLock()
+	stats := make([]DigestStat, 0, len(_digests))
+	for _, s := range _digests {
+		stats = append(stats, s.snapshot())
+	}
+	_digestMu.RUnlock()
+	sort.Slice(stats, func(i, j int) bool { return less(&stats[i], &stats[j]) })
+	if n > 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+//ResetDigests clear all the digest stats.
+func ResetDigests() {
+	_digestMu.Lock()
+	_digests = make(map[string]*digestStat)
+	_digestMu.Unlock()
+}
+
+//digestHandler the http handler of the top queries, served by APIHandler,
+//GET ?limit=20&sort=total list the top digests in json, DELETE reset the stats.
+func digestHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			limit := 20
+			if s := r.FormValue("limit"); s != "" {
+				var err error
+				if limit, err = strconv.Atoi(s); err != nil {
+					http.Error(w, "invalid limit", http.StatusBadRequest)
+					return
+				}
+			}
+			sortBy := r.FormValue("sort")
+			if _, ok := digestOrders[sortBy]; sortBy != "" && !ok {
+				http.Error(w, "invalid sort", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(TopDigests(limit, sortBy))
+		case http.MethodDelete:
+			ResetDigests()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"igo/mysql/parser"
+)
+
+func Test_Digest(t *testing.T) {
+	defer ResetDigests()
+	slow := parser.Fingerprint([]byte("select * from t where id in (1, 2)"))
+	fast := parser.Fingerprint([]byte("select * from u where id = 1"))
+	for i := 0; i < 100; i++ {
+		recordDigest(slow, time.Millisecond, 2, false)
+		recordDigest(fast, time.Microsecond*10, 1, i == 0)
+	}
+	recordDigest(slow, time.Second, 2, false)
+
+	top := TopDigests(1, "total")
+	if len(top) != 1 || top[0].Digest != "select * from t where id in(?+)" || top[0].Count != 101 || top[0].Rows != 202 {
+		t.Fatalf("%+v", top)
+	}
+	if s := top[0]; s.MaxTime != 1 || s.MinTime != 0.001 || s.P99Time < 0.001 || s.P99Time > 0.0013 || s.FirstSeen.IsZero() {
+		t.Fatalf("%+v", s)
+	}
+	if top := TopDigests(0, "errors"); len(top) != 2 || top[0].Errors != 1 || top[0].ID != parser.DigestID(fast) {
+		t.Fatalf("%+v", top)
+	}
+
+	srv := httptest.NewServer(digestHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "?limit=5&sort=count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats []DigestStat
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil || len(stats) != 2 || stats[0].Count != 101 {
+		t.Fatal(stats, err)
+	}
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatal(resp, err)
+	}
+	if top := TopDigests(0, ""); len(top) != 0 {
+		t.Fatalf("not reset: %+v", top)
+	}
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+//the firewall actions, allow stops the check
+const (
+	actionDeny  = "deny"
+	actionLog   = "log"
+	actionLearn = "learn"
+)
+
+//firewallRule the compiled firewall rule, the empty conditions match all.
+type firewallRule struct {
+	config.FirewallRule
+	users   map[string]bool
+	nets    []*net.IPNet
+	types   map[parser.StmtType]bool
+	tables  map[string]bool //lower case table or db.table
+	digests map[string]bool //the digest ids and the fingerprints
+	re      *regexp.Regexp
+}
+
+//the firewall rules and the learned digests, replaced by firewall.install.
+var (
+	_firewallMu sync.RWMutex
+	_rules      []*firewallRule
+	_allowlist  = make(map[string]bool) //the fingerprints
+	_allowFile  string
+)
+
+func newFirewallRule(rc *config.FirewallRule) (*firewallRule, error) {
+	r := &firewallRule{FirewallRule: *rc, users: stringSet(rc.Users), digests: stringSet(rc.Digests)}
+	nets, err := ipNets(rc.Sources)
+	if err != nil {
+		return nil, err
+	}
+	r.nets = nets
+	if len(rc.Types) > 0 {
+		r.types = make(map[parser.StmtType]bool)
+		for _, t := range rc.Types {
+			r.types[parser.ParseStmtType(t)] = true
+		}
+	}
+	if len(rc.Tables) > 0 {
+		r.tables = make(map[string]bool)
+		for _, t := range rc.Tables {
+			r.tables[strings.ToLower(t)] = true
+		}
+	}
+	if rc.Regex != "" {
+		if r.re, err = regexp.Compile(rc.Regex); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+//firewall the compiled rules and the allowlist of a config, not in use until installed.
+type firewall struct {
+	rules     []*firewallRule
+	allowlist map[string]bool
+	allowFile string
+}
+
+//newFirewall compile the rules and load the allowlist file.
+func newFirewall(conf *config.Config) (*firewall, error) {
+	f := &firewall{rules: make([]*firewallRule, 0, len(conf.Rules)), allowFile: conf.Server.FirewallAllowlist}
+	for i := range conf.Rules {
+		r, err := newFirewallRule(&conf.Rules[i])
+		if err != nil {
+			return nil, fmt.Errorf("firewall rule %q: %v", conf.Rules[i].Name, err)
+		}
+		f.rules = append(f.rules, r)
+	}
+	allowlist, err := loadAllowlist(f.allowFile)
+	if err != nil {
+		return nil, fmt.Errorf("firewall allowlist: %v", err)
+	}
+	f.allowlist = allowlist
+	return f, nil
+}
+
+//install replace the rules and the allowlist in use.
+func (f *firewall) install() {
+	_firewallMu.Lock()
+	_rules, _allowlist, _allowFile = f.rules, f.allowlist, f.allowFile
+	_firewallMu.Unlock()
+}
+
+//setFirewall compile the firewall of the config and install it.
+func setFirewall(conf *config.Config) error {
+	f, err := newFirewall(conf)
+	if err != nil {
+		return err
+	}
+	f.install()
+	return nil
+}
+
+//loadAllowlist read the fingerprints of the file, the missing file is empty.
+func loadAllowlist(fname string) (map[string]bool, error) {
+	allowlist := make(map[string]bool)
+	if fname == "" {
+		return allowlist, nil
+	}
+	f, err := os.Open(fname)
+	if os.IsNotExist(err) {
+		return allowlist, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, mysql.MaxPacketSize)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			allowlist[line] = true
+		}
+	}
+	return allowlist, s.Err()
+}
+
+//learn add the fingerprint to the allowlist and append it to the file.
+func learn(fp string) {
+	_firewallMu.Lock()
+	defer _firewallMu.Unlock()
+	if _allowlist[fp] {
+		return
+	}
+	f, err := os.OpenFile(_allowFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err == nil {
+		_, err = fmt.Fprintln(f, fp)
+		f.Close()
+	}
+	if err != nil {
+		log.Errorf("firewall learn %.200s: %v", fp, err)
+		return
+	}
+	_allowlist[fp] = true
+	log.Infof("firewall learned: %.200s", fp)
+}
+
+func listed(fp string) bool {
+	_firewallMu.RLock()
+	defer _firewallMu.RUnlock()
+	return _allowlist[fp]
+}
+
+//fwStmt the statement checked by the firewall, the fingerprint and the parsed statement are made on demand.
+type fwStmt struct {
+	c      *Client
+	sql    []byte
+	typ    parser.StmtType
+	ip     net.IP
+	fp     string
+	parsed bool
+	stmt   parser.Statement
+}
+
+func (s *fwStmt) fingerprint() string {
+	if s.fp == "" {
+		s.fp = parser.Fingerprint(s.sql)
+	}
+	return s.fp
+}
+
+func (s *fwStmt) parse() parser.Statement {
+	if !s.parsed {
+		s.stmt, _ = parser.Parse(s.sql)
+		s.parsed = true
+	}
+	return s.stmt
+}
+
+func (r *firewallRule) match(s *fwStmt) bool {
+	if r.users != nil && !r.users[s.c.user] {
+		return false
+	}
+	if len(r.nets) > 0 && !containsIP(r.nets, s.ip) {
+		return false
+	}
+	if r.types != nil && !r.types[s.typ] {
+		return false
+	}
+	if r.re != nil && !r.re.Match(s.sql) {
+		return false
+	}
+	if r.digests != nil && !r.digests[s.fingerprint()] && !r.digests[parser.DigestID(s.fingerprint())] {
+		return false
+	}
+	if r.Unlisted && listed(s.fingerprint()) {
+		return false
+	}
+	if r.NoWhere && !noWhere(s.parse()) {
+		return false
+	}
+	if r.tables != nil && !r.matchTables(s) {
+		return false
+	}
+	return true
+}
+
+//stringSet the set of the list, nil if the list is empty.
+func stringSet(list []string) map[string]bool {
+	if len(list) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(list))
+	for _, s := range list {
+		set[s] = true
+	}
+	return set
+}
+
+//ipNets parse the ips or CIDRs, the ip is a CIDR of itself.
+func ipNets(srcs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, src := range srcs {
+		if !strings.Contains(src, "/") {
+			if ip := net.ParseIP(src); ip != nil && ip.To4() != nil {
+				src += "/32"
+			} else {
+				src += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(src)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//matchTables any table of the statement is in the rule, the table of the rule matches it in any db.
+func (r *firewallRule) matchTables(s *fwStmt) bool {
+	stmt := s.parse()
+	if stmt == nil {
+		return false
+	}
+	for _, t := range parser.Tables(stmt) {
+		db := t.Schema
+		if db == "" {
+			db = s.c.dbname
+		}
+		name := strings.ToLower(t.Name)
+		if r.tables[name] || r.tables[strings.ToLower(db)+"."+name] {
+			return true
+		}
+	}
+	return false
+}
+
+//noWhere the statement is an UPDATE or DELETE without WHERE.
+func noWhere(stmt parser.Statement) bool {
+	switch s := stmt.(type) {
+	case *parser.Update:
+		return s.Where == nil
+	case *parser.Delete:
+		return s.Where == nil
+	}
+	return false
+}
+
+//checkFirewall check the rules in order, the statement denied returns ER_SPECIFIC_ACCESS_DENIED_ERROR.
+func (c *Client) checkFirewall(sql []byte) error {
+	_firewallMu.RLock()
+	rules := _rules
+	_firewallMu.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+	s := &fwStmt{c: c, sql: sql, typ: parser.Classify(sql), ip: c.ip()}
+	for _, r := range rules {
+		if !r.match(s) {
+			continue
+		}
+		_firewallMatches.With(r.Name, r.Action).Inc()
+		switch r.Action {
+		case actionLog:
+			log.Warnf("Firewall %q: %v@%v %.200s", r.Name, c.user, c.Addr(), sql)
+			continue
+		case actionLearn:
+			learn(s.fingerprint())
+		case actionDeny:
+			log.Warnf("Firewall %q deny: %v@%v %.200s", r.Name, c.user, c.Addr(), sql)
+			return mysql.NewErrf(mysql.ErrSpecificAccessDenied, "Access denied for user '%v' by the firewall rule '%v'", c.user, r.Name)
+		}
+		return nil
+	}
+	return nil
+}
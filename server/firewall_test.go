@@ -0,0 +1,86 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_Firewall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "igo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	allowFile := filepath.Join(dir, "allowlist")
+
+	conf := &config.Config{
+		Server: config.ServerConfig{FirewallAllowlist: allowFile},
+		Rules: []config.FirewallRule{
+			{Name: "admin", Action: "allow", Users: []string{"root"}, Sources: []string{"127.0.0.1"}},
+			{Name: "outfile", Action: "deny", Regex: "(?i)into\\s+outfile"},
+			{Name: "no-drop", Action: "deny", Users: []string{"app"}, Types: []string{"drop", "truncate"}},
+			{Name: "no-where", Action: "deny", NoWhere: true},
+			{Name: "secret", Action: "deny", Tables: []string{"db.secret"}},
+			{Name: "audit", Action: "log", Tables: []string{"user"}},
+			{Name: "learn", Action: "learn", Users: []string{"dev"}},
+			{Name: "allowlist", Action: "deny", Users: []string{"app"}, Unlisted: true},
+		},
+	}
+	if err := setFirewall(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer setFirewall(&config.Config{})
+
+	c, done := testClient(t, &conf.Server)
+	defer done()
+	c.dbname = "db"
+	check := func(user, sql, rule string) {
+		c.user = user
+		err := c.checkFirewall([]byte(sql))
+		if rule == "" {
+			if err != nil {
+				t.Fatal(user, sql, err)
+			}
+			return
+		}
+		if e, ok := err.(*mysql.SQLError); !ok || e.Code != mysql.ErrSpecificAccessDenied || !strings.Contains(e.Message, rule) {
+			t.Fatal(user, sql, err)
+		}
+	}
+
+	check("root", "drop table user", "")
+	check("root", "select * from t into outfile '/tmp/t'", "")
+	check("dev", "select * from t into OUTFILE '/tmp/t'", "outfile")
+	check("app", "DROP TABLE user", "no-drop")
+	check("app", "truncate user", "no-drop")
+	check("dev", "delete from user", "no-where")
+	check("dev", "update user set a = 1", "no-where")
+	check("dev", "select * from secret", "secret")
+	check("dev", "select * from t where id in (select id from db.secret)", "secret")
+
+	//learned by dev, then allowed for app
+	check("app", "select * from user where id = 1", "allowlist")
+	check("dev", "select * from user where id = 2", "")
+	check("dev", "update user set a = 1 where id = 2", "")
+	check("app", "select * from user where id = 3", "")
+	check("app", "update user set a = 2 where id = 3", "")
+	check("app", "delete from user where id = 3", "allowlist")
+
+	//the learned digests are kept in the file
+	if err := setFirewall(conf); err != nil {
+		t.Fatal(err)
+	}
+	check("app", "select * from user where id = 4", "")
+	if data, _ := ioutil.ReadFile(allowFile); strings.Count(string(data), "\n") != 2 {
+		t.Fatal(string(data))
+	}
+
+	setFirewall(&config.Config{})
+	check("app", "drop table user", "")
+}
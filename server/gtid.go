@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"igo/log"
+	"igo/mysql"
+)
+
+//the scopes of read your writes consistency
+const (
+	consistencySession = "session" //the reads of the client see its writes
+	consistencyToken   = "token"   //the reads see the writes with the same /*igo:token=xxx*/
+)
+
+//session state type of the gtids tracker
+//https://dev.mysql.com/doc/internals/en/packet-OK_Packet.html
+const sessionTrackGTIDs = 0x03
+
+//tokenTTL how long the gtid of a token is kept.
+const tokenTTL = 10 * time.Minute
+
+var (
+	_consistency string
+	_gtidWait    time.Duration
+	_tokens      = &gtidTokens{m: make(map[string]tokenGTID)}
+)
+
+type tokenGTID struct {
+	gtid string
+	at   time.Time
+}
+
+//gtidTokens the gtid of the last write of each token.
+type gtidTokens struct {
+	mu    sync.Mutex
+	m     map[string]tokenGTID
+	purge time.Time
+}
+
+func (t *gtidTokens) get(token string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, ok := t.m[token]
+	if !ok || nowFunc().Sub(v.at) > tokenTTL {
+		return ""
+	}
+	return v.gtid
+}
+
+func (t *gtidTokens) set(token, gtid string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := nowFunc()
+	t.m[token] = tokenGTID{gtid: gtid, at: now}
+	if now.Sub(t.purge) < tokenTTL {
+		return
+	}
+	t.purge = now
+	for k, v := range t.m {
+		if now.Sub(v.at) > tokenTTL {
+			delete(t.m, k)
+		}
+	}
+}
+
+//trackedGTID read the gtid from the session state changes of the OK packet.
+func trackedGTID(state []byte) string {
+	for len(state) > 0 {
+		typ := state[0]
+		data, _, n, err := readLengthEncodedString(state[1:])
+		if err != nil {
+			return ""
+		}
+		state = state[1+n:]
+		if typ != sessionTrackGTIDs || len(data) < 1 {
+			continue
+		}
+		// encoding specification [1 byte], gtid [length encoded string]
+		gtid, _, _, err := readLengthEncodedString(data[1:])
+		if err != nil {
+			return ""
+		}
+		return string(gtid)
+	}
+	return ""
+}
+
+//readYourWrites set the gtid the statement must see to the hint.
+func (c *Client) readYourWrites(h *hint) *hint {
+	var gtid string
+	switch _consistency {
+	case consistencySession:
+		gtid = c.gtid
+	case consistencyToken:
+		c.token = ""
+		if h != nil && h.token != "" {
+			c.token = h.token
+			gtid = _tokens.get(h.token)
+		}
+	}
+	if gtid == "" {
+		return h
+	}
+	if h == nil {
+		h = &hint{shard: -1}
+	}
+	h.gtid = gtid
+	return h
+}
+
+//trackGTID save the gtid of the write executed on the connection.
+func (c *Client) trackGTID(mc *mysqlConn) {
+	c.saveGTID(mc.gtid)
+	mc.gtid = ""
+}
+
+//saveGTID save the gtid of the write for the session or the token, not safe to run in parallel.
+func (c *Client) saveGTID(gtid string) {
+	if gtid == "" {
+		return
+	}
+	switch _consistency {
+	case consistencySession:
+		c.gtid = gtid
+	case consistencyToken:
+		if c.token != "" {
+			_tokens.set(c.token, gtid)
+		}
+	}
+}
+
+//executed the database has executed the gtid, it wait for the gtid at most wait if wait > 0.
+func (m *MysqlDB) executed(gtid string, wait time.Duration) bool {
+	conn := m.getConn()
+	if conn == nil {
+		return false
+	}
+	defer m.putConn(conn)
+
+	gtid = strings.Replace(gtid, "'", "", -1)
+	//WAIT_FOR_EXECUTED_GTID_SET return 0 if executed, GTID_SUBSET return 1.
+	query := fmt.Sprintf("SELECT WAIT_FOR_EXECUTED_GTID_SET('%s', %.3f) = 0", gtid, wait.Seconds())
+	if wait <= 0 {
+		query = fmt.Sprintf("SELECT GTID_SUBSET('%s', @@GLOBAL.gtid_executed)", gtid)
+	}
+	res, err := conn.Query([]byte(string(mysql.ComQuery) + query))
+	if err != nil {
+		log.Error("check gtid:", err)
+		return false
+	}
+	// column count, column, EOF, row, EOF
+	if len(res) < 5 {
+		return false
+	}
+	row, err := parseTextRow(res[3], 1)
+	if err != nil {
+		return false
+	}
+	return string(row[0]) == "1"
+}
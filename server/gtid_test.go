@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"igo/mysql"
+)
+
+func Test_HandleOkPacketGTID(t *testing.T) {
+	gtid := "3e11fa47-71ca-11e1-9e33-c80aa9429562:23"
+	data := appendLengthEncodedInteger(nil, uint64(len(gtid)))
+	data = append([]byte{0}, append(data, gtid...)...)
+	state := append([]byte{sessionTrackGTIDs}, appendLengthEncodedInteger(nil, uint64(len(data)))...)
+	state = append(state, data...)
+
+	status := mysql.StatusInAutocommit | mysql.StatusSessionStateChanged
+	pkt := []byte{mysql.HeaderOK, 1, 0, byte(status), byte(status >> 8), 0, 0, 0}
+	pkt = append(pkt, appendLengthEncodedInteger(nil, uint64(len(state)))...)
+	pkt = append(pkt, state...)
+
+	mc := &mysqlConn{sessionTrack: true}
+	ok, err := mc.handleOkPacket(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.gtid != gtid {
+		t.Fatalf("got gtid %q", mc.gtid)
+	}
+	want := okPacket(1, 0, mysql.StatusInAutocommit)
+	if !bytes.Equal(ok, want) {
+		t.Fatalf("got %v, want %v", ok, want)
+	}
+	if mc.affectedRows != 1 {
+		t.Fatal("affected rows", mc.affectedRows)
+	}
+
+	//untracked connection keep the packet
+	mc = &mysqlConn{}
+	if ok, err = mc.handleOkPacket(want); err != nil || !bytes.Equal(ok, want) || mc.gtid != "" {
+		t.Fatal(ok, err)
+	}
+
+	//the bare OK packet ends after the warning count, as read by readPacket with cap == len
+	bare := []byte{mysql.HeaderOK, 0, 0, byte(mysql.StatusInAutocommit), 0, 0, 0}
+	mc = &mysqlConn{sessionTrack: true}
+	if ok, err = mc.handleOkPacket(bare[:len(bare):len(bare)]); err != nil || !bytes.Equal(ok, bare) || mc.gtid != "" {
+		t.Fatal(ok, err)
+	}
+}
+
+func Test_ReadYourWrites(t *testing.T) {
+	defer func() { _consistency = "" }()
+
+	_consistency = consistencySession
+	c := &Client{}
+	if h := c.readYourWrites(nil); h != nil {
+		t.Fatal("expect nil hint before write")
+	}
+	c.trackGTID(&mysqlConn{gtid: "uuid:1"})
+	if h := c.readYourWrites(nil); h == nil || h.gtid != "uuid:1" || h.shard != -1 {
+		t.Fatalf("got %+v", h)
+	}
+
+	_consistency = consistencyToken
+	c, other := &Client{}, &Client{}
+	h, _ := parseHints([]byte("/*igo:token=order-1*/ insert into t values (1)"), true)
+	c.readYourWrites(h)
+	c.trackGTID(&mysqlConn{gtid: "uuid:2"})
+	h, _ = parseHints([]byte("/*igo:token=order-1*/ select 1"), true)
+	if h = other.readYourWrites(h); h.gtid != "uuid:2" {
+		t.Fatalf("got %+v", h)
+	}
+	if h := other.readYourWrites(nil); h != nil {
+		t.Fatalf("got %+v", h)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+import (
+	"igo/log"
+	"igo/mysql/parser"
+)
+
+//hintPrefix the comment begin with it is the routing hint, such as /*igo:master*/.
+const hintPrefix = "igo:"
+
+//hint the routing hint of the statement.
+type hint struct {
+	node    nodeType //masterNode, slaveNode or autoNode
+	shard   int      //the index of the shard rule nodes, -1 if not set
+	cluster string   //the node name
+	token   string   //the consistency token
+	gtid    string   //the gtid the read must see, set by the read your writes
+}
+
+//parseHints read the hints of /*igo:master*/, /*igo:slave*/, /*igo:shard=N*/,
+///*igo:cluster=name*/ and /*igo:token=xxx*/, return nil if the sql has no hint.
+//The hint comments are removed from the returned sql if strip.
+func parseHints(sql []byte, strip bool) (*hint, []byte) {
+	if !bytes.Contains(sql, []byte(hintPrefix)) {
+		return nil, sql
+	}
+
+	var h *hint
+	var out []byte
+	last := 0
+	t := parser.NewTokenizer(sql)
+	t.KeepComments = true
+	for {
+		tok, err := t.Next()
+		if err != nil {
+			break
+		}
+		body := strings.TrimSpace(tok.Val)
+		if tok.Type != parser.TokComment || !strings.HasPrefix(body, hintPrefix) {
+			continue
+		}
+		if h == nil {
+			h = &hint{shard: -1}
+		}
+		for _, word := range strings.FieldsFunc(body[len(hintPrefix):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			h.set(word)
+		}
+		if strip {
+			out = append(out, sql[last:tok.Pos]...)
+			last = tok.End
+		}
+	}
+	if h == nil || !strip {
+		return h, sql
+	}
+	return h, append(out, sql[last:]...)
+}
+
+func (h *hint) set(word string) {
+	name, value := word, ""
+	if i := strings.IndexByte(word, '='); i >= 0 {
+		name, value = word[:i], word[i+1:]
+	}
+	switch strings.ToLower(name) {
+	case "master":
+		h.node = masterNode
+		return
+	case "slave":
+		h.node = slaveNode
+		return
+	case "shard":
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			h.shard = n
+			return
+		}
+	case "cluster":
+		if value != "" {
+			h.cluster = value
+			return
+		}
+	case "token":
+		if value != "" {
+			h.token = value
+			return
+		}
+	}
+	log.Warnf("unknown hint %q", hintPrefix+word)
+}
+
+//nodeType the node type of the statement, the hint first,
+//then the read statement goes to the slave.
+func (h *hint) nodeType(sql []byte) nodeType {
+	if h != nil && h.node != autoNode {
+		return h.node
+	}
+	if readOnly(sql) {
+		return slaveNode
+	}
+	return masterNode
+}
+
+//readOnly the statement is a select without lock.
+func readOnly(sql []byte) bool {
+	if parser.Classify(sql) != parser.StmtSelect {
+		return false
+	}
+	stmt, err := parser.Parse(sql)
+	if err != nil {
+		return false
+	}
+	switch s := stmt.(type) {
+	case *parser.Select:
+		return s.Lock == ""
+	case *parser.Union:
+		for _, sel := range s.Selects {
+			if sel.Lock != "" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+
+	"igo/config"
+)
+
+func Test_ParseHints(t *testing.T) {
+	h, sql := parseHints([]byte("select 1"), true)
+	if h != nil || string(sql) != "select 1" {
+		t.Fatal(h, string(sql))
+	}
+
+	h, sql = parseHints([]byte("/*igo:slave*/ select /* keep */ 'igo:x' /*igo:shard=3, cluster=reporting*/ from t"), true)
+	if h == nil || h.node != slaveNode || h.shard != 3 || h.cluster != "reporting" {
+		t.Fatalf("got %+v", h)
+	}
+	if string(sql) != " select /* keep */ 'igo:x'  from t" {
+		t.Fatalf("got %q", sql)
+	}
+
+	h, sql = parseHints([]byte("select /* igo:master */ 1"), false)
+	if h == nil || h.node != masterNode || h.shard != -1 || string(sql) != "select /* igo:master */ 1" {
+		t.Fatalf("got %+v %q", h, sql)
+	}
+
+	h, _ = parseHints([]byte("select /*igo:primary*/ 1"), true)
+	if h == nil || h.node != autoNode {
+		t.Fatalf("got %+v", h)
+	}
+}
+
+func Test_RouteHint(t *testing.T) {
+	master, slave, n1 := &MysqlDB{}, &MysqlDB{}, &MysqlDB{}
+	_nodes = map[string]*node{
+		defaultNode: {master: master, slaves: []*MysqlDB{slave}},
+		"n1":        {master: n1},
+	}
+	r, err := newShardRule(&config.ShardConfig{Table: "user", Key: "uid", Rule: "hash", Nodes: []string{"default", "n1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_shards = map[string]*shardRule{"user": r}
+	defer func() {
+		_nodes = make(map[string]*node)
+		_shards = make(map[string]*shardRule)
+	}()
+
+	cases := []struct {
+		sql string
+		db  *MysqlDB
+	}{
+		{"select * from t", slave},
+		{"select * from t for update", master},
+		{"(select * from t) union (select * from t2 lock in share mode)", master},
+		{"with x as (select 1) select * from x for update", master},
+		{"update t set a = 1", master},
+		{"/*igo:master*/ select * from t", master},
+		{"/*igo:slave*/ select * from t for update", slave},
+		{"/*igo:cluster=n1*/ select * from t", n1},
+		{"/*igo:shard=1*/ select * from user", n1},
+		{"select * from user where uid = 2", slave},
+	}
+	for _, c := range cases {
+		h, sql := parseHints([]byte(c.sql), true)
+		dbs, err := route(h, sql, planShard(sql), nil)
+		if err != nil {
+			t.Fatal(c.sql, err)
+		}
+		if len(dbs) != 1 || dbs[0] != c.db {
+			t.Fatalf("%v: routed to wrong db", c.sql)
+		}
+	}
+
+	for _, sql := range []string{
+		"/*igo:cluster=none*/ select 1",
+		"/*igo:shard=2*/ select * from user",
+		"/*igo:shard=0*/ select * from t",
+	} {
+		h, sql := parseHints([]byte(sql), true)
+		if _, err := route(h, sql, planShard(sql), nil); err == nil {
+			t.Fatalf("%s: expect error", sql)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"strconv"
+	"sync"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+//resultLimit the max rows and bytes of a result set, 0 no limit.
+type resultLimit struct {
+	rows  int
+	bytes int
+}
+
+func (l resultLimit) exceeded(rows, bytes int) bool {
+	return (l.rows > 0 && rows > l.rows) || (l.bytes > 0 && bytes > l.bytes)
+}
+
+//exceededBy the rows of the result set packets exceed the limit, for the result not read by readRows.
+func (l resultLimit) exceededBy(res [][]byte) bool {
+	if len(res) == 0 {
+		return false
+	}
+	num, _, _ := readLengthEncodedInteger(res[0])
+	first := int(num) + 2
+	if first >= len(res) {
+		return false
+	}
+	rows, size := res[first:len(res)-1], 0
+	for _, data := range rows {
+		size += len(data)
+	}
+	return l.exceeded(len(rows), size)
+}
+
+//the result limits of the server, replaced by setResultLimit.
+var (
+	_limitMu     sync.RWMutex
+	_resultLimit resultLimit
+	_drainResult bool //read and discard the rest rows instead of killing the query
+	_injectLimit bool
+)
+
+//errResultTooLarge the result set is stopped for exceeding the max rows or bytes.
+var errResultTooLarge = mysql.NewErrf(mysql.ErrQueryInterrupted,
+	"Query execution was interrupted, the result set exceeds maxResultRows or maxResultBytes")
+
+func setResultLimit(conf *config.ServerConfig) {
+	_limitMu.Lock()
+	_resultLimit = resultLimit{rows: conf.MaxResultRows, bytes: conf.MaxResultBytes}
+	_drainResult = conf.ResultLimit == "drain"
+	_injectLimit = conf.InjectLimit
+	_limitMu.Unlock()
+}
+
+//resultLimit the result limit of the client, the user config overrides the server one.
+func (c *Client) resultLimit() resultLimit {
+	_userMu.RLock()
+	u := _users[c.user]
+	_userMu.RUnlock()
+	_limitMu.RLock()
+	lim := _resultLimit
+	_limitMu.RUnlock()
+	if u != nil && u.MaxResultRows > 0 {
+		lim.rows = u.MaxResultRows
+	}
+	if u != nil && u.MaxResultBytes > 0 {
+		lim.bytes = u.MaxResultBytes
+	}
+	return lim
+}
+
+//injectLimit add LIMIT maxResultRows to the SELECT without LIMIT, the others are returned as is.
+func (c *Client) injectLimit(query []byte) []byte {
+	_limitMu.RLock()
+	inject := _injectLimit
+	_limitMu.RUnlock()
+	rows := c.resultLimit().rows
+	if !inject || rows <= 0 || parser.Classify(query) != parser.StmtSelect {
+		return query
+	}
+	stmt, err := parser.Parse(query)
+	s, ok := stmt.(*parser.Select)
+	if err != nil || !ok || s.Limit != nil {
+		return query
+	}
+	at := s.LimitAt
+	sql := make([]byte, 0, len(query)+16)
+	sql = append(sql, query[:at]...)
+	if at > 0 && query[at-1] != ' ' {
+		sql = append(sql, ' ')
+	}
+	sql = append(sql, "LIMIT "+strconv.Itoa(rows)...)
+	if at < len(query) {
+		sql = append(sql, ' ')
+	}
+	return append(sql, query[at:]...)
+}
+
+//stopResult stop the result set over the limit, the query is killed unless resultLimit is drain,
+//then the rest rows are discarded, the connection is marked broken if it can not be reused.
+func (mc *mysqlConn) stopResult() {
+	_resultLimits.Inc()
+	_limitMu.RLock()
+	drain := _drainResult
+	_limitMu.RUnlock()
+	if !drain {
+		if err := mc.killQuery(); err != nil {
+			log.Errorf("kill query of thread %v on %v: %v", mc.threadID, mc.cfg.Addr, err)
+			mc.broken = true
+			return
+		}
+	}
+	err := mc.discardRows()
+	if drain {
+		if _, ok := err.(*mysql.MySQLError); err != nil && !ok {
+			mc.broken = true
+		}
+		return
+	}
+	mc.resetAfterKill(err)
+}
+
+//discardRows read the rows until EOF or ERR, returns the error of the ERR packet.
+func (mc *mysqlConn) discardRows() error {
+	for {
+		data, err := mc.readPacket()
+		if err != nil {
+			return err
+		}
+		switch data[0] {
+		case mysql.HeaderERR:
+			return mc.handleErrorPacket(data)
+		case mysql.HeaderEOF:
+			if len(data) >= 9 {
+				continue
+			}
+			if len(data) == 5 {
+				mc.status = readStatus(data[3:])
+			}
+			return nil
+		}
+	}
+}
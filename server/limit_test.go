@@ -0,0 +1,121 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_ResultLimit(t *testing.T) {
+	addr, queries, stop := testBackend(t)
+	defer stop()
+	conf := &config.Config{Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: addr, MaxIdleConn: 2, MaxConnNum: 2,
+		MaxResultRows: 10, MaxResultBytes: 1000}}
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	setResultLimit(&conf.Server)
+	defer setResultLimit(&config.ServerConfig{})
+
+	db := GetNode(defaultNode)
+	for i := 0; i < 100; i++ {
+		if mc := db.getConn(); mc != nil {
+			db.putConn(mc)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c, done := testClient(t, &conf.Server)
+	defer done()
+	c.user = "app"
+	query := func(sql string) error {
+		c.sent = cmdStat{}
+		return c.handleQuery(append([]byte{mysql.ComQuery}, sql...))
+	}
+	drain := func() {
+		for len(queries) > 0 {
+			<-queries
+		}
+	}
+
+	if err := query("select rows 10"); err != nil || c.sent.errCode != 0 || c.sent.rows != 10 {
+		t.Fatal(err, c.sent)
+	}
+	//killed, then the connection is reused
+	drain()
+	if err := query("select rows 11"); err != nil || c.sent.errCode != mysql.ErrQueryInterrupted {
+		t.Fatal(err, c.sent)
+	}
+	if <-queries != "select rows 11" || !strings.HasPrefix(<-queries, "KILL QUERY ") {
+		t.Fatal("the query should be killed")
+	}
+	if err := query("select rows 1"); err != nil || c.sent.errCode != 0 || c.sent.rows != 1 {
+		t.Fatal(err, c.sent)
+	}
+
+	conf.Server.ResultLimit = "drain"
+	setResultLimit(&conf.Server)
+	drain()
+	setUsers([]config.UserConfig{{Name: "app", MaxResultRows: 1000, MaxResultBytes: 100}})
+	defer setUsers(nil)
+	if err := query("select rows 30"); err != nil || c.sent.errCode != mysql.ErrQueryInterrupted {
+		t.Fatal(err, c.sent)
+	}
+	if <-queries != "select rows 30" || len(queries) != 0 {
+		t.Fatal("drain should not kill the query")
+	}
+	if err := query("select rows 20"); err != nil || c.sent.errCode != 0 || c.sent.rows != 20 {
+		t.Fatal(err, c.sent)
+	}
+
+	//the statement on the connection lost while the rows are drained is dropped
+	stmt := &mysqlStmt{id: 2, mc: db.getConn(), db: db}
+	c.stmt = stmt
+	exec := func() error {
+		c.sent = cmdStat{}
+		return c.handlestmtExec([]byte{mysql.ComStmtExecute, byte(stmt.id), 0, 0, 0, 0, 1, 0, 0, 0})
+	}
+	if err := exec(); err != nil || c.sent.errCode != mysql.ErrQueryInterrupted {
+		t.Fatal(err, c.sent)
+	}
+	if c.stmt != nil || stmt.mc != nil {
+		t.Fatal("the statement should be dropped")
+	}
+	if err := exec(); err != nil || c.sent.errCode != mysql.ErrUnknownStmtHandler {
+		t.Fatal(err, c.sent)
+	}
+
+	//the hint stripped and the LIMIT injected keep the length of the query
+	setUsers(nil)
+	setResultLimit(&config.ServerConfig{MaxResultRows: 100000, InjectLimit: true})
+	drain()
+	if err := query("/*igo:slave*/select * from t"); err != nil || c.sent.errCode != 0 {
+		t.Fatal(err, c.sent)
+	}
+	if q := <-queries; q != "select * from t LIMIT 100000" {
+		t.Fatal("the query is not rebuilt:", q)
+	}
+}
+
+func Test_InjectLimit(t *testing.T) {
+	setResultLimit(&config.ServerConfig{MaxResultRows: 100, InjectLimit: true})
+	defer setResultLimit(&config.ServerConfig{})
+	c := &Client{user: "app"}
+	for sql, want := range map[string]string{
+		"select * from t":                          "select * from t LIMIT 100",
+		"select * from t where id > 1 order by id": "select * from t where id > 1 order by id LIMIT 100",
+		"select * from t for update":               "select * from t LIMIT 100 for update",
+		"select * from t limit 5":                  "select * from t limit 5",
+		"select * from t union select * from t2":   "select * from t union select * from t2",
+		"update t set a = 1":                       "update t set a = 1",
+	} {
+		if got := string(c.injectLimit([]byte(sql))); got != want {
+			t.Fatalf("%v: got %v", sql, got)
+		}
+	}
+}
@@ -0,0 +1,840 @@
+package server
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+import (
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+var selectModifiers = map[string]bool{
+	"ALL": true, "DISTINCT": true, "DISTINCTROW": true, "HIGH_PRIORITY": true, "STRAIGHT_JOIN": true,
+	"SQL_SMALL_RESULT": true, "SQL_BIG_RESULT": true, "SQL_BUFFER_RESULT": true,
+	"SQL_CACHE": true, "SQL_NO_CACHE": true, "SQL_CALC_FOUND_ROWS": true,
+}
+
+var aggFuncs = map[string]bool{"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true}
+
+func errMerge(what string) error {
+	return mysql.NewErrf(mysql.ErrNotSupportedYet, "cross shard select: %v is not supported", what)
+}
+
+//mergeCol a column of the result set, hidden column is appended to the select list by igo.
+type mergeCol struct {
+	index  int
+	hidden bool
+	desc   bool
+}
+
+//mergeAgg an aggregate column, AVG is rewritten to SUM and a hidden COUNT column.
+type mergeAgg struct {
+	col   mergeCol
+	fn    string
+	count mergeCol
+}
+
+//mergePlan describe how to rewrite the select and merge the result sets from shards.
+type mergePlan struct {
+	query    []byte
+	star     bool
+	hidden   []string
+	distinct bool
+	aggs     []mergeAgg
+	groups   []mergeCol
+	orders   []mergeCol
+	offset   uint64
+	count    int64 //-1 if no limit
+}
+
+type selectItem struct {
+	toks  []parser.Token
+	alias string
+	name  string //column name of `[t.]col`
+	text  string
+}
+
+type sqlEdit struct {
+	pos, end int
+	s        string
+}
+
+func newSelectItem(toks []parser.Token) selectItem {
+	it := selectItem{toks: toks}
+	n := len(toks)
+	if n >= 2 && toks[n-2].Keyword() == "AS" {
+		it.alias = toks[n-1].Name()
+		toks = toks[:n-2]
+	} else if n >= 2 && toks[n-1].Name() != "" && toks[n-2].Val != "." && !isKeyword(toks[n-1].Keyword()) && toks[n-2].Val != "(" {
+		//`expr alias` without AS
+		if toks[n-2].Val == ")" || toks[n-2].Name() != "" || toks[n-2].IsLiteral() {
+			it.alias = toks[n-1].Name()
+			toks = toks[:n-1]
+		}
+	}
+	it.toks = toks
+	it.name = refName(toks)
+	it.text = tokensKey(toks)
+	return it
+}
+
+//refName the column name if toks is `col` or `t.col`.
+func refName(toks []parser.Token) string {
+	switch {
+	case len(toks) == 1:
+		return toks[0].Name()
+	case len(toks) == 3 && toks[1].Val == ".":
+		return toks[2].Name()
+	}
+	return ""
+}
+
+func tokensKey(toks []parser.Token) string {
+	var b bytes.Buffer
+	for _, t := range toks {
+		b.WriteString(strings.ToLower(t.Val))
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func (it selectItem) isStar() bool {
+	n := len(it.toks)
+	return n > 0 && it.toks[n-1].Val == "*" && (n == 1 || it.toks[n-2].Val == ".")
+}
+
+//aggregate return the function name if the item is `FN(args)`.
+func (it selectItem) aggregate() (string, []parser.Token) {
+	toks := it.toks
+	n := len(toks)
+	if n < 3 || !aggFuncs[toks[0].Keyword()] || toks[1].Val != "(" || toks[n-1].Val != ")" {
+		return "", nil
+	}
+	depth := 0
+	for i := 1; i < n; i++ {
+		switch toks[i].Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 && i != n-1 {
+				return "", nil
+			}
+		}
+	}
+	return toks[0].Keyword(), toks[2 : n-1]
+}
+
+func hasAggregate(toks []parser.Token) bool {
+	for i := 0; i+1 < len(toks); i++ {
+		if aggFuncs[toks[i].Keyword()] && toks[i+1].Val == "(" {
+			return true
+		}
+	}
+	return false
+}
+
+//splitTop split the tokens by the comma not in parentheses.
+func splitTop(toks []parser.Token) [][]parser.Token {
+	var list [][]parser.Token
+	depth, start := 0, 0
+	for i, t := range toks {
+		switch t.Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				list = append(list, toks[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(toks) {
+		list = append(list, toks[start:])
+	}
+	return list
+}
+
+//newMergePlan analyze the select and rewrite it for the shards:
+//AVG is split to SUM and COUNT, ORDER BY and GROUP BY columns not in the select list
+//are appended, LIMIT offset is merged to the count, or removed for aggregation.
+func newMergePlan(sql []byte) (*mergePlan, error) {
+	toks := tokenize(sql)
+	if len(toks) == 0 || toks[0].Keyword() != "SELECT" {
+		return nil, errMerge("statement except SELECT")
+	}
+	p := &mergePlan{count: -1}
+	var edits []sqlEdit
+
+	i := 1
+	for ; i < len(toks) && selectModifiers[toks[i].Keyword()]; i++ {
+		if kw := toks[i].Keyword(); kw == "DISTINCT" || kw == "DISTINCTROW" {
+			p.distinct = true
+		}
+	}
+
+	//select list
+	from := len(toks)
+	depth := 0
+	for j := i; j < len(toks); j++ {
+		switch toks[j].Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if kw := toks[j].Keyword(); depth == 0 && (kw == "FROM" || kw == "INTO") {
+			from = j
+			break
+		}
+	}
+	var items []selectItem
+	for _, it := range splitTop(toks[i:from]) {
+		items = append(items, newSelectItem(it))
+	}
+
+	//clauses after from
+	clauses := make(map[string]int)
+	var bounds []int
+	depth = 0
+	for j := from; j < len(toks); j++ {
+		switch toks[j].Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth != 0 {
+			continue
+		}
+		kw := toks[j].Keyword()
+		switch kw {
+		case "GROUP", "ORDER":
+			if j+1 >= len(toks) || toks[j+1].Keyword() != "BY" {
+				continue
+			}
+		case "HAVING", "LIMIT", "UNION", "FOR", "LOCK", "PROCEDURE", "INTO", "WINDOW":
+		default:
+			continue
+		}
+		if _, ok := clauses[kw]; !ok {
+			clauses[kw] = j
+			bounds = append(bounds, j)
+		}
+	}
+	bounds = append(bounds, len(toks))
+	clauseEnd := func(j int) int {
+		for _, b := range bounds {
+			if b > j {
+				return b
+			}
+		}
+		return len(toks)
+	}
+	if _, ok := clauses["UNION"]; ok {
+		return nil, errMerge("UNION")
+	}
+
+	//aggregate functions
+	for idx, it := range items {
+		if it.isStar() {
+			p.star = true
+			continue
+		}
+		fn, args := it.aggregate()
+		if fn == "" {
+			if hasAggregate(it.toks) {
+				return nil, errMerge("aggregate expression")
+			}
+			continue
+		}
+		if p.star {
+			return nil, errMerge("aggregate after *")
+		}
+		if len(args) > 0 && args[0].Keyword() == "DISTINCT" {
+			return nil, errMerge(fn + "(DISTINCT)")
+		}
+		agg := mergeAgg{col: mergeCol{index: idx}, fn: fn}
+		if fn == "AVG" {
+			edits = append(edits, sqlEdit{it.toks[0].Pos, it.toks[0].End, "SUM"})
+			agg.count = p.addHidden("COUNT(" + string(sql[args[0].Pos:args[len(args)-1].End]) + ")")
+		}
+		p.aggs = append(p.aggs, agg)
+	}
+
+	//group by
+	if j, ok := clauses["GROUP"]; ok {
+		for _, expr := range splitTop(toks[j+2 : clauseEnd(j)]) {
+			if n := len(expr); n > 1 && (expr[n-1].Keyword() == "ASC" || expr[n-1].Keyword() == "DESC") {
+				expr = expr[:n-1]
+			}
+			if len(expr) == 0 {
+				continue
+			}
+			if expr[0].Keyword() == "WITH" {
+				return nil, errMerge("WITH ROLLUP")
+			}
+			col, err := p.ref(sql, expr, items)
+			if err != nil {
+				return nil, err
+			}
+			p.groups = append(p.groups, col)
+		}
+	}
+	if _, ok := clauses["HAVING"]; ok && (len(p.aggs) > 0 || len(p.groups) > 0) {
+		return nil, errMerge("HAVING")
+	}
+
+	//order by
+	if j, ok := clauses["ORDER"]; ok {
+		for _, expr := range splitTop(toks[j+2 : clauseEnd(j)]) {
+			desc := false
+			if n := len(expr); n > 1 {
+				switch expr[n-1].Keyword() {
+				case "DESC":
+					desc = true
+					expr = expr[:n-1]
+				case "ASC":
+					expr = expr[:n-1]
+				}
+			}
+			if len(expr) == 0 {
+				continue
+			}
+			col, err := p.ref(sql, expr, items)
+			if err != nil {
+				return nil, err
+			}
+			col.desc = desc
+			p.orders = append(p.orders, col)
+		}
+	}
+
+	//limit
+	if j, ok := clauses["LIMIT"]; ok {
+		end := clauseEnd(j)
+		args := toks[j+1 : end]
+		var nums []uint64
+		for k, t := range args {
+			if k%2 == 1 {
+				if t.Val != "," && t.Keyword() != "OFFSET" {
+					return nil, errMerge("LIMIT " + t.Val)
+				}
+				continue
+			}
+			n, err := strconv.ParseUint(t.Val, 10, 64)
+			if err != nil || t.Type != parser.TokNumber {
+				return nil, errMerge("LIMIT " + t.Val)
+			}
+			nums = append(nums, n)
+		}
+		switch {
+		case len(nums) == 1:
+			p.count = int64(nums[0])
+		case len(nums) == 2 && args[1].Val == ",":
+			p.offset, p.count = nums[0], int64(nums[1])
+		case len(nums) == 2:
+			p.offset, p.count = nums[1], int64(nums[0])
+		default:
+			return nil, errMerge("LIMIT")
+		}
+
+		pos, stop := toks[j].Pos, len(sql)
+		if end < len(toks) {
+			stop = toks[end].Pos
+		}
+		limit := ""
+		if !p.aggregated() {
+			limit = fmt.Sprintf("LIMIT %d ", p.offset+uint64(p.count))
+		}
+		edits = append(edits, sqlEdit{pos, stop, limit})
+	}
+
+	if len(p.hidden) > 0 {
+		pos := len(sql)
+		if from < len(toks) {
+			pos = toks[from].Pos
+		}
+		edits = append(edits, sqlEdit{pos, pos, ", " + strings.Join(p.hidden, ", ") + " "})
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].pos < edits[j].pos })
+	last := 0
+	for _, e := range edits {
+		p.query = append(p.query, sql[last:e.pos]...)
+		p.query = append(p.query, e.s...)
+		last = e.end
+	}
+	p.query = append(p.query, sql[last:]...)
+	return p, nil
+}
+
+func (p *mergePlan) aggregated() bool {
+	return len(p.aggs) > 0 || len(p.groups) > 0 || p.distinct
+}
+
+func (p *mergePlan) addHidden(expr string) mergeCol {
+	p.hidden = append(p.hidden, expr)
+	return mergeCol{index: len(p.hidden) - 1, hidden: true}
+}
+
+//ref resolve the GROUP BY or ORDER BY expression to the select list, or append it.
+func (p *mergePlan) ref(sql []byte, expr []parser.Token, items []selectItem) (mergeCol, error) {
+	if len(expr) == 1 && expr[0].Type == parser.TokNumber {
+		n, err := strconv.Atoi(expr[0].Val)
+		if err != nil || n < 1 {
+			return mergeCol{}, errMerge("position " + expr[0].Val)
+		}
+		return mergeCol{index: n - 1}, nil
+	}
+	if !p.star {
+		name, text := refName(expr), tokensKey(expr)
+		for idx, it := range items {
+			if (name != "" && (it.alias == name || (it.alias == "" && it.name == name))) || it.text == text {
+				return mergeCol{index: idx}, nil
+			}
+		}
+	}
+
+	col := p.addHidden(string(sql[expr[0].Pos:expr[len(expr)-1].End]))
+	if hasAggregate(expr) {
+		fn, _ := newSelectItem(expr).aggregate()
+		if fn == "" || fn == "AVG" {
+			return mergeCol{}, errMerge("aggregate expression")
+		}
+		p.aggs = append(p.aggs, mergeAgg{col: col, fn: fn})
+	}
+	return col, nil
+}
+
+//columnInfo the column definition of result set.
+type columnInfo struct {
+	name      string
+	fieldType byte
+	flags     mysql.FieldFlag
+}
+
+// parseColumn read the column definition packet.
+// http://dev.mysql.com/doc/internals/en/com-query-response.html#packet-Protocol::ColumnDefinition41
+func parseColumn(data []byte) (columnInfo, error) {
+	var col columnInfo
+	pos := 0
+	// catalog, schema, table, org_table
+	for i := 0; i < 4; i++ {
+		n, err := skipLengthEncodedString(data[pos:])
+		if err != nil {
+			return col, err
+		}
+		pos += n
+	}
+	// name
+	name, _, n, err := readLengthEncodedString(data[pos:])
+	if err != nil {
+		return col, err
+	}
+	col.name = string(name)
+	pos += n
+	// org_name
+	if n, err = skipLengthEncodedString(data[pos:]); err != nil {
+		return col, err
+	}
+	pos += n
+	// filler [1 byte], charset [2 bytes], length [4 bytes]
+	pos += 1 + 2 + 4
+	if len(data) < pos+3 {
+		return col, mysql.ErrMalformPkt
+	}
+	col.fieldType = data[pos]
+	col.flags = mysql.FieldFlag(uint16(data[pos+1]) | uint16(data[pos+2])<<8)
+	return col, nil
+}
+
+//parseTextRow read the text protocol row, NULL is nil.
+func parseTextRow(data []byte, count int) ([][]byte, error) {
+	row := make([][]byte, count)
+	pos := 0
+	for i := 0; i < count; i++ {
+		v, isNull, n, err := readLengthEncodedString(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		if !isNull && v == nil {
+			v = []byte{}
+		}
+		if !isNull {
+			row[i] = v
+		}
+		pos += n
+	}
+	return row, nil
+}
+
+func appendTextRow(data []byte, row [][]byte) []byte {
+	for _, v := range row {
+		if v == nil {
+			data = append(data, 0xfb)
+			continue
+		}
+		data = appendLengthEncodedInteger(data, uint64(len(v)))
+		data = append(data, v...)
+	}
+	return data
+}
+
+func isNumeric(t byte) bool {
+	switch t {
+	case mysql.FieldTypeTiny, mysql.FieldTypeShort, mysql.FieldTypeLong, mysql.FieldTypeFloat,
+		mysql.FieldTypeDouble, mysql.FieldTypeLongLong, mysql.FieldTypeInt24, mysql.FieldTypeYear,
+		mysql.FieldTypeDecimal, mysql.FieldTypeNewDecimal:
+		return true
+	}
+	return false
+}
+
+//compareValue compare the values as the column type, NULL is the smallest.
+func compareValue(a, b []byte, col columnInfo) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if isNumeric(col.fieldType) {
+		if x, err := strconv.ParseInt(string(a), 10, 64); err == nil {
+			if y, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				}
+				return 0
+			}
+		}
+		x, okx := new(big.Rat).SetString(string(a))
+		y, oky := new(big.Rat).SetString(string(b))
+		if okx && oky {
+			return x.Cmp(y)
+		}
+	}
+	if col.flags&mysql.FlagBinary != 0 {
+		return bytes.Compare(a, b)
+	}
+	return bytes.Compare(bytes.ToLower(a), bytes.ToLower(b))
+}
+
+//addNumber add the decimal strings, keep the max scale of them.
+func addNumber(a, b []byte) []byte {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	x, okx := new(big.Rat).SetString(string(a))
+	y, oky := new(big.Rat).SetString(string(b))
+	if !okx || !oky {
+		return a
+	}
+	scale := fracDigits(a)
+	if s := fracDigits(b); s > scale {
+		scale = s
+	}
+	return []byte(x.Add(x, y).FloatString(scale))
+}
+
+func fracDigits(v []byte) int {
+	if i := bytes.IndexByte(v, '.'); i >= 0 {
+		return len(v) - i - 1
+	}
+	return 0
+}
+
+//merge merge the result sets of the shards to one result set.
+func (p *mergePlan) merge(results [][][]byte) ([][]byte, error) {
+	var defs [][]byte
+	var cols []columnInfo
+	var colEOF, eof []byte
+	shards := make([][][][]byte, 0, len(results))
+	for _, res := range results {
+		if len(res) == 0 || res[0][0] == mysql.HeaderOK || res[0][0] == mysql.HeaderERR {
+			return nil, errMerge("statement without result set")
+		}
+		num, _, _ := readLengthEncodedInteger(res[0])
+		n := int(num)
+		if len(res) < n+3 {
+			return nil, mysql.ErrMalformPkt
+		}
+		if defs == nil {
+			defs, colEOF = res[1:1+n], res[1+n]
+			for _, d := range defs {
+				col, err := parseColumn(d)
+				if err != nil {
+					return nil, err
+				}
+				cols = append(cols, col)
+			}
+		} else if n != len(defs) {
+			return nil, fmt.Errorf("shard result column count mismatch %d != %d", n, len(defs))
+		}
+
+		rows := make([][][]byte, 0, len(res)-n-3)
+		for _, data := range res[2+n : len(res)-1] {
+			row, err := parseTextRow(data, n)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		shards = append(shards, rows)
+		eof = res[len(res)-1]
+	}
+
+	visible := len(cols) - len(p.hidden)
+	if visible <= 0 {
+		return nil, mysql.ErrMalformPkt
+	}
+	resolve := func(c mergeCol) (int, error) {
+		i := c.index
+		if c.hidden {
+			i += visible
+		}
+		if i < 0 || i >= len(cols) {
+			return 0, errMerge(fmt.Sprintf("column position %d", c.index+1))
+		}
+		return i, nil
+	}
+	orders := make([]mergeCol, len(p.orders))
+	for k, c := range p.orders {
+		i, err := resolve(c)
+		if err != nil {
+			return nil, err
+		}
+		orders[k] = mergeCol{index: i, desc: c.desc}
+	}
+	less := func(a, b [][]byte) bool {
+		for _, c := range orders {
+			r := compareValue(a[c.index], b[c.index], cols[c.index])
+			if c.desc {
+				r = -r
+			}
+			if r != 0 {
+				return r < 0
+			}
+		}
+		return false
+	}
+
+	var rows [][][]byte
+	switch {
+	case p.aggregated():
+		var err error
+		if rows, err = p.aggregate(shards, cols, visible, resolve); err != nil {
+			return nil, err
+		}
+		if len(orders) > 0 {
+			sort.SliceStable(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+		}
+	case len(orders) > 0:
+		rows = mergeSorted(shards, less)
+	default:
+		for _, r := range shards {
+			rows = append(rows, r...)
+		}
+	}
+
+	if p.offset >= uint64(len(rows)) {
+		rows = nil
+	} else {
+		rows = rows[p.offset:]
+	}
+	if p.count >= 0 && int64(len(rows)) > p.count {
+		rows = rows[:p.count]
+	}
+
+	res := make([][]byte, 0, visible+len(rows)+3)
+	res = append(res, appendLengthEncodedInteger(nil, uint64(visible)))
+	res = append(res, defs[:visible]...)
+	res = append(res, colEOF)
+	for _, r := range rows {
+		res = append(res, appendTextRow(nil, r[:visible]))
+	}
+	res = append(res, eof)
+	return res, nil
+}
+
+//aggregate merge the rows of same group, the groups are sorted by the group columns.
+func (p *mergePlan) aggregate(shards [][][][]byte, cols []columnInfo, visible int, resolve func(mergeCol) (int, error)) ([][][]byte, error) {
+	var groups []int
+	if p.distinct && len(p.groups) == 0 && len(p.aggs) == 0 {
+		for i := 0; i < visible; i++ {
+			groups = append(groups, i)
+		}
+	}
+	for _, c := range p.groups {
+		i, err := resolve(c)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, i)
+	}
+	type agg struct {
+		index, count int
+		fn           string
+	}
+	aggs := make([]agg, len(p.aggs))
+	for k, a := range p.aggs {
+		i, err := resolve(a.col)
+		if err != nil {
+			return nil, err
+		}
+		aggs[k] = agg{index: i, fn: a.fn, count: -1}
+		if a.fn == "AVG" {
+			if aggs[k].count, err = resolve(a.count); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	index := make(map[string]int)
+	var rows [][][]byte
+	var key bytes.Buffer
+	for _, shard := range shards {
+		for _, row := range shard {
+			key.Reset()
+			for _, i := range groups {
+				v := row[i]
+				if v == nil {
+					key.WriteString("\x00N")
+					continue
+				}
+				if cols[i].flags&mysql.FlagBinary == 0 {
+					v = bytes.ToLower(v)
+				}
+				key.WriteString(strconv.Itoa(len(v)))
+				key.WriteByte(':')
+				key.Write(v)
+			}
+			k, ok := index[key.String()]
+			if !ok {
+				index[key.String()] = len(rows)
+				rows = append(rows, append([][]byte{}, row...))
+				continue
+			}
+			acc := rows[k]
+			for _, a := range aggs {
+				switch a.fn {
+				case "COUNT", "SUM", "AVG":
+					acc[a.index] = addNumber(acc[a.index], row[a.index])
+					if a.count >= 0 {
+						acc[a.count] = addNumber(acc[a.count], row[a.count])
+					}
+				case "MIN":
+					if row[a.index] != nil && (acc[a.index] == nil || compareValue(row[a.index], acc[a.index], cols[a.index]) < 0) {
+						acc[a.index] = row[a.index]
+					}
+				case "MAX":
+					if compareValue(row[a.index], acc[a.index], cols[a.index]) > 0 {
+						acc[a.index] = row[a.index]
+					}
+				}
+			}
+		}
+	}
+
+	//aggregate without group by always return one row
+	if len(rows) == 0 && len(groups) == 0 && len(aggs) > 0 {
+		row := make([][]byte, len(cols))
+		for _, a := range aggs {
+			if a.fn == "COUNT" {
+				row[a.index] = []byte("0")
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	for _, a := range aggs {
+		if a.fn != "AVG" {
+			continue
+		}
+		for _, row := range rows {
+			sum, okSum := new(big.Rat).SetString(string(row[a.index]))
+			cnt, okCnt := new(big.Rat).SetString(string(row[a.count]))
+			if row[a.index] == nil || !okSum || !okCnt || cnt.Sign() == 0 {
+				row[a.index] = nil
+				continue
+			}
+			row[a.index] = []byte(sum.Quo(sum, cnt).FloatString(fracDigits(row[a.index]) + 4))
+		}
+	}
+
+	if len(groups) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, g := range groups {
+				if r := compareValue(rows[i][g], rows[j][g], cols[g]); r != 0 {
+					return r < 0
+				}
+			}
+			return false
+		})
+	}
+	return rows, nil
+}
+
+//rowHeap the heads of the sorted shard rows.
+type rowHeap struct {
+	shards [][][][]byte
+	heads  []int //shard index
+	pos    []int //next row of the shard
+	less   func(a, b [][]byte) bool
+}
+
+func (h *rowHeap) Len() int { return len(h.heads) }
+func (h *rowHeap) Less(i, j int) bool {
+	a, b := h.heads[i], h.heads[j]
+	return h.less(h.shards[a][h.pos[a]], h.shards[b][h.pos[b]])
+}
+func (h *rowHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *rowHeap) Push(x interface{}) { h.heads = append(h.heads, x.(int)) }
+func (h *rowHeap) Pop() interface{} {
+	n := len(h.heads)
+	x := h.heads[n-1]
+	h.heads = h.heads[:n-1]
+	return x
+}
+
+//mergeSorted k-way merge the rows sorted by the shards.
+func mergeSorted(shards [][][][]byte, less func(a, b [][]byte) bool) [][][]byte {
+	h := &rowHeap{shards: shards, pos: make([]int, len(shards)), less: less}
+	total := 0
+	for i, rows := range shards {
+		total += len(rows)
+		if len(rows) > 0 {
+			h.heads = append(h.heads, i)
+		}
+	}
+	heap.Init(h)
+	rows := make([][][]byte, 0, total)
+	for h.Len() > 0 {
+		s := h.heads[0]
+		rows = append(rows, shards[s][h.pos[s]])
+		h.pos[s]++
+		if h.pos[s] < len(shards[s]) {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"igo/mysql"
+)
+
+func Test_MergePlanRewrite(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{"select * from t order by id limit 10, 5", "select * , id from t order by id LIMIT 15 "},
+		{"select a, avg(b) from t group by a limit 3", "select a, SUM(b) , COUNT(b) from t group by a "},
+		{"select a from t order by b desc", "select a , b from t order by b desc"},
+		{"select a as x, count(*) c from t group by x order by c", "select a as x, count(*) c from t group by x order by c"},
+		{"select a from t order by 1 limit 2 offset 4", "select a from t order by 1 LIMIT 6 "},
+	}
+	for _, c := range cases {
+		p, err := newMergePlan([]byte(c.in))
+		if err != nil {
+			t.Fatal(c.in, err)
+		}
+		if string(p.query) != c.out {
+			t.Fatalf("%v: got %q, want %q", c.in, p.query, c.out)
+		}
+	}
+
+	for _, sql := range []string{
+		"select a from t union select a from t2",
+		"select count(distinct a) from t",
+		"select sum(a) + 1 from t",
+		"select a, count(*) from t group by a having count(*) > 1",
+		"select *, count(*) from t",
+	} {
+		if _, err := newMergePlan([]byte(sql)); err == nil {
+			t.Fatalf("%v: expect error", sql)
+		}
+	}
+}
+
+func testColumn(name string, fieldType byte) []byte {
+	var data []byte
+	for _, s := range []string{"def", "db", "t", "t", name, name} {
+		data = appendLengthEncodedInteger(data, uint64(len(s)))
+		data = append(data, s...)
+	}
+	data = append(data, 0x0c, 33, 0, 0, 0, 0, 0, fieldType, 0, 0, 0, 0, 0)
+	return data
+}
+
+func testResult(cols []string, types []byte, rows ...[]string) [][]byte {
+	res := [][]byte{appendLengthEncodedInteger(nil, uint64(len(cols)))}
+	for i, c := range cols {
+		res = append(res, testColumn(c, types[i]))
+	}
+	res = append(res, []byte{mysql.HeaderEOF, 0, 0, 2, 0})
+	for _, r := range rows {
+		row := make([][]byte, len(r))
+		for i, v := range r {
+			if v != "NULL" {
+				row[i] = []byte(v)
+			}
+		}
+		res = append(res, appendTextRow(nil, row))
+	}
+	return append(res, []byte{mysql.HeaderEOF, 0, 0, 2, 0})
+}
+
+func resultRows(t *testing.T, res [][]byte) []string {
+	num, _, _ := readLengthEncodedInteger(res[0])
+	n := int(num)
+	var rows []string
+	for _, data := range res[2+n : len(res)-1] {
+		row, err := parseTextRow(data, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vals []string
+		for _, v := range row {
+			if v == nil {
+				vals = append(vals, "NULL")
+				continue
+			}
+			vals = append(vals, string(v))
+		}
+		rows = append(rows, strings.Join(vals, ","))
+	}
+	return rows
+}
+
+func Test_MergeResults(t *testing.T) {
+	long, str := mysql.FieldTypeLongLong, mysql.FieldTypeVarString
+	cases := []struct {
+		sql     string
+		results [][][]byte
+		want    string
+	}{
+		{
+			"select id, name from t order by id desc limit 1, 3",
+			[][][]byte{
+				testResult([]string{"id", "name"}, []byte{long, str}, []string{"9", "a"}, []string{"5", "b"}, []string{"1", "c"}),
+				testResult([]string{"id", "name"}, []byte{long, str}, []string{"10", "d"}, []string{"6", "e"}),
+			},
+			"9,a|6,e|5,b",
+		},
+		{
+			"select count(*), sum(n), min(n), max(n), avg(n) from t",
+			[][][]byte{
+				testResult([]string{"c", "s", "mi", "ma", "a", "cnt"}, []byte{long, long, long, long, mysql.FieldTypeNewDecimal, long}, []string{"2", "3", "1", "2", "3", "2"}),
+				testResult([]string{"c", "s", "mi", "ma", "a", "cnt"}, []byte{long, long, long, long, mysql.FieldTypeNewDecimal, long}, []string{"1", "10", "10", "10", "10", "1"}),
+			},
+			"3,13,1,10,4.3333",
+		},
+		{
+			"select name, count(*) from t group by name",
+			[][][]byte{
+				testResult([]string{"name", "c"}, []byte{str, long}, []string{"a", "1"}, []string{"b", "2"}),
+				testResult([]string{"name", "c"}, []byte{str, long}, []string{"A", "3"}, []string{"NULL", "4"}),
+			},
+			"NULL,4|a,4|b,2",
+		},
+		{
+			"select name from t order by id",
+			[][][]byte{
+				testResult([]string{"name", "id"}, []byte{str, long}, []string{"x", "2"}, []string{"y", "11"}),
+				testResult([]string{"name", "id"}, []byte{str, long}, []string{"z", "3"}),
+			},
+			"x|z|y",
+		},
+	}
+	for _, c := range cases {
+		p, err := newMergePlan([]byte(c.sql))
+		if err != nil {
+			t.Fatal(c.sql, err)
+		}
+		res, err := p.merge(c.results)
+		if err != nil {
+			t.Fatal(c.sql, err)
+		}
+		if got := strings.Join(resultRows(t, res), "|"); got != c.want {
+			t.Fatalf("%v: got %v, want %v", c.sql, got, c.want)
+		}
+	}
+}
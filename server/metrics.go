@@ -0,0 +1,126 @@
+package server
+
+import (
+	"strconv"
+	"time"
+)
+
+import (
+	"igo/metrics"
+	"igo/mysql"
+)
+
+//the routes of the command in the metrics
+const (
+	routeLocal   = "local"   //answered by igo
+	routeMaster  = "master"  //a master node
+	routeSlave   = "slave"   //a slave node
+	routeScatter = "scatter" //more than one node
+)
+
+var (
+	_queries = metrics.NewCounterVec("igo_queries_total",
+		"The commands from the clients by command type and route.", "command", "route")
+	_queryDuration = metrics.NewHistogramVec("igo_query_duration_seconds",
+		"The latency of the commands from the clients.", metrics.DefBuckets, "command", "route")
+	_backendErrors = metrics.NewCounterVec("igo_backend_errors_total",
+		"The error packets from mysql by error number.", "backend", "code")
+	_clientBytes = metrics.NewCounterVec("igo_client_bytes_total",
+		"The bytes between the clients and igo, in is received from the clients.", "direction")
+	_poolWait = metrics.NewCounterVec("igo_pool_wait_seconds_total",
+		"The time waited to get a connection from the pool.", "backend")
+	_poolExhausted = metrics.NewCounterVec("igo_pool_exhausted_total",
+		"The times no connection can be get from the pool.", "backend")
+	_auditErrors = metrics.NewCounterVec("igo_audit_errors_total",
+		"The audit records failed to write.").With()
+	_queryTimeouts = metrics.NewCounterVec("igo_query_timeouts_total",
+		"The statements killed for running longer than maxExecTime.").With()
+	_resultLimits = metrics.NewCounterVec("igo_result_limits_total",
+		"The result sets stopped for exceeding maxResultRows or maxResultBytes.").With()
+	_firewallMatches = metrics.NewCounterVec("igo_firewall_matches_total",
+		"The statements matched the firewall rules.", "rule", "action")
+	_rateLimited = metrics.NewCounterVec("igo_rate_limited_total",
+		"The statements queued or rejected by the rate limits.", "limit", "result")
+	_clientsRejected = metrics.NewCounterVec("igo_clients_rejected_total",
+		"The client connections rejected before the auth by the reason.", "reason")
+
+	_bytesIn            = _clientBytes.With("in")
+	_bytesOut           = _clientBytes.With("out")
+	_hostRejected       = _clientsRejected.With("host")
+	_maxClientsRejected = _clientsRejected.With("max_clients")
+)
+
+func init() {
+	metrics.NewGaugeFunc("igo_pool_connections", "The connections of the pools by state: open, idle, in_use and max.",
+		[]string{"node", "role", "backend", "state"}, collectPools)
+}
+
+//collectPools emit the pool stats of the databases.
+func collectPools(emit func(float64, ...string)) {
+	for name, n := range getNodes() {
+		for _, db := range append([]*MysqlDB{n.master}, n.slaves...) {
+			open, idle, max := db.stats()
+			emit(float64(open), name, db.role, db.addr, "open")
+			emit(float64(idle), name, db.role, db.addr, "idle")
+			emit(float64(open-idle), name, db.role, db.addr, "in_use")
+			emit(float64(max), name, db.role, db.addr, "max")
+		}
+	}
+}
+
+//registerCounter expose the clients of the counter.
+func registerCounter(cnt Counter, max func() int64) {
+	metrics.NewGaugeFunc("igo_clients", "The connected clients and the max clients.", []string{"state"},
+		func(emit func(float64, ...string)) {
+			emit(float64(cnt.Size()), "connected")
+			emit(float64(max()), "max")
+		})
+}
+
+//observeCommand record the command and the latency.
+func observeCommand(cmd byte, route string, start time.Time) {
+	name := commandName(cmd)
+	if route == "" {
+		route = routeLocal
+	}
+	_queries.With(name, route).Inc()
+	_queryDuration.With(name, route).Observe(time.Since(start).Seconds())
+}
+
+//observeBackendError count the error packet of the backend.
+func observeBackendError(addr string, code uint16) {
+	_backendErrors.With(addr, strconv.Itoa(int(code))).Inc()
+}
+
+//routeOf the route of the databases the command executed on.
+func routeOf(dbs ...*MysqlDB) string {
+	if len(dbs) > 1 {
+		return routeScatter
+	}
+	if len(dbs) == 1 && dbs[0] != nil {
+		return dbs[0].role
+	}
+	return ""
+}
+
+var commandNames = map[byte]string{
+	mysql.ComQuit:             "quit",
+	mysql.ComInitDB:           "init_db",
+	mysql.ComQuery:            "query",
+	mysql.ComFieldList:        "field_list",
+	mysql.ComStmtPrepare:      "stmt_prepare",
+	mysql.ComStmtExecute:      "stmt_execute",
+	mysql.ComStmtClose:        "stmt_close",
+	mysql.ComStmtFetch:        "stmt_fetch",
+	mysql.ComStmtReset:        "stmt_reset",
+	mysql.ComStmtSendLongData: "stmt_send_long_data",
+	mysql.ComPing:             "ping",
+	mysql.ComProcessKill:      "process_kill",
+}
+
+func commandName(cmd byte) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+	return "other"
+}
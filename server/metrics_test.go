@@ -0,0 +1,44 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/metrics"
+	"igo/mysql"
+)
+
+func Test_Metrics(t *testing.T) {
+	conf := &config.Config{Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: "127.0.0.1:3306", Slaves: []string{"127.0.0.1:3316"}, MaxConnNum: 10}}
+	s := NewServer(conf)
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	s.count.Incr()
+
+	observeCommand(mysql.ComQuery, routeOf(GetDB("select 1")), time.Now())
+	observeCommand(mysql.ComQuery, routeOf(GetNode(defaultNode), GetNode(defaultNode)), time.Now())
+	observeCommand(mysql.ComPing, "", time.Now())
+	observeBackendError("127.0.0.1:3306", mysql.ErrNoSuchTable)
+
+	var buf bytes.Buffer
+	metrics.WriteTo(&buf)
+	for _, line := range []string{
+		`igo_clients{state="connected"} 1`,
+		`igo_clients{state="max"} 1024`,
+		`igo_pool_connections{node="default",role="slave",backend="127.0.0.1:3316",state="max"} 10`,
+		`igo_queries_total{command="query",route="slave"} 1`,
+		`igo_queries_total{command="query",route="scatter"} 1`,
+		`igo_queries_total{command="ping",route="local"} 1`,
+		`igo_query_duration_seconds_count{command="ping",route="local"} 1`,
+		`igo_backend_errors_total{backend="127.0.0.1:3306",code="1146"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("%s not found in:\n%s", line, buf.String())
+		}
+	}
+}
@@ -1,5 +1,13 @@
 package server
 
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 import (
 	"igo/config"
 	"igo/log"
@@ -13,23 +21,249 @@ var (
 	slaveNode  nodeType = 2
 )
 
+//defaultNode the node name of ServerConfig dbaddr.
+const defaultNode = "default"
+
+//node a master and its slaves.
+type node struct {
+	master *MysqlDB
+	slaves []*MysqlDB
+	next   uint32 //atomic, round robin of the slaves
+}
+
+//get the master or a slave, the master is used if there is no online slave.
+func (n *node) get(t nodeType) *MysqlDB {
+	if t != slaveNode || len(n.slaves) == 0 {
+		return n.master
+	}
+	start := int(atomic.AddUint32(&n.next, 1))
+	for i := range n.slaves {
+		if db := n.slaves[(start+i)%len(n.slaves)]; !db.isOffline() {
+			return db
+		}
+	}
+	return n.master
+}
+
+//pick choose the database of the node for the sql with the hint.
+//The read must see the gtid of the hint goes to the slave executed it, or the master.
+func (n *node) pick(h *hint, sql []byte) *MysqlDB {
+	if len(n.slaves) == 0 || h.nodeType(sql) != slaveNode {
+		return n.master
+	}
+	if h == nil || h.gtid == "" {
+		return n.get(slaveNode)
+	}
+
+	if _gtidWait > 0 {
+		if db := n.get(slaveNode); db != n.master && db.executed(h.gtid, _gtidWait) {
+			return db
+		}
+		return n.master
+	}
+	start := int(atomic.AddUint32(&n.next, 1))
+	for i := range n.slaves {
+		if db := n.slaves[(start+i)%len(n.slaves)]; !db.isOffline() && db.executed(h.gtid, 0) {
+			return db
+		}
+	}
+	return n.master
+}
+
+//the nodes and shard rules, they are replaced as a whole by the reload.
 var (
-	_defaultDB = make(map[nodeType]*MysqlDB)
+	_routeMu sync.RWMutex
+	_nodes   = make(map[string]*node)
+	_shards  = make(map[string]*shardRule)
 )
 
-//InitDB init the db connection
-func InitDB(conf *config.ServerConfig) {
+func getNodes() map[string]*node {
+	_routeMu.RLock()
+	defer _routeMu.RUnlock()
+	return _nodes
+}
+
+func getShards() map[string]*shardRule {
+	_routeMu.RLock()
+	defer _routeMu.RUnlock()
+	return _shards
+}
+
+//dbSet the opened databases by addr, the ones with the same config are reused by the reload.
+type dbSet map[string]*MysqlDB
+
+//open take the database of the config and role from the set, or open a new one.
+func (set dbSet) open(conf *config.ServerConfig, role string) (*MysqlDB, error) {
+	if db := set[conf.Addr]; db != nil && db.role == role && db.same(conf) {
+		delete(set, conf.Addr)
+		return db, nil
+	}
 	db, err := Open(conf)
 	if err != nil {
+		return nil, err
+	}
+	db.role = role
+	return db, nil
+}
+
+//openNode open the master and slaves of the node.
+func (set dbSet) openNode(conf *config.ServerConfig, slaves []string) (*node, error) {
+	db, err := set.open(conf, routeMaster)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{master: db}
+	for _, addr := range slaves {
+		sc := *conf
+		sc.Addr = addr
+		sc.Consistency = "" //the writes only go to the master
+		db, err := set.open(&sc, routeSlave)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		n.slaves = append(n.slaves, db)
+	}
+	return n, nil
+}
+
+//InitDB init the db connection
+func InitDB(conf *config.Config) {
+	if err := loadDB(conf); err != nil {
 		log.Error(err)
-		return
 	}
-	_defaultDB[masterNode] = db
 }
 
-//GetDB get the database
+//loadDB open the nodes and shard rules of the config and replace the current ones.
+//The databases with unchanged config are kept, the others are closed,
+//the connections in use are closed after the statements done.
+func loadDB(conf *config.Config) error {
+	shards := make(map[string]*shardRule)
+	for i := range conf.Shards {
+		r, err := newShardRule(&conf.Shards[i])
+		if err != nil {
+			return err
+		}
+		shards[r.table] = r
+	}
+
+	_routeMu.Lock()
+	defer _routeMu.Unlock()
+	old := make(dbSet)
+	for _, n := range _nodes {
+		old[n.master.addr] = n.master
+		for _, db := range n.slaves {
+			old[db.addr] = db
+		}
+	}
+
+	nodes := make(map[string]*node)
+	n, err := old.openNode(&conf.Server, conf.Server.Slaves)
+	if err != nil {
+		return err
+	}
+	nodes[defaultNode] = n
+
+	for _, nc := range conf.Nodes {
+		sc := conf.Server
+		sc.Addr = nc.Addr
+		n, err := old.openNode(&sc, nc.Slaves)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		nodes[nc.Name] = n
+	}
+
+	_nodes, _shards = nodes, shards
+	_consistency = conf.Server.Consistency
+	_gtidWait = time.Duration(conf.Server.GTIDWait) * time.Millisecond
+	for _, db := range old {
+		db.Close()
+	}
+	for _, r := range shards {
+		log.Infof("Shard table: %v, key: %v, rule: %v, nodes: %v", r.table, r.key, r.kind, r.nodes)
+	}
+	return nil
+}
+
+//GetDB get the database of the default node for the sql,
+//the read statement goes to the slave, the hint in the sql can override it.
 func GetDB(s string) *MysqlDB {
-	//TODO parse the s , and choose a node for
+	n := getNodes()[defaultNode]
+	if n == nil {
+		return nil
+	}
+	h, _ := parseHints([]byte(s), false)
+	if db := n.pick(h, []byte(s)); !db.isOffline() {
+		return db
+	}
+	return nil
+}
+
+//GetNode get the master database of the node name.
+func GetNode(name string) *MysqlDB {
+	if n := getNodes()[name]; n != nil && !n.master.isOffline() {
+		return n.master
+	}
+	return nil
+}
+
+//route choose the databases for the statement, more than one database means broadcast.
+//The cluster hint choose the node directly, the shard hint choose the node of the shard rule.
+func route(h *hint, sql []byte, plan *shardPlan, params []string) ([]*MysqlDB, error) {
+	nodes := getNodes()
+	if h != nil && h.cluster != "" {
+		n := nodes[h.cluster]
+		if n == nil {
+			return nil, fmt.Errorf("hint: cluster %q not found", h.cluster)
+		}
+		return online(n.pick(h, sql))
+	}
+
+	var names []string
+	switch {
+	case h != nil && h.shard >= 0:
+		if plan == nil {
+			return nil, fmt.Errorf("hint: shard=%d on the statement without sharded table", h.shard)
+		}
+		if h.shard >= len(plan.rule.nodes) {
+			return nil, fmt.Errorf("hint: shard=%d out of range, table %v has %d shards", h.shard, plan.rule.table, len(plan.rule.nodes))
+		}
+		names = []string{plan.rule.nodes[h.shard]}
 
-	return _defaultDB[masterNode]
+	case plan == nil:
+		n := nodes[defaultNode]
+		if n == nil {
+			return nil, errNotfoundDB
+		}
+		return online(n.pick(h, sql))
+
+	default:
+		var err error
+		if names, err = plan.nodes(params); err != nil {
+			return nil, err
+		}
+	}
+
+	dbs := make([]*MysqlDB, 0, len(names))
+	for _, name := range names {
+		n := nodes[name]
+		if n == nil {
+			return nil, fmt.Errorf("shard %v: node %q not found", plan.rule.table, name)
+		}
+		dbs = append(dbs, n.pick(h, sql))
+	}
+	log.Debugf("route %v to %v", plan.rule.table, strings.Join(names, ","))
+	return online(dbs...)
+}
+
+//online check none of the databases is offline.
+func online(dbs ...*MysqlDB) ([]*MysqlDB, error) {
+	for _, db := range dbs {
+		if db.isOffline() {
+			return nil, errBackendOffline(db)
+		}
+	}
+	return dbs, nil
 }
@@ -31,6 +31,12 @@ type mysqlConn struct {
 	sequence         uint8
 	strict           bool
 	createdAt        time.Time
+	threadID         uint32 //the connection id of the mysql thread, the target of KILL
+	broken           bool   //not reusable, closed when it is put back to the pool
+
+	trackGTID    bool   //ask the session tracker for the gtid of the writes
+	sessionTrack bool   //CLIENT_SESSION_TRACK negotiated
+	gtid         string //the gtid of the last write, reset by the reader
 }
 
 func (mc *mysqlConn) Close() {
@@ -65,6 +71,12 @@ func (mc *mysqlConn) Exec(data []byte) ([]byte, error) {
 
 //Exec execute the cmd,and return the read all the  packet.
 func (mc *mysqlConn) Query(data []byte) ([][]byte, error) {
+	return mc.QueryLimit(data, resultLimit{})
+}
+
+//QueryLimit like Query, but stop reading the rows over the limit and return errResultTooLarge,
+//the caller must stopResult the rest rows.
+func (mc *mysqlConn) QueryLimit(data []byte, lim resultLimit) ([][]byte, error) {
 	cmd := data[0]
 	arg := string(data[1:])
 	if err := mc.writeCommandPacketStr(cmd, arg); err != nil {
@@ -85,7 +97,7 @@ func (mc *mysqlConn) Query(data []byte) ([][]byte, error) {
 			return nil, err
 		}
 		// rows
-		if result, err = mc.readUntilEOF(result); err != nil {
+		if result, err = mc.readRows(result, lim); err != nil {
 			return nil, err
 		}
 	}
@@ -149,11 +161,25 @@ func (mc *mysqlConn) readResultSetPacket(res [][]byte, count int) ([][]byte, err
 
 // Reads Packets until EOF-Packet or an Error appears. Returns count of Packets read
 func (mc *mysqlConn) readUntilEOF(res [][]byte) ([][]byte, error) {
+	return mc.readRows(res, resultLimit{})
+}
+
+//readRows read the rows like readUntilEOF, but stop at the row over the limit and return errResultTooLarge.
+func (mc *mysqlConn) readRows(res [][]byte, lim resultLimit) ([][]byte, error) {
+	rows, size := 0, 0
 	for {
 		data, err := mc.readPacket()
 		res = append(res, data)
+		// the statement failed in the middle, such as killed, nothing follows the ERR Packet
+		if err == nil && data[0] == mysql.HeaderERR {
+			return res, mc.handleErrorPacket(data)
+		}
 		// No Err and no EOF Packet
 		if err == nil && data[0] != mysql.HeaderEOF {
+			rows, size = rows+1, size+len(data)
+			if lim.exceeded(rows, size) {
+				return res, errResultTooLarge
+			}
 			continue
 		}
 		if err == nil && data[0] == mysql.HeaderEOF && len(data) == 5 {
@@ -172,7 +198,8 @@ func (mc *mysqlConn) readResultSetHeaderPacket() ([]byte, int, error) {
 		switch data[0] {
 
 		case mysql.HeaderOK:
-			return data, 0, mc.handleOkPacket(data)
+			data, err = mc.handleOkPacket(data)
+			return data, 0, err
 
 		case mysql.HeaderERR:
 			return data, 0, mc.handleErrorPacket(data)
@@ -203,6 +230,9 @@ func (mc *mysqlConn) handleErrorPacket(data []byte) error {
 
 	// Error Number [16 bit uint]
 	errno := binary.LittleEndian.Uint16(data[1:3])
+	if mc.cfg != nil {
+		observeBackendError(mc.cfg.Addr, errno)
+	}
 
 	pos := 3
 
@@ -242,7 +272,9 @@ func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 
 	// server version [null terminated string]
 	// connection id [4 bytes]
-	pos := 1 + bytes.IndexByte(data[1:], 0x00) + 1 + 4
+	pos := 1 + bytes.IndexByte(data[1:], 0x00) + 1
+	mc.threadID = binary.LittleEndian.Uint32(data[pos : pos+4])
+	pos += 4
 
 	// first part of the password cipher [8 bytes]
 	cipher := data[pos : pos+8]
@@ -261,6 +293,11 @@ func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 	pos += 2
 
 	if len(data) > pos {
+		// capability flags (upper 2 bytes)
+		if len(data) >= pos+5 {
+			mc.flags |= mysql.ClientFlag(binary.LittleEndian.Uint16(data[pos+3:pos+5])) << 16
+		}
+
 		// character set [1 byte]
 		// status flags [2 bytes]
 		// capability flags (upper 2 bytes) [2 bytes]
@@ -316,6 +353,12 @@ func (mc *mysqlConn) writeAuthPacket(cipher []byte) error {
 		//mysql.ClientMultiResults |
 		mc.flags&mysql.ClientLongFlag
 
+	// session_track_gtids need the session state in the OK packet
+	if mc.trackGTID && mc.flags&mysql.ClientSessionTrack != 0 {
+		clientFlags |= mysql.ClientSessionTrack
+		mc.sessionTrack = true
+	}
+
 	// if mc.cfg.ClientFoundRows {
 	// 	clientFlags |= mysql.ClientFoundRows
 	// }
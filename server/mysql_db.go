@@ -7,6 +7,7 @@ import (
 	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,7 +19,7 @@ type MysqlDB struct {
 
 	addr   string
 	user   string
-	passwd string
+	passwd config.Password
 	db     string
 	state  mysql.StatusFlag
 
@@ -29,6 +30,10 @@ type MysqlDB struct {
 	maxIdle     int
 	maxOpen     int
 	numOpen     int
+	trackGTID   bool
+	closed      int32  //atomic
+	offline     int32  //atomic, set by the admin, no statement is routed to it
+	role        string //routeMaster or routeSlave
 }
 
 //Open open with config.
@@ -41,6 +46,7 @@ func Open(conf *config.ServerConfig) (*MysqlDB, error) {
 		maxIdle:     conf.MaxIdleConn,
 		maxOpen:     conf.MaxConnNum,
 		maxLifetime: time.Duration(conf.MaxLifeTime),
+		trackGTID:   conf.Consistency != "",
 		tryTick:     time.NewTicker(2 * time.Millisecond),
 	}
 	m.freeConn = make(chan *mysqlConn, m.maxOpen)
@@ -65,6 +71,7 @@ func (m *MysqlDB) newConn() (*mysqlConn, error) {
 		maxWriteSize:     mysql.MaxPacketSize - 1,
 		writeTimeout:     time.Duration(defaultWriteTimeout * time.Second),
 		createdAt:        nowFunc(),
+		trackGTID:        m.trackGTID,
 	}
 	mc.cfg = &config.ServerConfig{
 		Addr:   m.addr,
@@ -118,18 +125,63 @@ func (m *MysqlDB) newConn() (*mysqlConn, error) {
 		mc.Close()
 		return nil, err
 	}
+
+	if mc.sessionTrack {
+		if _, err := mc.Exec([]byte(string(mysql.ComQuery) + "SET SESSION session_track_gtids = OWN_GTID")); err != nil {
+			log.Error("track gtid:", err)
+		}
+	}
 	m.numOpen++
 	return mc, nil
 }
 
 func (m *MysqlDB) opener() {
 	for range m.openCh {
+		if m.isClosed() || m.isOffline() {
+			continue
+		}
 		conn, err := m.newConn()
-		if err == nil {
-			m.freeConn <- conn
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if m.isClosed() {
+			conn.Close()
 			continue
 		}
-		log.Error(err)
+		m.freeConn <- conn
+	}
+}
+
+//same the database is opened with the same config.
+func (m *MysqlDB) same(conf *config.ServerConfig) bool {
+	return m.addr == conf.Addr && m.user == conf.User && m.passwd == conf.Passwd && m.db == conf.DBName &&
+		m.maxIdle == conf.MaxIdleConn && m.maxOpen == conf.MaxConnNum &&
+		m.maxLifetime == time.Duration(conf.MaxLifeTime) && m.trackGTID == (conf.Consistency != "")
+}
+
+func (m *MysqlDB) isClosed() bool {
+	return atomic.LoadInt32(&m.closed) == 1
+}
+
+//Close close the idle connections, the connections in use are closed when put back.
+func (m *MysqlDB) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !atomic.CompareAndSwapInt32(&m.closed, 0, 1) {
+		return
+	}
+	close(m.openCh)
+	m.tryTick.Stop()
+	for {
+		select {
+		case mc := <-m.freeConn:
+			m.numOpen--
+			mc.Close()
+		default:
+			log.Infof("Close db: %v", m.addr)
+			return
+		}
 	}
 }
 
@@ -143,8 +195,13 @@ func (m *MysqlDB) maybeOpenNew() {
 }
 
 func (m *MysqlDB) getConn() *mysqlConn {
+	start := time.Now()
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	defer func() { _poolWait.With(m.addr).Add(time.Since(start).Seconds()) }()
+	if m.isClosed() {
+		return nil
+	}
 	m.maybeOpenNew()
 	try := 10
 	for try > 0 {
@@ -158,15 +215,29 @@ func (m *MysqlDB) getConn() *mysqlConn {
 			continue
 		default:
 			log.Error("getConn case default, freeConn is empty and maxOpen at max.\n", stack())
+			_poolExhausted.With(m.addr).Inc()
 			return nil
 		}
 	}
+	_poolExhausted.With(m.addr).Inc()
 	return nil
 }
 
+//stats the open and idle connections and the max open of the pool.
+func (m *MysqlDB) stats() (open, idle, max int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.numOpen, len(m.freeConn), m.maxOpen
+}
+
 func (m *MysqlDB) putConn(mc *mysqlConn) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.isClosed() || m.isOffline() || mc.broken {
+		m.numOpen--
+		mc.Close()
+		return nil
+	}
 	if m.maxLifetime > 0 {
 		if m.maxIdle < m.numOpen && mc.expired(m.maxLifetime) {
 			m.numOpen--
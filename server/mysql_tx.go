@@ -71,8 +71,13 @@ type mysqlField struct {
 type mysqlStmt struct {
 	id         uint32
 	mc         *mysqlConn
+	db         *MysqlDB
 	paramCount int
 	columns    []mysqlField
+	typesSent  bool       //param types has been sent to server
+	shard      *shardStmt //not nil if the statement is routed by the args
+	query      string
+	digest     string //the fingerprint of the query
 }
 
 var _ Stmt = &mysqlStmt{}
@@ -105,12 +110,17 @@ func (ms *mysqlStmt) Exec(data []byte) ([]byte, error) {
 }
 
 func (ms *mysqlStmt) Query(data []byte) ([][]byte, error) {
+	return ms.QueryLimit(data, resultLimit{})
+}
+
+//QueryLimit like Query, but stop reading the rows over the limit, see mysqlConn.QueryLimit.
+func (ms *mysqlStmt) QueryLimit(data []byte, lim resultLimit) ([][]byte, error) {
 	mc := ms.mc
 	if mc.netConn == nil {
 		return nil, mysql.ErrBadConn
 	}
 	// Send command
-	result, err := mc.Query(data)
+	result, err := mc.QueryLimit(data, lim)
 	if err != nil {
 		return nil, err
 	}
@@ -13,6 +13,8 @@ import (
 	"encoding/binary"
 	"igo/log"
 	"igo/mysql"
+	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -66,6 +68,8 @@ func (c *Client) readPacket() ([]byte, error) {
 			c.close()
 			return nil, err
 		}
+		_bytesIn.Add(float64(4 + pktLen))
+		atomic.AddUint64(&c.session.bytesIn, uint64(4+pktLen))
 
 		isLastPacket := (pktLen < mysql.MaxPacketSize)
 
@@ -113,6 +117,9 @@ func (c *Client) writePacket(data []byte) error {
 		}
 		//log.Debug("client write packet:", data)
 		n, err := c.netConn.Write(data[:4+size])
+		_bytesOut.Add(float64(n))
+		c.sent.bytes += uint64(n)
+		atomic.AddUint64(&c.session.bytesOut, uint64(n))
 		if err == nil && n == 4+size {
 			c.sequence++
 			if size != mysql.MaxPacketSize {
@@ -133,8 +140,25 @@ func (c *Client) writePacket(data []byte) error {
 	}
 }
 
+//writeConnError write the ERR packet to the conn rejected before the handshake,
+//without a Client the packet is the first one and has no sql state.
+func writeConnError(conn net.Conn, e *mysql.SQLError) error {
+	data := make([]byte, 4, 7+len(e.Message))
+	data = append(data, mysql.HeaderERR, byte(e.Code), byte(e.Code>>8))
+	data = append(data, e.Message...)
+	pktLen := len(data) - 4
+	data[0], data[1], data[2], data[3] = byte(pktLen), byte(pktLen>>8), byte(pktLen>>16), 0
+	if err := conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout * time.Second)); err != nil {
+		return err
+	}
+	n, err := conn.Write(data)
+	_bytesOut.Add(float64(n))
+	return err
+}
+
 func (c *Client) writeResultPackets(payloads [][]byte) error {
 	var err error
+	c.sent.addResult(payloads)
 
 	for _, payload := range payloads {
 		pktLen := len(payload)
@@ -153,6 +177,15 @@ func (c *Client) writeResultPackets(payloads [][]byte) error {
 	return err
 }
 
+//writeResultSet write the result set built by igo in the text protocol.
+func (c *Client) writeResultSet(rs *mysql.ResultSet) error {
+	payloads, err := rs.TextPackets()
+	if err != nil {
+		return c.writeError(mysql.NewErrf(mysql.ErrUnknown, "%v", err))
+	}
+	return c.writeResultPackets(payloads)
+}
+
 // Read packet to buffer 'data'
 func (mc *mysqlConn) readPacket() ([]byte, error) {
 	var payload []byte
@@ -194,9 +227,10 @@ func (mc *mysqlConn) readPacket() ([]byte, error) {
 
 		isLastPacket := (pktLen < mysql.MaxPacketSize)
 		//log.Debug("mysqlConn read package: ", data, pktLen, mc.sequence)
-		// Zero allocations for non-splitting packets
+		// The result packets are kept after the conn put back to pool,
+		// so copy the packet out of the buffer.
 		if isLastPacket && payload == nil {
-			return data, nil
+			return append(make([]byte, 0, len(data)), data...), nil
 		}
 
 		payload = append(payload, data...)
@@ -260,7 +294,9 @@ func (mc *mysqlConn) writePacket(data []byte) error {
 
 // Ok Packet
 // http://dev.mysql.com/doc/internals/en/generic-response-packets.html#packet-OK_Packet
-func (mc *mysqlConn) handleOkPacket(data []byte) error {
+// The session state of the tracked connection is read and removed,
+// the client never negotiate CLIENT_SESSION_TRACK with the proxy.
+func (mc *mysqlConn) handleOkPacket(data []byte) ([]byte, error) {
 	var n, m int
 
 	// 0x00 [1 byte]
@@ -277,17 +313,39 @@ func (mc *mysqlConn) handleOkPacket(data []byte) error {
 	// 	return err
 	// }
 
+	pos := 1 + n + m + 2
+	// the info and the session state are omitted if both are empty
+	if mc.sessionTrack && len(data) > pos+2 {
+		// info [length encoded string]
+		info, _, k, err := readLengthEncodedString(data[pos+2:])
+		if err != nil {
+			return nil, err
+		}
+		// session state info [length encoded string]
+		if mc.status&mysql.StatusSessionStateChanged != 0 && pos+2+k < len(data) {
+			state, _, _, err := readLengthEncodedString(data[pos+2+k:])
+			if err != nil {
+				return nil, err
+			}
+			mc.gtid = trackedGTID(state)
+		}
+		status := mc.status &^ mysql.StatusSessionStateChanged
+		ok := make([]byte, 0, pos+2+len(info))
+		ok = append(ok, data[:1+n+m]...)
+		ok = append(ok, byte(status), byte(status>>8), data[pos], data[pos+1])
+		data = append(ok, info...)
+	}
+
 	// warning count [2 bytes]
 	if !mc.strict {
-		return nil
+		return data, nil
 	}
 
-	pos := 1 + n + m + 2
 	if binary.LittleEndian.Uint16(data[pos:pos+2]) > 0 {
 		log.Warn("mc.getWarnings()")
-		return nil
+		return data, nil
 	}
-	return nil
+	return data, nil
 }
 
 /******************************************************************************
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+import (
+	"igo/log"
+	"igo/mysql"
+)
+
+//the thread statements answered by igo, the ids are the connect ids of igo, not of the backends.
+var (
+	processlistStmt = regexp.MustCompile(`(?i)^\s*show\s+(full\s+)?processlist\s*;?\s*$`)
+	killStmt        = regexp.MustCompile(`(?i)^\s*kill\s+(query\s+|connection\s+)?(\d+)\s*;?\s*$`)
+)
+
+//maxProcessInfo the Info of SHOW PROCESSLIST is truncated to it without FULL.
+const maxProcessInfo = 100
+
+//handleProcessStmt answer SHOW PROCESSLIST and KILL, handled is false for the other statements.
+func (c *Client) handleProcessStmt(query []byte) (handled bool, err error) {
+	if len(query) > 64 {
+		return false, nil
+	}
+	if m := processlistStmt.FindSubmatch(query); m != nil {
+		return true, c.showProcesslist(len(m[1]) > 0)
+	}
+	if m := killStmt.FindSubmatch(query); m != nil {
+		id, err := strconv.ParseUint(string(m[2]), 10, 32)
+		if err != nil {
+			return true, c.writeError(mysql.NewErrf(mysql.ErrNoSuchThread, "Unknown thread id: %s", m[2]))
+		}
+		return true, c.killSession(uint32(id), len(m[1]) > 0 && (m[1][0] == 'q' || m[1][0] == 'Q'))
+	}
+	return false, nil
+}
+
+//handleProcessKill COM_PROCESS_KILL, the same as KILL CONNECTION.
+func (c *Client) handleProcessKill(data []byte) error {
+	if len(data) < 5 {
+		return c.writeError(mysql.ErrMalformPkt)
+	}
+	return c.killSession(binary.LittleEndian.Uint32(data[1:5]), false)
+}
+
+//showProcesslist list the sessions of the user, like mysql without the PROCESS privilege.
+func (c *Client) showProcesslist(full bool) error {
+	rs := mysql.NewResultSet(
+		mysql.Column{Name: "Id", Type: mysql.FieldTypeLongLong, Flags: mysql.FlagNotNULL | mysql.FlagUnsigned},
+		mysql.Column{Name: "User", Type: mysql.FieldTypeVarString, Flags: mysql.FlagNotNULL},
+		mysql.Column{Name: "Host", Type: mysql.FieldTypeVarString, Flags: mysql.FlagNotNULL},
+		mysql.Column{Name: "db", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "Command", Type: mysql.FieldTypeVarString, Flags: mysql.FlagNotNULL},
+		mysql.Column{Name: "Time", Type: mysql.FieldTypeLong, Flags: mysql.FlagNotNULL},
+		mysql.Column{Name: "State", Type: mysql.FieldTypeVarString},
+		mysql.Column{Name: "Info", Type: mysql.FieldTypeVarString},
+	)
+	for _, se := range Sessions() {
+		if se.User != c.user {
+			continue
+		}
+		info := se.Info
+		if !full && len(info) > maxProcessInfo {
+			info = info[:maxProcessInfo]
+		}
+		var state interface{}
+		if se.Command != processCommands[0] {
+			state = "executing"
+		}
+		if err := rs.AddRow(se.ID, se.User, se.Addr, nullString(se.Schema), se.Command, se.Time(), state, nullString(info)); err != nil {
+			return c.writeError(err)
+		}
+	}
+	return c.writeResultSet(rs)
+}
+
+//killSession kill the statements the session runs on the backends, and close the session unless query.
+//Only the sessions of the same user can be killed.
+func (c *Client) killSession(id uint32, query bool) error {
+	target := getClient(id)
+	if target == nil {
+		return c.writeError(mysql.NewErrf(mysql.ErrNoSuchThread, "Unknown thread id: %v", id))
+	}
+	if target.user != c.user {
+		return c.writeError(mysql.NewErrf(mysql.ErrKillDenied, "You are not owner of thread %v", id))
+	}
+	if err := target.killQueries(); err != nil {
+		log.Errorf("kill %v by %v: %v", id, c.connectID, err)
+		return c.writeError(mysql.NewErrf(mysql.ErrUnknown, "kill %v: %v", id, err))
+	}
+	if !query {
+		log.Warnf("Client %v killed by %v", id, c.connectID)
+		target.kill()
+	}
+	return c.writeOK()
+}
+
+//killQueries kill the statements the client is running on the backends.
+func (c *Client) killQueries() error {
+	s := &c.session
+	s.mu.Lock()
+	conns := append([]*mysqlConn(nil), s.conns...)
+	s.mu.Unlock()
+	var err error
+	for _, mc := range conns {
+		if e := mc.killQuery(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//killQuery run KILL QUERY of the thread on a new connection to the backend, the connection is kept usable.
+func (mc *mysqlConn) killQuery() error {
+	kc, err := (&MysqlDB{addr: mc.cfg.Addr, user: mc.cfg.User, passwd: mc.cfg.Passwd}).newConn()
+	if err != nil {
+		return err
+	}
+	defer kc.Close()
+	_, err = kc.Exec([]byte(fmt.Sprintf("%cKILL QUERY %d", mysql.ComQuery, mc.threadID)))
+	return err
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+//testBackend a fake mysql accepting any user, the queries it received are sent to the channel.
+//"select sleep" blocks until KILL QUERY of the thread and fails with ER_QUERY_INTERRUPTED,
+//"select broken" fails after the first row, "select rows n" returns n rows, the others are answered with OK.
+//The execute of the statement 1 runs until killed, the others return 30 rows,
+//then the connection is lost in the middle of the result.
+func testBackend(t *testing.T) (string, chan string, func()) {
+	ls, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := make(chan string, 16)
+	var mu sync.Mutex
+	killers := make(map[uint32]chan struct{})
+	interrupted := mysql.NewErr(mysql.ErrQueryInterrupted)
+	go func() {
+		for {
+			conn, err := ls.AcceptTCP()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				c, _ := newClient(conn, &config.ServerConfig{})
+				if c.Handshake() != nil {
+					return
+				}
+				killed := make(chan struct{}, 1)
+				mu.Lock()
+				killers[c.connectID] = killed
+				mu.Unlock()
+				for {
+					data, err := c.readPacket()
+					if err != nil || data[0] == mysql.ComQuit {
+						return
+					}
+					if data[0] == mysql.ComStmtExecute {
+						res := [][]byte{{1}, testColumn("a", mysql.FieldTypeVarString), {mysql.HeaderEOF, 0, 0, 2, 0}}
+						if binary.LittleEndian.Uint32(data[1:]) == 1 {
+							<-killed
+						}
+						for i := 0; i < 30; i++ {
+							res = append(res, []byte{0, 0, 3, 'r', 'o', 'w'})
+						}
+						c.writeResultPackets(res)
+						return
+					}
+					query := string(data[1:])
+					if data[0] == mysql.ComQuery {
+						queries <- query
+					}
+					var id uint32
+					switch {
+					case strings.HasPrefix(query, "select sleep"):
+						<-killed
+						c.writeError(interrupted)
+					case query == "select broken":
+						code := uint16(mysql.ErrQueryInterrupted)
+						errPkt := append([]byte{mysql.HeaderERR, byte(code), byte(code >> 8), '#'}, "70100interrupted"...)
+						c.writeResultPackets([][]byte{{1}, testColumn("a", mysql.FieldTypeVarString),
+							{mysql.HeaderEOF, 0, 0, 2, 0}, {1, 'x'}, errPkt})
+					case strings.HasPrefix(query, "select rows"):
+						var n int
+						fmt.Sscanf(query, "select rows %d", &n)
+						res := [][]byte{{1}, testColumn("a", mysql.FieldTypeVarString), {mysql.HeaderEOF, 0, 0, 2, 0}}
+						for i := 0; i < n; i++ {
+							res = append(res, []byte{3, 'r', 'o', 'w'})
+						}
+						c.writeResultPackets(append(res, []byte{mysql.HeaderEOF, 0, 0, 2, 0}))
+					default:
+						if n, _ := fmt.Sscanf(query, "KILL QUERY %d", &id); n == 1 {
+							mu.Lock()
+							if k := killers[id]; k != nil {
+								k <- struct{}{}
+							}
+							mu.Unlock()
+						}
+						c.writeOK()
+					}
+					c.sequence = 0
+				}
+			}()
+		}
+	}()
+	return ls.Addr().String(), queries, func() { ls.Close() }
+}
+
+func Test_Process(t *testing.T) {
+	addr, queries, stop := testBackend(t)
+	defer stop()
+
+	var cs []*Client
+	for _, user := range []string{"app", "app", "other"} {
+		c, done := testClient(t, &config.ServerConfig{})
+		defer done()
+		c.user = user
+		c.endCommand()
+		addClient(c)
+		defer removeClient(c)
+		cs = append(cs, c)
+	}
+	running, c, other := cs[0], cs[1], cs[2]
+	running.beginCommand(append([]byte{mysql.ComQuery}, "select sleep(100)"...))
+	running.pin(&mysqlConn{cfg: &config.ServerConfig{Addr: addr}, threadID: 42})
+
+	exec := func(c *Client, sql string) {
+		c.sent = cmdStat{}
+		if handled, err := c.handleProcessStmt([]byte(sql)); !handled || err != nil {
+			t.Fatal(sql, handled, err)
+		}
+	}
+	if handled, _ := c.handleProcessStmt([]byte("select 1")); handled {
+		t.Fatal("select is not a process statement")
+	}
+
+	exec(c, "show full processlist")
+	if c.sent.errCode != 0 || c.sent.rows != 2 {
+		t.Fatalf("%+v", c.sent)
+	}
+	exec(c, "KILL QUERY 1")
+	if c.sent.errCode != mysql.ErrNoSuchThread {
+		t.Fatalf("%+v", c.sent)
+	}
+	exec(other, "kill query "+fmt.Sprint(running.connectID))
+	if other.sent.errCode != mysql.ErrKillDenied {
+		t.Fatalf("%+v", other.sent)
+	}
+
+	exec(c, "kill query "+fmt.Sprint(running.connectID)+";")
+	if c.sent.errCode != 0 || <-queries != "KILL QUERY 42" {
+		t.Fatalf("%+v", c.sent)
+	}
+	if running.writeOK() != nil {
+		t.Fatal("kill query should keep the client")
+	}
+
+	c.sent = cmdStat{}
+	if err := c.handleProcessKill([]byte{mysql.ComProcessKill, byte(running.connectID), byte(running.connectID >> 8), 0, 0}); err != nil || c.sent.errCode != 0 {
+		t.Fatal(err, c.sent)
+	}
+	if <-queries != "KILL QUERY 42" || running.writeOK() == nil {
+		t.Fatal("kill should close the client")
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+import (
+	"igo/config"
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+//maxLimitStates the idle states are removed when a limit has more keys than it.
+const maxLimitStates = 10000
+
+//rateLimit the compiled rate limit, the empty conditions match all.
+type rateLimit struct {
+	config.RateLimit
+	users   map[string]bool
+	nets    []*net.IPNet
+	digests map[string]bool //the digest ids and the fingerprints
+	wait    time.Duration
+
+	mu     sync.Mutex
+	states map[string]*limitState //by the user, source ip or digest of Per
+}
+
+//limitState the token bucket and the running statements of a key.
+type limitState struct {
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	running chan struct{} //nil if no maxConcurrent
+}
+
+//the rate limits, replaced by installRateLimits.
+var (
+	_rateMu     sync.RWMutex
+	_rateLimits []*rateLimit
+)
+
+func newRateLimit(lc *config.RateLimit) (*rateLimit, error) {
+	l := &rateLimit{
+		RateLimit: *lc,
+		users:     stringSet(lc.Users),
+		digests:   stringSet(lc.Digests),
+		wait:      time.Duration(lc.Wait) * time.Millisecond,
+		states:    make(map[string]*limitState),
+	}
+	if l.Burst <= 0 {
+		l.Burst = int(math.Max(math.Ceil(l.QPS), 1))
+	}
+	var err error
+	if l.nets, err = ipNets(lc.Sources); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+//newRateLimits compile the rate limits of the config.
+func newRateLimits(conf *config.Config) ([]*rateLimit, error) {
+	limits := make([]*rateLimit, 0, len(conf.Limits))
+	for i := range conf.Limits {
+		l, err := newRateLimit(&conf.Limits[i])
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %q: %v", conf.Limits[i].Name, err)
+		}
+		limits = append(limits, l)
+	}
+	return limits, nil
+}
+
+//installRateLimits replace the rate limits in use, the states of the old limits are dropped.
+func installRateLimits(limits []*rateLimit) {
+	_rateMu.Lock()
+	_rateLimits = limits
+	_rateMu.Unlock()
+}
+
+//setRateLimits compile the rate limits of the config and install them.
+func setRateLimits(conf *config.Config) error {
+	limits, err := newRateLimits(conf)
+	if err != nil {
+		return err
+	}
+	installRateLimits(limits)
+	return nil
+}
+
+func (l *rateLimit) match(user string, ip net.IP, digest string) bool {
+	if l.users != nil && !l.users[user] {
+		return false
+	}
+	if len(l.nets) > 0 && !containsIP(l.nets, ip) {
+		return false
+	}
+	if l.digests != nil && !l.digests[digest] && !l.digests[parser.DigestID(digest)] {
+		return false
+	}
+	return true
+}
+
+func (l *rateLimit) key(user string, ip net.IP, digest string) string {
+	switch l.Per {
+	case "user":
+		return user
+	case "source":
+		return ip.String()
+	case "digest":
+		return digest
+	}
+	return ""
+}
+
+//state the state of the key, a new one has the full bucket.
+func (l *rateLimit) state(key string, now time.Time) *limitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.states[key]
+	if st != nil {
+		return st
+	}
+	if len(l.states) >= maxLimitStates {
+		for k, s := range l.states {
+			if s.idle(l, now) {
+				delete(l.states, k)
+			}
+		}
+	}
+	st = &limitState{tokens: float64(l.Burst), last: now}
+	if l.MaxConcurrent > 0 {
+		st.running = make(chan struct{}, l.MaxConcurrent)
+	}
+	l.states[key] = st
+	return st
+}
+
+//reserve take a token from the bucket, returns how long to wait for it,
+//nothing is taken and ok is false if it is longer than max.
+func (st *limitState) reserve(l *rateLimit, now time.Time, max time.Duration) (wait time.Duration, ok bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.refill(l, now)
+	if st.tokens < 1 {
+		wait = time.Duration((1 - st.tokens) / l.QPS * float64(time.Second))
+		if wait > max {
+			return wait, false
+		}
+	}
+	st.tokens--
+	return wait, true
+}
+
+//refund give back the token taken by reserve.
+func (st *limitState) refund(l *rateLimit) {
+	st.mu.Lock()
+	st.tokens = math.Min(float64(l.Burst), st.tokens+1)
+	st.mu.Unlock()
+}
+
+func (st *limitState) refill(l *rateLimit, now time.Time) {
+	if now.After(st.last) {
+		st.tokens = math.Min(float64(l.Burst), st.tokens+now.Sub(st.last).Seconds()*l.QPS)
+		st.last = now
+	}
+}
+
+//idle no statement is running and the bucket is full.
+func (st *limitState) idle(l *rateLimit, now time.Time) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.refill(l, now)
+	return len(st.running) == 0 && (l.QPS == 0 || st.tokens >= float64(l.Burst))
+}
+
+//acquire wait the token and the running slot of the key up to the wait of the limit,
+//exceeded is the resource over the limit if the statement is rejected,
+//the token is given back if no slot is available.
+func (l *rateLimit) acquire(key string) (release func(), exceeded string) {
+	now := time.Now()
+	st := l.state(key, now)
+	deadline := now.Add(l.wait)
+	if l.QPS > 0 {
+		wait, ok := st.reserve(l, now, l.wait)
+		if !ok {
+			return nil, "qps"
+		}
+		if wait > 0 {
+			_rateLimited.With(l.Name, "queued").Inc()
+			time.Sleep(wait)
+		}
+	}
+	if st.running == nil {
+		return func() {}, ""
+	}
+	busy := func() (func(), string) {
+		if l.QPS > 0 {
+			st.refund(l)
+		}
+		return nil, "max_concurrent"
+	}
+	select {
+	case st.running <- struct{}{}:
+	default:
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return busy()
+		}
+		_rateLimited.With(l.Name, "queued").Inc()
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case st.running <- struct{}{}:
+		case <-timer.C:
+			return busy()
+		}
+	}
+	return func() { <-st.running }, ""
+}
+
+//admit wait until all the rate limits matched admit the statement, release must be called after it is done,
+//the statement rejected returns ER_USER_LIMIT_REACHED.
+func (c *Client) admit(digest string) (release func(), err error) {
+	_rateMu.RLock()
+	limits := _rateLimits
+	_rateMu.RUnlock()
+	var releases []func()
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	if len(limits) == 0 {
+		return release, nil
+	}
+	ip := c.ip()
+	for _, l := range limits {
+		if !l.match(c.user, ip, digest) {
+			continue
+		}
+		r, exceeded := l.acquire(l.key(c.user, ip, digest))
+		if exceeded != "" {
+			release()
+			_rateLimited.With(l.Name, "rejected").Inc()
+			return nil, mysql.NewErrf(mysql.ErrUserLimitReached,
+				"User '%v' has exceeded the '%v' resource of the rate limit '%v'", c.user, exceeded, l.Name)
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/mysql"
+	"igo/mysql/parser"
+)
+
+func Test_RateLimit(t *testing.T) {
+	hot := parser.Fingerprint([]byte("select * from t where id = 1"))
+	conf := &config.Config{Limits: []config.RateLimit{
+		{Name: "qps", Users: []string{"app", "app2"}, Per: "user", QPS: 1, Burst: 2},
+		{Name: "queue", Users: []string{"queue"}, QPS: 50, Wait: 1000},
+		{Name: "hot", Digests: []string{parser.DigestID(hot)}, MaxConcurrent: 1, Wait: 50},
+		{Name: "remote", Sources: []string{"10.0.0.0/8"}, QPS: 1, Burst: 1},
+		{Name: "both", Users: []string{"both"}, QPS: 1, Burst: 2, MaxConcurrent: 1},
+	}}
+	if err := setRateLimits(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer setRateLimits(&config.Config{})
+
+	c, done := testClient(t, &config.ServerConfig{})
+	defer done()
+	admit := func(user, digest string) (func(), error) {
+		c.user = user
+		return c.admit(digest)
+	}
+	rejected := func(err error) bool {
+		e, ok := err.(*mysql.SQLError)
+		return ok && e.Code == mysql.ErrUserLimitReached
+	}
+
+	//the burst, then rejected, the other user has its own bucket
+	for i := 0; i < 2; i++ {
+		if release, err := admit("app", "select ?"); err != nil {
+			t.Fatal(i, err)
+		} else {
+			release()
+		}
+	}
+	if _, err := admit("app", "select ?"); !rejected(err) {
+		t.Fatal(err)
+	}
+	if _, err := admit("app2", "select ?"); err != nil {
+		t.Fatal(err)
+	}
+
+	//queued until the token is refilled
+	start := time.Now()
+	for i := 0; i < 52; i++ {
+		if _, err := admit("queue", "select ?"); err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Fatal("not queued", d)
+	}
+
+	//one running statement of the digest
+	release, err := admit("other", hot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admit("other", hot); !rejected(err) {
+		t.Fatal(err)
+	}
+	if r, err := admit("other", "select ?"); err != nil {
+		t.Fatal(err)
+	} else {
+		r()
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	if r, err := admit("other", hot); err != nil {
+		t.Fatal("should wait the running one", err)
+	} else {
+		r()
+	}
+
+	//the statement rejected by maxConcurrent gives back the token
+	release, err = admit("both", "select ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := admit("both", "select ?"); !rejected(err) {
+			t.Fatal(err)
+		}
+	}
+	release()
+	if r, err := admit("both", "select ?"); err != nil {
+		t.Fatal("the token should be left", err)
+	} else {
+		r()
+	}
+
+	//the remote limit does not match 127.0.0.1
+	for i := 0; i < 3; i++ {
+		if _, err := admit("other", "select ?"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_Reload(t *testing.T) {
+	conf := &config.Config{
+		Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: "127.0.0.1:3306", MaxClient: 10, MaxConnNum: 10},
+		Nodes:  []config.NodeConfig{{Name: "n1", Addr: "127.0.0.1:3307"}, {Name: "n2", Addr: "127.0.0.1:3308"}},
+	}
+	s := NewServer(conf)
+	defer func() {
+		_nodes = make(map[string]*node)
+		_shards = make(map[string]*shardRule)
+		setUsers(nil)
+	}()
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	master, n1, n2 := GetNode(defaultNode), GetNode("n1"), GetNode("n2")
+
+	next := &config.Config{
+		Server: conf.Server,
+		Nodes:  []config.NodeConfig{{Name: "n1", Addr: "127.0.0.1:3317"}},
+		Shards: []config.ShardConfig{{Table: "user", Key: "uid", Rule: "hash", Nodes: []string{"default", "n1"}}},
+		Users:  []config.UserConfig{{Name: "app", Passwd: "secret"}},
+	}
+	next.Server.MaxClient = 20
+	if err := s.Reload(next); err != nil {
+		t.Fatal(err)
+	}
+	if GetNode(defaultNode) != master {
+		t.Fatal("unchanged db should be kept")
+	}
+	if db := GetNode("n1"); db == n1 || db.addr != "127.0.0.1:3317" {
+		t.Fatal("changed db should be reopened")
+	}
+	if GetNode("n2") != nil || !n1.isClosed() || !n2.isClosed() {
+		t.Fatal("removed db should be closed")
+	}
+	if planShard([]byte("select * from user where uid = 1")) == nil {
+		t.Fatal("shard rule not loaded")
+	}
+	if s.count.(*ChanCount).max != 20 {
+		t.Fatal("max client not changed")
+	}
+	if checkAuth("root", nil, nil) || !checkAuth("app", []byte("12345678901234567890"), mysql.ScramblePassword([]byte("12345678901234567890"), []byte("secret"))) {
+		t.Fatal("users not changed")
+	}
+
+	bad := &config.Config{Server: next.Server, Shards: []config.ShardConfig{{Table: "t", Key: "id", Rule: "hash", Nodes: []string{"none"}}}}
+	if err := s.Reload(bad); err == nil {
+		t.Fatal("expect invalid config rejected")
+	}
+	if s.config() != next || GetNode("n1") == nil {
+		t.Fatal("last good config should be kept")
+	}
+
+	//the firewall and the rate limits compiled are not installed if the reload fails
+	dir, err := ioutil.TempDir("", "igo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bad = &config.Config{Server: next.Server, Rules: []config.FirewallRule{{Name: "all", Action: "deny"}},
+		Limits: []config.RateLimit{{Name: "all", QPS: 1}}}
+	bad.Server.FirewallAllowlist = dir
+	if err := s.Reload(bad); err == nil {
+		t.Fatal("expect the allowlist error")
+	}
+	if len(_rules) != 0 || len(_rateLimits) != 0 || s.config() != next {
+		t.Fatal("the firewall and the rate limits should not be changed")
+	}
+}
+
+type stubConfiger struct{ conf *config.Config }
+
+func (s *stubConfiger) Parse() (*config.Config, error) { return s.conf, nil }
+
+func Test_ReloadConfiger(t *testing.T) {
+	conf := &config.Config{Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: "127.0.0.1:3306", MaxConnNum: 10}}
+	s := NewServer(conf)
+	defer func() { _nodes = make(map[string]*node) }()
+	if err := s.reload(); err == nil {
+		t.Fatal("expect error without config source")
+	}
+
+	next := &config.Config{Server: conf.Server}
+	next.Server.MaxClient = 5
+	s.SetConfiger(&stubConfiger{next})
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.config() != next || s.count.(*ChanCount).max != 5 {
+		t.Fatal("config not reloaded")
+	}
+}
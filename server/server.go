@@ -6,6 +6,7 @@ import (
 
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -13,6 +14,7 @@ import (
 import (
 	"igo/config"
 	"igo/log"
+	"igo/mysql"
 	"igo/utils"
 )
 
@@ -29,7 +31,9 @@ type Serverer interface {
 
 //Server the server.
 type Server struct {
+	mu    sync.Mutex
 	cfg   *config.Config
+	src   config.Configer //re-read on SIGHUP
 	count Counter
 }
 
@@ -42,6 +46,12 @@ func NewServer(conf *config.Config) *Server {
 	cnt := new(ChanCount)
 	cnt.SetMax(conf.Server.MaxClient)
 	s.count = cnt
+	registerCounter(cnt, func() int64 {
+		if m := s.config().Server.MaxClient; m > 0 {
+			return m
+		}
+		return _defaultMax
+	})
 
 	return s
 }
@@ -49,12 +59,12 @@ func NewServer(conf *config.Config) *Server {
 //Run  run the server
 func (s *Server) Run() error {
 
-	s.startup(&s.cfg.Server)
+	cfg := s.startup()
 
-	if s.cfg.Server.Listen == "" {
+	if cfg.Server.Listen == "" {
 		return fmt.Errorf("addr is not set")
 	}
-	addr, err := net.ResolveTCPAddr("tcp", s.cfg.Server.Listen)
+	addr, err := net.ResolveTCPAddr("tcp", cfg.Server.Listen)
 	if err != nil {
 		return err
 	}
@@ -62,7 +72,12 @@ func (s *Server) Run() error {
 	if err != nil {
 		return err
 	}
-	log.Alertf("Server Running on addr: %v, max client: %v", addr.String(), s.cfg.Server.MaxClient)
+	log.Alertf("Server Running on addr: %v, max client: %v", addr.String(), cfg.Server.MaxClient)
+	if cfg.Server.AdminListen != "" {
+		if _, err := s.runAdmin(cfg.Server.AdminListen); err != nil {
+			return err
+		}
+	}
 
 	s.signal()
 
@@ -72,16 +87,26 @@ func (s *Server) Run() error {
 			log.Error(err)
 			continue
 		}
-		if ok := s.count.Incr(); !ok { //at max client limit or not
-			conn.Close()
-			continue
-		}
-
-		go s.handleClient(conn)
+		go s.admit(conn)
 	}
 
 }
 
+//admit wait the clients under maxClient up to maxClientWait, the client over it gets ER_CON_COUNT_ERROR.
+func (s *Server) admit(conn *net.TCPConn) {
+	conf := &s.config().Server
+	if !s.count.IncrWait(time.Duration(conf.MaxClientWait) * time.Millisecond) {
+		_maxClientsRejected.Inc()
+		if err := writeConnError(conn, mysql.NewErr(mysql.ErrConCount)); err != nil {
+			log.Warnf("Client %v: %v", conn.RemoteAddr(), err)
+		}
+		conn.Close()
+		log.Warnf("Client %v: too many connections, max client: %v", conn.RemoteAddr(), conf.MaxClient)
+		return
+	}
+	s.handleClient(conn)
+}
+
 func (s *Server) handleClient(conn *net.TCPConn) {
 	defer utils.PrintPanicStack()
 
@@ -94,19 +119,29 @@ func (s *Server) handleClient(conn *net.TCPConn) {
 	conn.SetNoDelay(false)
 
 	//new Client
-	client, die := newClient(conn, &s.cfg.Server)
+	client, die := newClient(conn, &s.config().Server)
 	defer func() {
+		removeClient(client)
 		s.count.Decr()
 		conn.Close()
 		log.Warnf("Client Close: id -> %v", client.ConnectID())
 	}()
 	log.Warnf("New Client: %v, id -> %v", client.Addr(), client.ConnectID())
 
+	//no user can connect from the host, reject it before the handshake like mysql
+	if !hostAllowed("", client.ip()) {
+		client.writeError(errHostNotPrivileged(client.ip()))
+		log.Warnf("Client %v: host not allowed", client.Addr())
+		return
+	}
+
 	if err := client.Handshake(); err != nil {
 		log.Error(err)
 		return
 	}
 	log.Info("Auth OK: ", client.ConnectID())
+	client.endCommand()
+	addClient(client)
 
 	var closed = false
 	for {
@@ -127,9 +162,105 @@ func (s *Server) handleClient(conn *net.TCPConn) {
 	}
 }
 
-func (s *Server) startup(conf *config.ServerConfig) {
-	InitDB(conf)
+func (s *Server) startup() *config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	InitDB(s.cfg)
+	setUsers(s.cfg.Users)
+	setMaxExecTime(&s.cfg.Server)
+	setResultLimit(&s.cfg.Server)
+	if err := setFirewall(s.cfg); err != nil {
+		log.Error("firewall: ", err)
+	}
+	if err := setRateLimits(s.cfg); err != nil {
+		log.Error("rate limit: ", err)
+	}
+	if err := setSlowLog(&s.cfg.Server); err != nil {
+		log.Error("slow log: ", err)
+	}
+	if err := setAuditLog(&s.cfg.Server); err != nil {
+		log.Error("audit log: ", err)
+	}
 	s.sysInfo()
+	return s.cfg
+}
+
+//SetConfiger set the config source, the server reload from it on SIGHUP.
+func (s *Server) SetConfiger(src config.Configer) {
+	s.mu.Lock()
+	s.src = src
+	s.mu.Unlock()
+}
+
+//reload re-read the config source and apply it.
+func (s *Server) reload() error {
+	s.mu.Lock()
+	src := s.src
+	s.mu.Unlock()
+	if src == nil {
+		return fmt.Errorf("config source is not set")
+	}
+	conf, err := src.Parse()
+	if err != nil {
+		return err
+	}
+	return s.Reload(conf)
+}
+
+func (s *Server) config() *config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg
+}
+
+//Reload apply the new config to the running server, the connected clients are kept.
+//The invalid config is rejected and the current one is kept, the listen addr need restart to change.
+func (s *Server) Reload(conf *config.Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if conf.Server.Listen != s.cfg.Server.Listen {
+		log.Warnf("Reload: listen %v -> %v need restart", s.cfg.Server.Listen, conf.Server.Listen)
+		conf.Server.Listen = s.cfg.Server.Listen
+	}
+	if conf.Server.AdminListen != s.cfg.Server.AdminListen {
+		log.Warnf("Reload: adminListen %v -> %v need restart", s.cfg.Server.AdminListen, conf.Server.AdminListen)
+		conf.Server.AdminListen = s.cfg.Server.AdminListen
+	}
+	if conf.Server.HTTPListen != s.cfg.Server.HTTPListen {
+		log.Warnf("Reload: httpListen %v -> %v need restart", s.cfg.Server.HTTPListen, conf.Server.HTTPListen)
+		conf.Server.HTTPListen = s.cfg.Server.HTTPListen
+	}
+	//nothing is changed until every part of the config is loaded
+	fw, err := newFirewall(conf)
+	if err != nil {
+		return err
+	}
+	limits, err := newRateLimits(conf)
+	if err != nil {
+		return err
+	}
+	if err := loadDB(conf); err != nil {
+		return err
+	}
+	fw.install()
+	installRateLimits(limits)
+	setUsers(conf.Users)
+	setMaxExecTime(&conf.Server)
+	setResultLimit(&conf.Server)
+	if err := setSlowLog(&conf.Server); err != nil {
+		log.Error("slow log: ", err)
+	}
+	if err := setAuditLog(&conf.Server); err != nil {
+		log.Error("audit log: ", err)
+	}
+	s.count.SetMax(conf.Server.MaxClient)
+	s.cfg = conf
+	log.Alertf("Config reloaded, max client: %v, nodes: %v, shards: %v, users: %v",
+		conf.Server.MaxClient, len(conf.Nodes)+1, len(conf.Shards), len(conf.Users))
+	return nil
 }
 
 func (s *Server) sysInfo() {
@@ -138,7 +269,7 @@ func (s *Server) sysInfo() {
 		for {
 			select {
 			case <-t.C:
-				log.Alertf("SYS Info: curCli->%v/%v", s.count.Size(), s.cfg.Server.MaxClient)
+				log.Alertf("SYS Info: curCli->%v/%v", s.count.Size(), s.config().Server.MaxClient)
 			}
 		}
 	}()
@@ -149,8 +280,16 @@ func (s *Server) signal() {
 	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
 	go func() {
-		sig := <-sc
-		log.Warnf("Got signal [%d] to exit.", sig)
-		os.Exit(0)
+		for sig := range sc {
+			if sig == syscall.SIGHUP {
+				log.Warnf("Got signal [%d] to reload config.", sig)
+				if err := s.reload(); err != nil {
+					log.Errorf("Reload config: %v", err)
+				}
+				continue
+			}
+			log.Warnf("Got signal [%d] to exit.", sig)
+			os.Exit(0)
+		}
 	}()
 }
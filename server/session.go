@@ -0,0 +1,190 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+import (
+	"igo/mysql"
+)
+
+//the connected clients by connect id, added when the client is authorized.
+var (
+	_clientMu sync.RWMutex
+	_clients  = make(map[uint32]*Client)
+)
+
+func addClient(c *Client) {
+	_clientMu.Lock()
+	_clients[c.connectID] = c
+	_clientMu.Unlock()
+}
+
+func removeClient(c *Client) {
+	_clientMu.Lock()
+	delete(_clients, c.connectID)
+	_clientMu.Unlock()
+}
+
+func getClient(id uint32) *Client {
+	_clientMu.RLock()
+	defer _clientMu.RUnlock()
+	return _clients[id]
+}
+
+//clients the connected clients ordered by connect id.
+func clients() []*Client {
+	_clientMu.RLock()
+	cs := make([]*Client, 0, len(_clients))
+	for _, c := range _clients {
+		cs = append(cs, c)
+	}
+	_clientMu.RUnlock()
+	sort.Slice(cs, func(i, j int) bool { return cs[i].connectID < cs[j].connectID })
+	return cs
+}
+
+//sessionState the live state of the client, written by the client and read by the admin.
+type sessionState struct {
+	bytesIn  uint64 //atomic
+	bytesOut uint64 //atomic
+	queries  uint64 //atomic, the COM_QUERY and COM_STMT_EXECUTE
+
+	mu      sync.Mutex
+	command byte         //the current command, 0 when idle
+	info    string       //the statement of the current command
+	since   time.Time    //the start of the current command, or the end of the last one
+	schema  string       //the schema of the client at the command boundary
+	conns   []*mysqlConn //the backend connections the current command runs on
+	inTx    bool         //the backend reported SERVER_STATUS_IN_TRANS after the last command
+}
+
+//beginCommand mark the command running, the statement is copied before the read buffer is reused.
+func (c *Client) beginCommand(data []byte) {
+	var info string
+	switch data[0] {
+	case mysql.ComQuery, mysql.ComStmtExecute:
+		atomic.AddUint64(&c.session.queries, 1)
+		info = c.commandText(data)
+	case mysql.ComStmtPrepare, mysql.ComInitDB:
+		info = c.commandText(data)
+	}
+	s := &c.session
+	s.mu.Lock()
+	s.command, s.info, s.since, s.schema = data[0], info, time.Now(), c.dbname
+	s.mu.Unlock()
+}
+
+//endCommand mark the client idle.
+func (c *Client) endCommand() {
+	s := &c.session
+	s.mu.Lock()
+	s.command, s.info, s.since, s.schema = 0, "", time.Now(), c.dbname
+	s.mu.Unlock()
+}
+
+//pin the command runs on the backend connection until unpin.
+func (c *Client) pin(mc *mysqlConn) {
+	s := &c.session
+	s.mu.Lock()
+	s.conns = append(s.conns, mc)
+	s.mu.Unlock()
+}
+
+//unpin the command is done on the backend connection, the transaction flag is taken from its status.
+func (c *Client) unpin(mc *mysqlConn) {
+	s := &c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, conn := range s.conns {
+		if conn == mc {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+	s.inTx = mc.status&mysql.StatusInTrans != 0
+}
+
+//processCommands the command names of SHOW PROCESSLIST.
+var processCommands = map[byte]string{
+	0:                         "Sleep",
+	mysql.ComQuit:             "Quit",
+	mysql.ComInitDB:           "Init DB",
+	mysql.ComQuery:            "Query",
+	mysql.ComFieldList:        "Field List",
+	mysql.ComProcessKill:      "Kill",
+	mysql.ComPing:             "Ping",
+	mysql.ComStmtPrepare:      "Prepare",
+	mysql.ComStmtExecute:      "Execute",
+	mysql.ComStmtClose:        "Close stmt",
+	mysql.ComStmtReset:        "Reset stmt",
+	mysql.ComStmtFetch:        "Fetch",
+	mysql.ComStmtSendLongData: "Long Data",
+}
+
+//Session the snapshot of a connected client.
+type Session struct {
+	ID          uint32    `json:"id"`
+	User        string    `json:"user"`
+	Addr        string    `json:"addr"`
+	Schema      string    `json:"schema"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Command     string    `json:"command"`
+	Info        string    `json:"info,omitempty"`
+	CommandAt   time.Time `json:"command_at"` //the start of the current command, or the end of the last one
+	Backend     string    `json:"backend,omitempty"`
+	InTx        bool      `json:"in_tx"`
+	BytesIn     uint64    `json:"bytes_in"`
+	BytesOut    uint64    `json:"bytes_out"`
+	Queries     uint64    `json:"queries"`
+}
+
+//Time the seconds in the current state, the Time column of SHOW PROCESSLIST.
+func (s *Session) Time() int64 {
+	return int64(time.Since(s.CommandAt) / time.Second)
+}
+
+func (c *Client) snapshot() Session {
+	s := &c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmd, ok := processCommands[s.command]
+	if !ok {
+		cmd = commandName(s.command)
+	}
+	addrs := make([]string, 0, len(s.conns))
+	for _, mc := range s.conns {
+		if mc.netConn != nil {
+			addrs = append(addrs, mc.netConn.RemoteAddr().String())
+		}
+	}
+	return Session{
+		ID:          c.connectID,
+		User:        c.user,
+		Addr:        c.Addr(),
+		Schema:      s.schema,
+		ConnectedAt: c.connectedAt,
+		Command:     cmd,
+		Info:        s.info,
+		CommandAt:   s.since,
+		Backend:     strings.Join(addrs, ","),
+		InTx:        s.inTx,
+		BytesIn:     atomic.LoadUint64(&s.bytesIn),
+		BytesOut:    atomic.LoadUint64(&s.bytesOut),
+		Queries:     atomic.LoadUint64(&s.queries),
+	}
+}
+
+//Sessions the snapshots of the connected clients ordered by connect id.
+func Sessions() []Session {
+	cs := clients()
+	sessions := make([]Session, len(cs))
+	for i, c := range cs {
+		sessions[i] = c.snapshot()
+	}
+	return sessions
+}
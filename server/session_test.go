@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_Session(t *testing.T) {
+	c, done := testClient(t, &config.ServerConfig{})
+	defer done()
+	c.user, c.dbname = "app", "db1"
+	c.endCommand()
+	addClient(c)
+	defer removeClient(c)
+
+	ss := Sessions()
+	if len(ss) != 1 || ss[0].ID != c.connectID || ss[0].Command != "Sleep" || ss[0].Schema != "db1" || ss[0].User != "app" {
+		t.Fatalf("%+v", ss)
+	}
+
+	data := append([]byte{mysql.ComQuery}, "select sleep(1)"...)
+	c.beginCommand(data)
+	data[1] = 'X' //the read buffer is reused
+	mc := &mysqlConn{netConn: c.netConn, status: mysql.StatusInTrans}
+	c.pin(mc)
+	c.writeOK()
+	s := c.snapshot()
+	if s.Command != "Query" || s.Info != "select sleep(1)" || s.Backend != c.netConn.RemoteAddr().String() || s.InTx {
+		t.Fatalf("%+v", s)
+	}
+	c.unpin(mc)
+	c.endCommand()
+	s = c.snapshot()
+	if s.Command != "Sleep" || s.Info != "" || s.Backend != "" || !s.InTx || s.Queries != 1 || s.BytesOut != 7 {
+		t.Fatalf("%+v", s)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+import (
+	"igo/config"
+)
+
+//shard rule kinds
+const (
+	ruleHash   = "hash"
+	ruleRange  = "range"
+	ruleDate   = "date"
+	ruleLookup = "lookup"
+)
+
+//policy of the statements without sharding key
+const (
+	policyReject    = "reject"
+	policyBroadcast = "broadcast"
+)
+
+var (
+	errNoShardKey    = errors.New("sharding key not found in statement")
+	errMultiShardRow = errors.New("insert rows belong to different shards")
+	errUpdateKey     = errors.New("sharding key can not be updated")
+)
+
+//shardRule locate the node of a sharded table by the key value.
+type shardRule struct {
+	table     string
+	key       string
+	kind      string
+	nodes     []string
+	bounds    []int64 //range: key value, date: unix time
+	lookup    map[string]string
+	broadcast bool
+}
+
+func newShardRule(conf *config.ShardConfig) (*shardRule, error) {
+	r := &shardRule{
+		table:  strings.ToLower(conf.Table),
+		key:    strings.ToLower(conf.Key),
+		kind:   strings.ToLower(conf.Rule),
+		nodes:  conf.Nodes,
+		lookup: conf.Lookup,
+	}
+	if r.table == "" || r.key == "" {
+		return nil, fmt.Errorf("shard: table and key must be set, table: %q, key: %q", conf.Table, conf.Key)
+	}
+	if len(r.nodes) == 0 {
+		return nil, fmt.Errorf("shard %v: nodes is empty", r.table)
+	}
+
+	switch strings.ToLower(conf.Policy) {
+	case "", policyReject:
+	case policyBroadcast:
+		r.broadcast = true
+	default:
+		return nil, fmt.Errorf("shard %v: unknown policy %q", r.table, conf.Policy)
+	}
+
+	switch r.kind {
+	case ruleHash:
+	case ruleRange, ruleDate:
+		if len(conf.Bounds) != len(r.nodes)-1 {
+			return nil, fmt.Errorf("shard %v: %v rule need %d bounds, got %d", r.table, r.kind, len(r.nodes)-1, len(conf.Bounds))
+		}
+		bounds, err := conf.ParseBounds()
+		if err != nil {
+			return nil, fmt.Errorf("shard %v: %v", r.table, err)
+		}
+		r.bounds = bounds
+	case ruleLookup:
+		if len(r.lookup) == 0 {
+			return nil, fmt.Errorf("shard %v: lookup rule need lookup table", r.table)
+		}
+	default:
+		return nil, fmt.Errorf("shard %v: unknown rule %q", r.table, conf.Rule)
+	}
+	return r, nil
+}
+
+//parseValue parse the range and date value to int64.
+func (r *shardRule) parseValue(s string) (int64, error) {
+	return config.ParseShardValue(r.kind, s)
+}
+
+//locate return the node name of the key value.
+func (r *shardRule) locate(v string) (string, error) {
+	switch r.kind {
+	case ruleHash:
+		var h uint64
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			if n < 0 {
+				n = -n
+			}
+			h = uint64(n)
+		} else {
+			h = uint64(crc32.ChecksumIEEE([]byte(v)))
+		}
+		return r.nodes[h%uint64(len(r.nodes))], nil
+
+	case ruleRange, ruleDate:
+		n, err := r.parseValue(v)
+		if err != nil {
+			return "", fmt.Errorf("shard %v: bad key value %q: %v", r.table, v, err)
+		}
+		i := sort.Search(len(r.bounds), func(i int) bool { return n < r.bounds[i] })
+		return r.nodes[i], nil
+
+	case ruleLookup:
+		if node, ok := r.lookup[v]; ok {
+			return node, nil
+		}
+		return "", fmt.Errorf("shard %v: key value %q not in lookup table", r.table, v)
+	}
+	return "", fmt.Errorf("shard %v: unknown rule %q", r.table, r.kind)
+}
+
+//shardValue the key value in statement, param is the index of placeholder or -1.
+type shardValue struct {
+	lit   string
+	param int
+}
+
+//shardPlan the sharding key values found in a statement.
+type shardPlan struct {
+	rule   *shardRule
+	values []shardValue
+	//rows of insert, every row must on same node.
+	insert bool
+	//the update assigns the key, the row would stay on the old node.
+	updateKey bool
+}
+
+//nodes return the node names the plan route to, params is the prepare statement args.
+//The insert without key values is never broadcast, the rows would be written on every node.
+func (p *shardPlan) nodes(params []string) ([]string, error) {
+	if p.updateKey {
+		return nil, errUpdateKey
+	}
+	if len(p.values) == 0 {
+		if p.insert || !p.rule.broadcast {
+			return nil, errNoShardKey
+		}
+		return p.rule.nodes, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, v := range p.values {
+		lit := v.lit
+		if v.param >= 0 {
+			if v.param >= len(params) {
+				return nil, fmt.Errorf("shard %v: missing param %d", p.rule.table, v.param)
+			}
+			lit = params[v.param]
+		}
+		node, err := p.rule.locate(lit)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[node] {
+			seen[node] = true
+			names = append(names, node)
+		}
+	}
+	if p.insert && len(names) > 1 {
+		return nil, errMultiShardRow
+	}
+	return names, nil
+}
+
+//hasParam the plan depend on the prepare statement args or not.
+func (p *shardPlan) hasParam() bool {
+	for _, v := range p.values {
+		if v.param >= 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,386 @@
+package server
+
+import (
+	"strconv"
+)
+
+import (
+	"igo/mysql/parser"
+)
+
+//tokenize split the sql into tokens, comments and white space are skipped.
+//The tokens before a broken string or comment are returned, the backend will report the error.
+func tokenize(sql []byte) []parser.Token {
+	toks, _ := parser.Tokenize(sql)
+	return toks
+}
+
+//planShard find the first sharded table in the sql and the key values of it.
+//It return nil if the statement not access any sharded table.
+func planShard(sql []byte) *shardPlan {
+	shards := getShards()
+	if len(shards) == 0 {
+		return nil
+	}
+	toks := tokenize(sql)
+	if len(toks) == 0 {
+		return nil
+	}
+
+	var plan *shardPlan
+	var tableAt int
+	for i := 0; i+1 < len(toks) && plan == nil; i++ {
+		switch toks[i].Keyword() {
+		case "FROM", "JOIN", "UPDATE", "INTO", "TABLE":
+			for j := i + 1; j < len(toks); {
+				name, n := tableName(toks[j:])
+				if n == 0 {
+					break
+				}
+				if r, ok := shards[name]; ok {
+					plan = &shardPlan{rule: r}
+					tableAt = j + n
+					break
+				}
+				j += n
+				//skip alias
+				if j < len(toks) && toks[j].Keyword() == "AS" {
+					j++
+				}
+				if j < len(toks) && toks[j].Type == parser.TokIdent && !isKeyword(toks[j].Keyword()) {
+					j++
+				}
+				if j >= len(toks) || toks[j].Val != "," {
+					break
+				}
+				j++
+			}
+		}
+	}
+	if plan == nil {
+		return nil
+	}
+
+	switch toks[0].Keyword() {
+	case "INSERT", "REPLACE":
+		plan.insert = true
+		plan.values = insertKeyValues(toks[tableAt:], plan.rule.key)
+	default:
+		names := tableNames(toks[tableAt:], plan.rule.table)
+		plan.updateKey = toks[0].Keyword() == "UPDATE" && setsKey(toks, plan.rule.key, names)
+		plan.values = whereKeyValues(toks, plan.rule.key, names)
+	}
+	return plan
+}
+
+//setsKey the SET list of the update assigns the key, unqualified or qualified by one of the names.
+func setsKey(toks []parser.Token, key string, names map[string]bool) bool {
+	set := false
+	depth := 0
+	for i, t := range toks {
+		switch t.Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth != 0 {
+			continue
+		}
+		switch t.Keyword() {
+		case "SET":
+			set = true
+			continue
+		case "WHERE", "ORDER", "LIMIT":
+			return false
+		}
+		if !set || t.Name() != key || i+1 >= len(toks) || toks[i+1].Val != "=" {
+			continue
+		}
+		switch prev := toks[i-1]; {
+		case prev.Val == ".":
+			if i >= 2 && names[toks[i-2].Name()] {
+				return true
+			}
+		case prev.Keyword() == "SET", prev.Val == ",":
+			return true
+		}
+	}
+	return false
+}
+
+//tableNames the names to qualify the columns of the table, the table name and the alias after it.
+func tableNames(toks []parser.Token, table string) map[string]bool {
+	names := map[string]bool{table: true}
+	if len(toks) > 0 && toks[0].Keyword() == "AS" {
+		toks = toks[1:]
+	}
+	if len(toks) > 0 && toks[0].Name() != "" && !isKeyword(toks[0].Keyword()) {
+		names[toks[0].Name()] = true
+	}
+	return names
+}
+
+//returnRows the statement return a result set or not.
+func returnRows(sql []byte) bool {
+	switch parser.Classify(sql) {
+	case parser.StmtSelect, parser.StmtShow, parser.StmtExplain:
+		return true
+	}
+	return false
+}
+
+//tableName read the [db.]table name, return the lower case table name and the tokens used.
+func tableName(toks []parser.Token) (string, int) {
+	name := toks[0].Name()
+	if name == "" || (toks[0].Type == parser.TokIdent && isKeyword(toks[0].Keyword())) {
+		return "", 0
+	}
+	if len(toks) > 2 && toks[1].Val == "." && toks[2].Name() != "" {
+		return toks[2].Name(), 3
+	}
+	return name, 1
+}
+
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "JOIN": true, "LEFT": true, "RIGHT": true,
+	"INNER": true, "OUTER": true, "CROSS": true, "ON": true, "USING": true, "GROUP": true,
+	"ORDER": true, "LIMIT": true, "HAVING": true, "SET": true, "VALUES": true, "VALUE": true,
+	"FOR": true, "UNION": true, "AS": true, "AND": true, "OR": true, "NOT": true, "IN": true,
+	"STRAIGHT_JOIN": true, "NATURAL": true, "LOCK": true, "INTO": true, "CASE": true,
+	"WHEN": true, "THEN": true, "ELSE": true, "END": true, "NULL": true, "ASC": true, "DESC": true,
+}
+
+func isKeyword(s string) bool {
+	return keywords[s]
+}
+
+//whereKeyValues find `key = v` and `key IN (v, ...)` in the where clause.
+//The where clause with OR, NOT or sub query is not analyzed, nil will be returned.
+//The qualified key is of the table only if qualified by one of the names.
+func whereKeyValues(toks []parser.Token, key string, names map[string]bool) []shardValue {
+	start := -1
+	depth := 0
+	for i, t := range toks {
+		switch t.Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth == 0 && t.Keyword() == "WHERE" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	end := len(toks)
+	depth = 0
+	for i := start; i < len(toks); i++ {
+		t := toks[i]
+		switch t.Val {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		switch t.Keyword() {
+		case "OR", "SELECT", "XOR", "NOT":
+			return nil
+		case "GROUP", "ORDER", "LIMIT", "HAVING", "FOR", "LOCK", "UNION":
+			if depth == 0 {
+				end = i
+			}
+		}
+		if end != len(toks) {
+			break
+		}
+		if t.Val == "|" || t.Val == "||" || t.Val == "!" {
+			return nil
+		}
+	}
+
+	var values []shardValue
+	where := toks[start:end]
+	for i := 0; i < len(where); i++ {
+		//skip other columns and the qualifier of `key.col`
+		if where[i].Name() != key || (i+1 < len(where) && where[i+1].Val == ".") {
+			continue
+		}
+		//the key of another table
+		if i >= 2 && where[i-1].Val == "." && !names[where[i-2].Name()] {
+			continue
+		}
+		if i+2 >= len(where) {
+			break
+		}
+		switch {
+		case where[i+1].Val == "=":
+			v, n := literalValue(where[i+2:])
+			if n == 0 || (i+2+n < len(where) && !endOfExpr(where[i+2+n])) {
+				continue
+			}
+			values = append(values, v)
+			i += 1 + n
+
+		case where[i+1].Keyword() == "IN" && where[i+2].Val == "(":
+			list, n := literalList(where[i+3:])
+			if n == 0 {
+				return nil
+			}
+			values = append(values, list...)
+			i += 2 + n
+		}
+	}
+	return values
+}
+
+//insertKeyValues find the key of each row in `(col, ...) VALUES (v, ...), (...)`.
+func insertKeyValues(toks []parser.Token, key string) []shardValue {
+	if len(toks) == 0 || toks[0].Val != "(" {
+		return nil
+	}
+	idx := -1
+	col := 0
+	i := 1
+	for ; i < len(toks) && toks[i].Val != ")"; i++ {
+		switch {
+		case toks[i].Val == ",":
+			col++
+		case toks[i].Name() == key:
+			idx = col
+		}
+	}
+	if idx < 0 || i+1 >= len(toks) {
+		return nil
+	}
+	i++
+	if kw := toks[i].Keyword(); kw != "VALUES" && kw != "VALUE" {
+		return nil
+	}
+	i++
+
+	var values []shardValue
+	for i < len(toks) && toks[i].Val == "(" {
+		i++
+		col = 0
+		found := false
+		for depth := 0; i < len(toks); i++ {
+			t := toks[i]
+			if depth == 0 && t.Val == ")" {
+				break
+			}
+			switch t.Val {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case ",":
+				if depth == 0 {
+					col++
+					continue
+				}
+			}
+			if depth == 0 && col == idx && !found {
+				v, n := literalValue(toks[i:])
+				if n == 0 || (i+n < len(toks) && toks[i+n].Val != "," && toks[i+n].Val != ")") {
+					return nil
+				}
+				values = append(values, v)
+				found = true
+				i += n - 1
+			}
+		}
+		if !found {
+			return nil
+		}
+		i++
+		if i < len(toks) && toks[i].Val == "," {
+			i++
+		}
+	}
+	return values
+}
+
+//literalValue read a number, string or placeholder with optional sign,
+//the hex and bit literals are decoded to the integer.
+func literalValue(toks []parser.Token) (shardValue, int) {
+	sign := ""
+	n := 0
+	if len(toks) > 1 && (toks[0].Val == "-" || toks[0].Val == "+") && toks[1].Type == parser.TokNumber {
+		if toks[0].Val == "-" {
+			sign = "-"
+		}
+		toks = toks[1:]
+		n++
+	}
+	if len(toks) == 0 || !toks[0].IsLiteral() {
+		return shardValue{}, 0
+	}
+	if toks[0].Type == parser.TokParam {
+		return shardValue{param: toks[0].Param}, n + 1
+	}
+	lit := toks[0].Val
+	if toks[0].Type == parser.TokNumber {
+		lit = numberValue(lit)
+	}
+	return shardValue{lit: sign + lit, param: -1}, n + 1
+}
+
+//numberValue decode the hex 0x1F, X'1F' and the bit 0b01, B'01' literal, the others are returned as is.
+func numberValue(s string) string {
+	base, digits := 0, ""
+	switch {
+	case len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X'):
+		base, digits = 16, s[2:]
+	case len(s) > 2 && s[0] == '0' && (s[1] == 'b' || s[1] == 'B'):
+		base, digits = 2, s[2:]
+	case len(s) > 3 && (s[0] == 'x' || s[0] == 'X') && s[1] == '\'':
+		base, digits = 16, s[2:len(s)-1]
+	case len(s) > 3 && (s[0] == 'b' || s[0] == 'B') && s[1] == '\'':
+		base, digits = 2, s[2:len(s)-1]
+	default:
+		return s
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return s
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+//literalList read `v, v, ...)`, return 0 if any item is not a literal.
+func literalList(toks []parser.Token) ([]shardValue, int) {
+	var list []shardValue
+	for i := 0; i < len(toks); {
+		v, n := literalValue(toks[i:])
+		if n == 0 {
+			return nil, 0
+		}
+		list = append(list, v)
+		i += n
+		if i >= len(toks) {
+			return nil, 0
+		}
+		switch toks[i].Val {
+		case ",":
+			i++
+		case ")":
+			return list, i + 1
+		default:
+			return nil, 0
+		}
+	}
+	return nil, 0
+}
+
+func endOfExpr(t parser.Token) bool {
+	switch t.Keyword() {
+	case "AND":
+		return true
+	}
+	return t.Val == ")"
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+import (
+	"igo/log"
+	"igo/mysql"
+)
+
+//shardStmt the sharded prepare statement, it is prepared on every node it routed to.
+type shardStmt struct {
+	plan  *shardPlan
+	query string
+	types []byte //param types of the last execute which new-params-bound
+	stmts map[*MysqlDB]*mysqlStmt
+}
+
+//routeStmt choose the statement on the node of the execute args,
+//and rewrite the statement id of the execute packet.
+func (c *Client) routeStmt(data []byte) (*mysqlStmt, []byte, error) {
+	front, ss := c.stmt, c.stmt.shard
+	params, err := ss.params(data, front.paramCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbs, err := route(nil, nil, ss.plan, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(dbs) != 1 {
+		return nil, nil, fmt.Errorf("shard %v: prepare statement route to %d nodes", ss.plan.rule.table, len(dbs))
+	}
+
+	stmt, ok := ss.stmts[dbs[0]]
+	if !ok {
+		if _, stmt, err = c.prepareOn(dbs[0], ss.query); err != nil {
+			return nil, nil, err
+		}
+		ss.stmts[dbs[0]] = stmt
+	}
+	pkt := data
+	if stmt != front {
+		pkt = make([]byte, 0, len(data))
+		pkt = append(pkt, data[:1]...)
+		pkt = append(pkt, byte(stmt.id), byte(stmt.id>>8), byte(stmt.id>>16), byte(stmt.id>>24))
+		pkt = append(pkt, data[5:]...)
+	}
+
+	//the statement never see the param types, send them with new-params-bound.
+	if front.paramCount > 0 {
+		pos := 10 + (front.paramCount+7)/8
+		if pkt[pos] == 0 && !stmt.typesSent {
+			b := make([]byte, 0, len(pkt)+len(ss.types))
+			b = append(b, pkt[:pos]...)
+			b = append(b, 1)
+			b = append(b, ss.types...)
+			pkt = append(b, pkt[pos+1:]...)
+		}
+		stmt.typesSent = true
+	}
+	return stmt, pkt, nil
+}
+
+//close close the statements on other nodes and put back the connections.
+func (ss *shardStmt) close(front *mysqlStmt) {
+	for db, stmt := range ss.stmts {
+		if stmt == front {
+			continue
+		}
+		mc := stmt.mc
+		if err := stmt.Close(); err != nil {
+			log.Error(err)
+			continue
+		}
+		db.putConn(mc)
+	}
+}
+
+// params decode the args of the execute packet to string.
+// http://dev.mysql.com/doc/internals/en/com-stmt-execute.html
+func (ss *shardStmt) params(data []byte, count int) ([]string, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	// cmd [1 byte], stmt id [4 bytes], flags [1 byte], iteration count [4 bytes]
+	pos := 10
+	// null bitmap and new-params-bound flag [1 byte]
+	if len(data) < pos+(count+7)/8+1 {
+		return nil, mysql.ErrMalformPkt
+	}
+	nullMask := data[pos : pos+(count+7)/8]
+	pos += len(nullMask)
+
+	if data[pos] == 1 {
+		if len(data) < pos+1+2*count {
+			return nil, mysql.ErrMalformPkt
+		}
+		ss.types = append(ss.types[:0], data[pos+1:pos+1+2*count]...)
+		pos += 2 * count
+	}
+	pos++
+	if len(ss.types) != 2*count {
+		return nil, mysql.ErrMalformPkt
+	}
+
+	params := make([]string, count)
+	for i := 0; i < count; i++ {
+		if nullMask[i/8]&(1<<uint(i%8)) != 0 {
+			continue
+		}
+		v, n, err := readBinaryParam(data[pos:], ss.types[2*i], ss.types[2*i+1]&0x80 != 0)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = v
+		pos += n
+	}
+	return params, nil
+}
+
+//readBinaryParam read a binary protocol value as string, return the value and bytes read.
+func readBinaryParam(b []byte, fieldType byte, unsigned bool) (string, int, error) {
+	need := func(n int) error {
+		if len(b) < n {
+			return mysql.ErrMalformPkt
+		}
+		return nil
+	}
+	switch fieldType {
+	case mysql.FieldTypeNULL:
+		return "", 0, nil
+
+	case mysql.FieldTypeTiny:
+		if err := need(1); err != nil {
+			return "", 0, err
+		}
+		if unsigned {
+			return strconv.FormatUint(uint64(b[0]), 10), 1, nil
+		}
+		return strconv.FormatInt(int64(int8(b[0])), 10), 1, nil
+
+	case mysql.FieldTypeShort, mysql.FieldTypeYear:
+		if err := need(2); err != nil {
+			return "", 0, err
+		}
+		v := binary.LittleEndian.Uint16(b)
+		if unsigned {
+			return strconv.FormatUint(uint64(v), 10), 2, nil
+		}
+		return strconv.FormatInt(int64(int16(v)), 10), 2, nil
+
+	case mysql.FieldTypeLong, mysql.FieldTypeInt24:
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		v := binary.LittleEndian.Uint32(b)
+		if unsigned {
+			return strconv.FormatUint(uint64(v), 10), 4, nil
+		}
+		return strconv.FormatInt(int64(int32(v)), 10), 4, nil
+
+	case mysql.FieldTypeLongLong:
+		if err := need(8); err != nil {
+			return "", 0, err
+		}
+		v := binary.LittleEndian.Uint64(b)
+		if unsigned {
+			return strconv.FormatUint(v, 10), 8, nil
+		}
+		return strconv.FormatInt(int64(v), 10), 8, nil
+
+	case mysql.FieldTypeFloat:
+		if err := need(4); err != nil {
+			return "", 0, err
+		}
+		v := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), 4, nil
+
+	case mysql.FieldTypeDouble:
+		if err := need(8); err != nil {
+			return "", 0, err
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		return strconv.FormatFloat(v, 'f', -1, 64), 8, nil
+
+	case mysql.FieldTypeDate, mysql.FieldTypeNewDate, mysql.FieldTypeTimestamp, mysql.FieldTypeDateTime:
+		if err := need(1); err != nil {
+			return "", 0, err
+		}
+		n := int(b[0])
+		if err := need(1 + n); err != nil {
+			return "", 0, err
+		}
+		v := b[1 : 1+n]
+		switch n {
+		case 0:
+			return "0000-00-00", 1, nil
+		case 4:
+			return fmt.Sprintf("%04d-%02d-%02d", binary.LittleEndian.Uint16(v), v[2], v[3]), 1 + n, nil
+		default:
+			if n < 7 {
+				return "", 0, mysql.ErrMalformPkt
+			}
+			return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", binary.LittleEndian.Uint16(v), v[2], v[3], v[4], v[5], v[6]), 1 + n, nil
+		}
+
+	case mysql.FieldTypeTime:
+		if err := need(1); err != nil {
+			return "", 0, err
+		}
+		n := int(b[0])
+		if err := need(1 + n); err != nil {
+			return "", 0, err
+		}
+		v := b[1 : 1+n]
+		if n < 8 {
+			return "00:00:00", 1 + n, nil
+		}
+		sign := ""
+		if v[0] == 1 {
+			sign = "-"
+		}
+		hours := binary.LittleEndian.Uint32(v[1:5])*24 + uint32(v[5])
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, v[6], v[7]), 1 + n, nil
+	}
+
+	// string, decimal and blob types are length encoded string
+	switch {
+	case len(b) == 0, b[0] == 0xfc && len(b) < 3, b[0] == 0xfd && len(b) < 4, b[0] == 0xfe && len(b) < 9:
+		return "", 0, mysql.ErrMalformPkt
+	}
+	v, _, n, err := readLengthEncodedString(b)
+	if err != nil {
+		return "", 0, mysql.ErrMalformPkt
+	}
+	return string(v), n, nil
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_ShardRule(t *testing.T) {
+	cases := []struct {
+		conf config.ShardConfig
+		in   string
+		node string
+	}{
+		{config.ShardConfig{Table: "t", Key: "id", Rule: "hash", Nodes: []string{"n0", "n1", "n2"}}, "7", "n1"},
+		{config.ShardConfig{Table: "t", Key: "id", Rule: "hash", Nodes: []string{"n0", "n1", "n2"}}, "-7", "n1"},
+		{config.ShardConfig{Table: "t", Key: "id", Rule: "range", Nodes: []string{"n0", "n1", "n2"}, Bounds: []string{"100", "200"}}, "99", "n0"},
+		{config.ShardConfig{Table: "t", Key: "id", Rule: "range", Nodes: []string{"n0", "n1", "n2"}, Bounds: []string{"100", "200"}}, "100", "n1"},
+		{config.ShardConfig{Table: "t", Key: "id", Rule: "range", Nodes: []string{"n0", "n1", "n2"}, Bounds: []string{"100", "200"}}, "5000", "n2"},
+		{config.ShardConfig{Table: "t", Key: "d", Rule: "date", Nodes: []string{"n0", "n1"}, Bounds: []string{"2017-01-01"}}, "2016-12-31 23:59:59", "n0"},
+		{config.ShardConfig{Table: "t", Key: "d", Rule: "date", Nodes: []string{"n0", "n1"}, Bounds: []string{"2017-01-01"}}, "2017-01-01", "n1"},
+		{config.ShardConfig{Table: "t", Key: "c", Rule: "lookup", Nodes: []string{"n0", "n1"}, Lookup: map[string]string{"cn": "n1"}}, "cn", "n1"},
+	}
+	for i, c := range cases {
+		r, err := newShardRule(&c.conf)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		node, err := r.locate(c.in)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if node != c.node {
+			t.Fatalf("case %d: locate %q got %v, want %v", i, c.in, node, c.node)
+		}
+	}
+
+	bad := []config.ShardConfig{
+		{Table: "t", Key: "id", Rule: "range", Nodes: []string{"n0", "n1"}},
+		{Table: "t", Key: "id", Rule: "range", Nodes: []string{"n0", "n1", "n2"}, Bounds: []string{"200", "100"}},
+		{Table: "t", Key: "id", Rule: "lookup", Nodes: []string{"n0"}},
+		{Table: "t", Key: "id", Rule: "mod", Nodes: []string{"n0"}},
+		{Table: "t", Key: "id", Rule: "hash", Nodes: []string{"n0"}, Policy: "random"},
+	}
+	for i := range bad {
+		if _, err := newShardRule(&bad[i]); err == nil {
+			t.Fatalf("case %d: expect error", i)
+		}
+	}
+}
+
+func Test_PlanShard(t *testing.T) {
+	r, err := newShardRule(&config.ShardConfig{Table: "user", Key: "uid", Rule: "hash", Nodes: []string{"n0", "n1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_shards = map[string]*shardRule{"user": r}
+	defer func() { _shards = make(map[string]*shardRule) }()
+
+	cases := []struct {
+		sql     string
+		values  []shardValue
+		nilPlan bool
+	}{
+		{sql: "select * from other where uid = 1", nilPlan: true},
+		{sql: "select * from user where uid = 1", values: []shardValue{{"1", -1}}},
+		{sql: "SELECT * FROM `db`.`user` u WHERE u.uid IN (1, '2', -3) ORDER BY id", values: []shardValue{{"1", -1}, {"2", -1}, {"-3", -1}}},
+		{sql: "select * from user where name = ? and uid = ?", values: []shardValue{{"", 1}}},
+		{sql: "select * from user where uid = 1 or uid = 2"},
+		{sql: "select * from user where uid = 1 + 1"},
+		{sql: "select * from user where uid in (0x10, X'1f', 0b101, b'11', 0x)", values: []shardValue{{"16", -1}, {"31", -1}, {"5", -1}, {"3", -1}, {"0x", -1}}},
+		{sql: "select * from user u join orders o on o.id = u.oid where o.uid = 3"},
+		{sql: "select * from user as u join orders o on o.id = u.oid where o.uid = 3 and u.uid = 4", values: []shardValue{{"4", -1}}},
+		{sql: "select * from orders o join db.user on o.id = user.oid where db.user.uid = 5", values: []shardValue{{"5", -1}}},
+		{sql: "select * from user where not uid = 1"},
+		{sql: "select * from user where !(uid = 1)"},
+		{sql: "select * from user where uid = 1 and not (uid = 1)"},
+		{sql: "select * from user where uid != 1"},
+		{sql: "select * from user where uid in (select uid from t)"},
+		{sql: "update user set name = 'a' where uid = 'x\\'y'", values: []shardValue{{"x'y", -1}}},
+		{sql: "delete /* uid = 1 */ from user where uid = 2", values: []shardValue{{"2", -1}}},
+		{sql: "insert into user (name, uid) values ('a', 1), ('b', ?)", values: []shardValue{{"1", -1}, {"", 0}}},
+		{sql: "insert into user values (1, 'a')"},
+	}
+	for _, c := range cases {
+		p := planShard([]byte(c.sql))
+		if c.nilPlan {
+			if p != nil {
+				t.Fatalf("%v: expect nil plan", c.sql)
+			}
+			continue
+		}
+		if p == nil {
+			t.Fatalf("%v: expect plan", c.sql)
+		}
+		if len(p.values) != len(c.values) {
+			t.Fatalf("%v: got values %v, want %v", c.sql, p.values, c.values)
+		}
+		for i := range c.values {
+			if p.values[i] != c.values[i] {
+				t.Fatalf("%v: got values %v, want %v", c.sql, p.values, c.values)
+			}
+		}
+	}
+
+	p := planShard([]byte("insert into user (uid) values (0), (1)"))
+	if _, err := p.nodes(nil); err != errMultiShardRow {
+		t.Fatal("expect errMultiShardRow, got", err)
+	}
+	p = planShard([]byte("select * from user"))
+	if _, err := p.nodes(nil); err != errNoShardKey {
+		t.Fatal("expect errNoShardKey, got", err)
+	}
+
+	for _, sql := range []string{
+		"update user set uid = 5 where uid = 1",
+		"update user u set name = 'a', u.uid = 5 where uid = 1",
+		"update user set `uid` = 5",
+	} {
+		if _, err := planShard([]byte(sql)).nodes(nil); err != errUpdateKey {
+			t.Fatalf("%v: expect errUpdateKey, got %v", sql, err)
+		}
+	}
+	for _, sql := range []string{
+		"update user set name = (select uid = 1 from t) where uid = 1",
+		"update user u join orders o on o.id = u.oid set o.uid = 5 where u.uid = 1",
+		"update user set a = 1 where uid = 1 and name = 'x'",
+	} {
+		if _, err := planShard([]byte(sql)).nodes(nil); err != nil {
+			t.Fatalf("%v: %v", sql, err)
+		}
+	}
+
+	//the broadcast policy does not apply to the insert without key
+	r.broadcast = true
+	if nodes, err := planShard([]byte("select * from user")).nodes(nil); err != nil || len(nodes) != 2 {
+		t.Fatal(nodes, err)
+	}
+	for _, sql := range []string{
+		"insert into user values (1, 'a')",
+		"insert into user set uid = 1, name = 'a'",
+		"replace into user select * from t",
+	} {
+		if _, err := planShard([]byte(sql)).nodes(nil); err != errNoShardKey {
+			t.Fatalf("%v: expect errNoShardKey, got %v", sql, err)
+		}
+	}
+}
+
+func Test_ReadBinaryParam(t *testing.T) {
+	v, n, err := readBinaryParam([]byte{0xfe, 0xff, 0xff, 0xff}, 0x03, false)
+	if err != nil || v != "-2" || n != 4 {
+		t.Fatal(v, n, err)
+	}
+	v, n, err = readBinaryParam([]byte{3, 'a', 'b', 'c'}, 0xfd, false)
+	if err != nil || v != "abc" || n != 4 {
+		t.Fatal(v, n, err)
+	}
+	v, n, err = readBinaryParam([]byte{4, 0xe1, 0x07, 1, 2}, 0x0a, false)
+	if err != nil || v != "2017-01-02" || n != 5 {
+		t.Fatal(v, n, err)
+	}
+}
+
+func Test_StmtParams(t *testing.T) {
+	//execute stmt 1 with 2 params: bigint 7 and varchar "ab"
+	head := []byte{mysql.ComStmtExecute, 1, 0, 0, 0, 0, 1, 0, 0, 0}
+	pkt := append(append([]byte{}, head...), 0, 1, 0x08, 0, 0xfd, 0, 7, 0, 0, 0, 0, 0, 0, 0, 2, 'a', 'b')
+	ss := &shardStmt{}
+	params, err := ss.params(pkt, 2)
+	if err != nil || len(params) != 2 || params[0] != "7" || params[1] != "ab" {
+		t.Fatal(params, err)
+	}
+
+	//the truncated packets are malformed instead of panic
+	for i := 0; i < len(pkt); i++ {
+		if _, err := (&shardStmt{}).params(pkt[:i], 2); err != mysql.ErrMalformPkt {
+			t.Fatal(i, err)
+		}
+	}
+	//the types of the last execute are used without new-params-bound
+	if _, err := ss.params(append(append([]byte{}, head...), 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 'c'), 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.params(append(append([]byte{}, head...), 0, 0), 2); err != mysql.ErrMalformPkt {
+		t.Fatal(err)
+	}
+}
+
+func Test_BroadcastError(t *testing.T) {
+	addr, _, stop := testBackend(t)
+	defer stop()
+	conf := &config.Config{
+		Server: config.ServerConfig{Addr: addr, MaxIdleConn: 1, MaxConnNum: 1},
+		Nodes:  []config.NodeConfig{{Name: "n1", Addr: "127.0.0.1:1"}},
+	}
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	db := GetNode(defaultNode)
+	for i := 0; i < 100; i++ {
+		if mc := db.getConn(); mc != nil {
+			db.putConn(mc)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c, done := testClient(t, &conf.Server)
+	defer done()
+	if err := c.broadcast([]*MysqlDB{db, GetNode("n1")}, append([]byte{mysql.ComQuery}, "update t set a = 1"...)); err != nil {
+		t.Fatal("the client should be kept", err)
+	}
+	if c.sent.errCode != mysql.ErrUnknown {
+		t.Fatalf("%+v", c.sent)
+	}
+	err := errBroadcast(errCannotGetConn, GetNode("n1"), []string{addr})
+	if e := err.(*mysql.SQLError); !strings.Contains(e.Message, "127.0.0.1:1") || !strings.Contains(e.Message, addr) {
+		t.Fatal(err)
+	}
+}
+
+func Test_Scatter(t *testing.T) {
+	addr1, _, stop1 := testBackend(t)
+	defer stop1()
+	addr2, _, stop2 := testBackend(t)
+	defer stop2()
+	conf := &config.Config{
+		Server: config.ServerConfig{Addr: addr1, MaxIdleConn: 2, MaxConnNum: 2},
+		Nodes:  []config.NodeConfig{{Name: "n1", Addr: addr2}},
+	}
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	dbs := []*MysqlDB{GetNode(defaultNode), GetNode("n1")}
+	for _, db := range dbs {
+		for i := 0; i < 100; i++ {
+			if mc := db.getConn(); mc != nil {
+				db.putConn(mc)
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	c, done := testClient(t, &conf.Server)
+	defer done()
+	if err := c.scatter(dbs, append([]byte{mysql.ComQuery}, "select rows 3"...)); err != nil || c.sent.errCode != 0 || c.sent.rows != 6 {
+		t.Fatal(err, c.sent)
+	}
+
+	//the limit is of the merged result set
+	setResultLimit(&config.ServerConfig{MaxResultRows: 5})
+	defer setResultLimit(&config.ServerConfig{})
+	c.sent = cmdStat{}
+	if err := c.scatter(dbs, append([]byte{mysql.ComQuery}, "select rows 3"...)); err != nil || c.sent.errCode != mysql.ErrQueryInterrupted {
+		t.Fatal(err, c.sent)
+	}
+	c.sent = cmdStat{}
+	if err := c.scatter(dbs, append([]byte{mysql.ComQuery}, "select rows 2"...)); err != nil || c.sent.errCode != 0 || c.sent.rows != 4 {
+		t.Fatal(err, c.sent)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+)
+
+//the slow log settings, set by setSlowLog on startup and reload.
+var (
+	_slowTime = int64(-1) //atomic, the threshold in nanoseconds, < 0 means disabled
+	_slowFile string      //the opened slow log file, guarded by Server.mu
+)
+
+//setSlowLog open, reopen or close the slow log file, and set the threshold.
+func setSlowLog(conf *config.ServerConfig) error {
+	if conf.SlowLog == "" {
+		atomic.StoreInt64(&_slowTime, -1)
+		if _slowFile != "" {
+			log.DelSlowLogger()
+			_slowFile = ""
+		}
+		return nil
+	}
+	if conf.SlowLog != _slowFile {
+		jsonconf, _ := json.Marshal(map[string]string{"filename": conf.SlowLog})
+		if err := log.SetSlowLogger("slowfile", string(jsonconf)); err != nil {
+			return err
+		}
+		_slowFile = conf.SlowLog
+	}
+	atomic.StoreInt64(&_slowTime, int64(time.Duration(conf.SlowTime)*time.Millisecond))
+	return nil
+}
+
+func slowLogOn() bool {
+	return atomic.LoadInt64(&_slowTime) >= 0
+}
+
+//cmdStat what the current command sent to the client, for the slow log and the digest stats.
+type cmdStat struct {
+	rows     uint64 //the rows of the result set
+	affected uint64 //the affected rows of the ok packet
+	bytes    uint64
+	errCode  uint16 //the code of the error packet sent, 0 if none
+}
+
+//addResult count the rows of the result packets: an ok packet, or a result set of
+//the column count, the columns, EOF, the rows and EOF.
+func (s *cmdStat) addResult(payloads [][]byte) {
+	if len(payloads) == 0 || len(payloads[0]) == 0 {
+		return
+	}
+	switch payloads[0][0] {
+	case mysql.HeaderOK:
+		if len(payloads) == 1 {
+			n, _, _ := readLengthEncodedInteger(payloads[0][1:])
+			s.affected += n
+		}
+	case mysql.HeaderERR:
+		if len(payloads[0]) >= 3 {
+			s.errCode = binary.LittleEndian.Uint16(payloads[0][1:3])
+		}
+	case mysql.HeaderLocalInFile:
+	default:
+		cols, _, _ := readLengthEncodedInteger(payloads[0])
+		if n := uint64(len(payloads)); n > cols+3 {
+			s.rows += n - cols - 3
+		}
+	}
+}
+
+//setRoute record the databases the command executed on.
+func (c *Client) setRoute(dbs ...*MysqlDB) {
+	c.route = routeOf(dbs...)
+	addrs := make([]string, 0, len(dbs))
+	for _, db := range dbs {
+		if db != nil {
+			addrs = append(addrs, db.addr)
+		}
+	}
+	c.backend = strings.Join(addrs, ",")
+}
+
+//commandText the statement of the command in the slow log and the audit log,
+//it is copied before the read buffer is reused by the result packets.
+func (c *Client) commandText(data []byte) string {
+	switch data[0] {
+	case mysql.ComQuery, mysql.ComStmtPrepare:
+		return string(data[1:])
+	case mysql.ComStmtExecute:
+		if c.stmt != nil {
+			return c.stmt.query
+		}
+	case mysql.ComInitDB:
+		return "use " + string(data[1:])
+	}
+	return "# administrator command: " + commandName(data[0])
+}
+
+//logSlow write the command took longer than the threshold to the slow log.
+func (c *Client) logSlow(cmd byte, query string, start time.Time) {
+	d := time.Since(start)
+	if t := atomic.LoadInt64(&_slowTime); t < 0 || d < time.Duration(t) {
+		return
+	}
+	host, _, _ := net.SplitHostPort(c.Addr())
+	e := &log.SlowEntry{
+		Time:      start,
+		Duration:  d,
+		ID:        c.connectID,
+		User:      c.user,
+		Host:      host,
+		Schema:    c.dbname,
+		Backend:   c.backend,
+		BytesSent: c.sent.bytes,
+		Query:     query,
+	}
+	if cmd == mysql.ComQuery || cmd == mysql.ComStmtExecute {
+		e.RowsSent, e.RowsAffected = c.sent.rows, c.sent.affected
+	}
+	log.Slow(e)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_SlowLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "igo_slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := &config.ServerConfig{SlowLog: filepath.Join(dir, "slow.log"), SlowTime: 0}
+	if err := setSlowLog(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer setSlowLog(&config.ServerConfig{})
+
+	c, done := testClient(t, conf)
+	defer done()
+	c.user, c.dbname = "app", "test"
+	c.setRoute(&MysqlDB{addr: "127.0.0.1:3306", role: routeMaster})
+	//1 column, 2 rows
+	res := [][]byte{{1}, {3, 'd', 'e', 'f'}, {0xfe, 0, 0, 2, 0}, {1, '1'}, {1, '2'}, {0xfe, 0, 0, 2, 0}}
+	if err := c.writeResultPackets(res); err != nil {
+		t.Fatal(err)
+	}
+	c.logSlow(mysql.ComQuery, "select a from t", time.Now().Add(-1500*time.Millisecond))
+	c.logSlow(mysql.ComPing, c.commandText([]byte{mysql.ComPing}), time.Now())
+
+	data, err := ioutil.ReadFile(conf.SlowLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, s := range []string{
+		"# User@Host: app[app] @ 127.0.0.1 [127.0.0.1]  Id: ",
+		"# Schema: test  Backend: 127.0.0.1:3306\n",
+		"# Query_time: 1.5",
+		"Rows_sent: 2  Rows_examined: 0  Rows_affected: 0  Bytes_sent: 43\n",
+		"\nselect a from t;\n",
+		"\n# administrator command: ping;\n",
+	} {
+		if !strings.Contains(log, s) {
+			t.Fatalf("%q not found in:\n%s", s, log)
+		}
+	}
+
+	//the fast command is not logged
+	setSlowLog(&config.ServerConfig{SlowLog: conf.SlowLog, SlowTime: 1000})
+	c.logSlow(mysql.ComQuery, "select 1", time.Now())
+	if data, _ := ioutil.ReadFile(conf.SlowLog); strings.Contains(string(data), "select 1") {
+		t.Fatal("fast query logged")
+	}
+}
+
+//testClient a client connected to a peer which discards the packets.
+func testClient(t *testing.T, conf *config.ServerConfig) (*Client, func()) {
+	ls, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := ls.Accept()
+		if err == nil {
+			ioutil.ReadAll(conn)
+		}
+	}()
+	conn, err := net.DialTCP("tcp", nil, ls.Addr().(*net.TCPAddr))
+	if err != nil {
+		ls.Close()
+		t.Fatal(err)
+	}
+	c, _ := newClient(conn, conf)
+	return c, func() {
+		conn.Close()
+		ls.Close()
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+)
+
+//_maxExecTime the max execution time of the statements in nanoseconds, 0 means no limit, atomic.
+var _maxExecTime int64
+
+//errQueryTimeout the statement is killed for running longer than the max execution time.
+var errQueryTimeout = mysql.NewErrf(mysql.ErrQueryInterrupted,
+	"Query execution was interrupted, maximum statement execution time exceeded")
+
+func setMaxExecTime(conf *config.ServerConfig) {
+	atomic.StoreInt64(&_maxExecTime, int64(conf.MaxExecTime)*int64(time.Millisecond))
+}
+
+//maxExecTime the max execution time of the statements of the client, the user config overrides the server one.
+func (c *Client) maxExecTime() time.Duration {
+	_userMu.RLock()
+	u := _users[c.user]
+	_userMu.RUnlock()
+	if u != nil && u.MaxExecTime > 0 {
+		return time.Duration(u.MaxExecTime) * time.Millisecond
+	}
+	return time.Duration(atomic.LoadInt64(&_maxExecTime))
+}
+
+//watchdog kill the statement running on the backend connection when the time is up.
+type watchdog struct {
+	timer *time.Timer
+	done  chan struct{} //closed when the kill is done
+}
+
+//watch start the watchdog of the statement about to run on mc, nil if d is 0.
+func watch(mc *mysqlConn, d time.Duration) *watchdog {
+	if d <= 0 {
+		return nil
+	}
+	w := &watchdog{done: make(chan struct{})}
+	w.timer = time.AfterFunc(d, func() {
+		defer close(w.done)
+		_queryTimeouts.Inc()
+		if err := mc.killQuery(); err != nil {
+			log.Errorf("kill query of thread %v on %v: %v", mc.threadID, mc.cfg.Addr, err)
+		}
+	})
+	return w
+}
+
+//stop stop the watchdog after the statement returned, killed is true if the kill is sent,
+//the kill is done when it returns, so the connection is not reused before it.
+func (w *watchdog) stop() (killed bool) {
+	if w == nil || w.timer.Stop() {
+		return false
+	}
+	<-w.done
+	return true
+}
+
+//resetAfterKill make the connection clean after the statement is killed, err is the error the statement returned.
+//The kill may reach the thread after the statement is done, so ping it to be sure it is usable,
+//the connection is marked broken and closed by putConn if it is not.
+func (mc *mysqlConn) resetAfterKill(err error) {
+	if _, ok := err.(*mysql.MySQLError); err != nil && !ok {
+		mc.broken = true
+		return
+	}
+	if _, err := mc.Exec([]byte{mysql.ComPing}); err != nil {
+		log.Errorf("ping thread %v on %v after kill: %v", mc.threadID, mc.cfg.Addr, err)
+		mc.broken = true
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_QueryTimeout(t *testing.T) {
+	addr, queries, stop := testBackend(t)
+	defer stop()
+	conf := &config.Config{Server: config.ServerConfig{Listen: "127.0.0.1:6603", Addr: addr, MaxIdleConn: 2, MaxConnNum: 2, MaxExecTime: 50}}
+	if err := loadDB(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _nodes = make(map[string]*node) }()
+	setMaxExecTime(&conf.Server)
+	defer setMaxExecTime(&config.ServerConfig{})
+
+	//the pool opens the connections in the background
+	db := GetNode(defaultNode)
+	for i := 0; i < 100; i++ {
+		if mc := db.getConn(); mc != nil {
+			db.putConn(mc)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c, done := testClient(t, &conf.Server)
+	defer done()
+	c.user = "app"
+	query := func(sql string) error {
+		c.sent = cmdStat{}
+		return c.handleQuery(append([]byte{mysql.ComQuery}, sql...))
+	}
+	drain := func() {
+		for len(queries) > 0 {
+			<-queries
+		}
+	}
+
+	start := time.Now()
+	if err := query("select sleep(10)"); err != nil || c.sent.errCode != mysql.ErrQueryInterrupted {
+		t.Fatal(err, c.sent)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > time.Second {
+		t.Fatal("killed after", d)
+	}
+	drain()
+	if err := query("select 1"); err != nil || c.sent.errCode != 0 {
+		t.Fatal("the killed connection should be reusable", err, c.sent)
+	}
+
+	//the ERR packet after the rows ends the result set
+	if err, ok := query("select broken").(*mysql.MySQLError); !ok || err.Number != mysql.ErrQueryInterrupted {
+		t.Fatal(err)
+	}
+
+	//the statement on the connection lost after the kill is dropped
+	drain()
+	stmt := &mysqlStmt{id: 1, mc: db.getConn(), db: db}
+	c.stmt = stmt
+	exec := func() error {
+		c.sent = cmdStat{}
+		return c.handlestmtExec([]byte{mysql.ComStmtExecute, byte(stmt.id), 0, 0, 0, 0, 1, 0, 0, 0})
+	}
+	if err := exec(); err != nil || c.sent.errCode != mysql.ErrQueryInterrupted {
+		t.Fatal(err, c.sent)
+	}
+	if c.stmt != nil || stmt.mc != nil {
+		t.Fatal("the statement should be dropped")
+	}
+	if err := exec(); err != nil || c.sent.errCode != mysql.ErrUnknownStmtHandler {
+		t.Fatal(err, c.sent)
+	}
+	if err := c.handleStmtClose([]byte{mysql.ComStmtClose, 1, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	setUsers([]config.UserConfig{{Name: "app", MaxExecTime: 2000}})
+	defer setUsers(nil)
+	if d := c.maxExecTime(); d != 2*time.Second {
+		t.Fatal(d)
+	}
+}
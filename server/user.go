@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"strings"
+	"sync"
+)
+
+import (
+	"igo/config"
+	"igo/log"
+	"igo/mysql"
+)
+
+//the proxy users by name, empty means the auth is disabled.
+var (
+	_userMu    sync.RWMutex
+	_users     = make(map[string]*config.UserConfig)
+	_userHosts = make(map[string][]*net.IPNet) //the hosts allowed of the users, nil allows any
+)
+
+//setUsers replace the proxy users, the connected clients are not affected.
+func setUsers(users []config.UserConfig) {
+	m := make(map[string]*config.UserConfig, len(users))
+	hosts := make(map[string][]*net.IPNet, len(users))
+	for i := range users {
+		m[users[i].Name] = &users[i]
+		nets, err := ipNets(users[i].Hosts)
+		if err != nil {
+			log.Errorf("user %q hosts: %v", users[i].Name, err)
+			nets = []*net.IPNet{} //allow none instead of any
+		}
+		hosts[users[i].Name] = nets
+	}
+	_userMu.Lock()
+	_users, _userHosts = m, hosts
+	_userMu.Unlock()
+}
+
+//hostAllowed the user can connect from the ip, any user if user is empty.
+func hostAllowed(user string, ip net.IP) bool {
+	_userMu.RLock()
+	defer _userMu.RUnlock()
+	if len(_users) == 0 {
+		return true
+	}
+	if user != "" {
+		nets, ok := _userHosts[user]
+		//the unknown user fails the auth
+		return !ok || nets == nil || containsIP(nets, ip)
+	}
+	for _, nets := range _userHosts {
+		if nets == nil || containsIP(nets, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//errHostNotPrivileged the error of the client connecting from the host not allowed.
+func errHostNotPrivileged(ip net.IP) error {
+	_hostRejected.Inc()
+	return mysql.NewErr(mysql.ErrHostNotPrivileged, ip.String())
+}
+
+//checkAuth check the scrambled password of the user, any user pass if no user configured.
+func checkAuth(user string, salt, auth []byte) bool {
+	_userMu.RLock()
+	defer _userMu.RUnlock()
+	if len(_users) == 0 {
+		return true
+	}
+	u := _users[user]
+	if u == nil {
+		return false
+	}
+	return checkPassword(u.Passwd, salt, auth)
+}
+
+//checkAdminAuth check the user and the scrambled password of the admin port.
+func checkAdminAuth(conf *config.ServerConfig, user string, salt, auth []byte) bool {
+	return user == conf.AdminUser && checkPassword(conf.AdminPasswd, salt, auth)
+}
+
+//checkPassword check the scrambled password by the cleartext or the mysql_native_password hash.
+func checkPassword(passwd config.Password, salt, auth []byte) bool {
+	if hash, ok := nativeHash(string(passwd)); ok {
+		return checkNativeHash(salt, auth, hash)
+	}
+	return bytes.Equal(auth, mysql.ScramblePassword(salt, []byte(passwd)))
+}
+
+//nativeHash decode the mysql_native_password hash "*" + HEX(SHA1(SHA1(password))).
+func nativeHash(passwd string) ([]byte, bool) {
+	if len(passwd) != 41 || passwd[0] != '*' {
+		return nil, false
+	}
+	hash, err := hex.DecodeString(strings.ToLower(passwd[1:]))
+	if err != nil {
+		return nil, false
+	}
+	return hash, true
+}
+
+//checkNativeHash check the auth by the password hash,
+//auth = SHA1(password) XOR SHA1(salt + hash), so SHA1(auth XOR SHA1(salt + hash)) must be the hash.
+func checkNativeHash(salt, auth, hash []byte) bool {
+	if len(auth) != sha1.Size {
+		return false
+	}
+	h := sha1.New()
+	h.Write(salt)
+	h.Write(hash)
+	stage1 := h.Sum(nil)
+	for i := range stage1 {
+		stage1[i] ^= auth[i]
+	}
+	check := sha1.Sum(stage1)
+	return bytes.Equal(check[:], hash)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"igo/config"
+	"igo/mysql"
+)
+
+func Test_CheckAuth(t *testing.T) {
+	defer setUsers(nil)
+	salt := []byte("abcdefghijklmnopqrst")
+	if !checkAuth("anyone", salt, nil) {
+		t.Fatal("no user configured should pass")
+	}
+
+	stage1 := sha1.Sum([]byte("secret"))
+	stage2 := sha1.Sum(stage1[:])
+	hash := "*" + strings.ToUpper(hex.EncodeToString(stage2[:]))
+	setUsers([]config.UserConfig{{Name: "app", Passwd: "secret"}, {Name: "hashed", Passwd: config.Password(hash)}, {Name: "empty"}})
+
+	auth := mysql.ScramblePassword(salt, []byte("secret"))
+	cases := []struct {
+		user string
+		auth []byte
+		ok   bool
+	}{
+		{"app", auth, true},
+		{"hashed", auth, true},
+		{"hashed", mysql.ScramblePassword(salt, []byte("wrong")), false},
+		{"hashed", nil, false},
+		{"empty", nil, true},
+		{"empty", auth, false},
+		{"none", auth, false},
+	}
+	for _, c := range cases {
+		if checkAuth(c.user, salt, c.auth) != c.ok {
+			t.Fatalf("%v: expect %v", c.user, c.ok)
+		}
+	}
+}
+
+func Test_HostAllowed(t *testing.T) {
+	defer setUsers(nil)
+	setUsers([]config.UserConfig{
+		{Name: "app", Passwd: "secret", Hosts: []string{"127.0.0.1", "10.0.0.0/8"}},
+		{Name: "report", Hosts: []string{"192.168.1.0/24"}},
+	})
+	for _, c := range []struct {
+		user string
+		ip   string
+		ok   bool
+	}{
+		{"app", "127.0.0.1", true},
+		{"app", "10.1.2.3", true},
+		{"app", "192.168.1.1", false},
+		{"report", "192.168.1.1", true},
+		{"none", "192.168.2.1", true},
+		{"", "192.168.1.1", true},
+		{"", "192.168.2.1", false},
+	} {
+		if hostAllowed(c.user, net.ParseIP(c.ip)) != c.ok {
+			t.Fatalf("%v@%v: expect %v", c.user, c.ip, c.ok)
+		}
+	}
+
+	//rejected with ER_HOST_NOT_PRIVILEGED before and during the handshake
+	s := NewServer(&config.Config{Server: config.ServerConfig{MaxClient: 10}})
+	ls, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	go func() {
+		for {
+			conn, err := ls.AcceptTCP()
+			if err != nil {
+				return
+			}
+			s.count.Incr()
+			go s.handleClient(conn)
+		}
+	}()
+	connect := func(user string) error {
+		mc, err := (&MysqlDB{addr: ls.Addr().String(), user: user, passwd: "secret"}).newConn()
+		if err == nil {
+			mc.Close()
+		}
+		return err
+	}
+	setUsers([]config.UserConfig{{Name: "app", Passwd: "secret", Hosts: []string{"10.0.0.0/8"}}})
+	if e, ok := connect("app").(*mysql.MySQLError); !ok || e.Number != mysql.ErrHostNotPrivileged {
+		t.Fatal(e)
+	}
+	setUsers([]config.UserConfig{{Name: "app", Passwd: "secret", Hosts: []string{"10.0.0.0/8"}}, {Name: "any", Passwd: "secret"}})
+	if e, ok := connect("app").(*mysql.MySQLError); !ok || e.Number != mysql.ErrHostNotPrivileged {
+		t.Fatal(e)
+	}
+	if err := connect("any"); err != nil {
+		t.Fatal(err)
+	}
+}